@@ -0,0 +1,55 @@
+package main
+
+import "runtime/debug"
+
+// sourceWebsite is the site the fatwas are scraped from.
+const sourceWebsite = "https://www.muftiwp.gov.my"
+
+// version is the bot's release, set at build time with
+// -ldflags "-X main.version=v1.2.0".
+var version string
+
+// botVersion returns the bot's release or, for builds without one, the
+// commit it was built from.
+func botVersion() string {
+	if version != "" {
+		return version
+	}
+
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "dev"
+	}
+	var revision, modified string
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value
+		}
+	}
+	if revision == "" {
+		return "dev"
+	}
+	revision = revision[:min(len(revision), 12)]
+	if modified == "true" {
+		revision += "-dirty"
+	}
+	return revision
+}
+
+// showAbout tells users where the fatwas come from, how current and how
+// many they are, which version of the bot is answering, and that the bot
+// isn't an official service of the mufti's office, so the content is
+// redistributed responsibly.
+func (fb *FatwaBot) showAbout(chatID int64) {
+	lang := fb.language(chatID)
+
+	scraped := tr(lang, "stats.unknown")
+	if finished, ok := lastScrape(); ok {
+		scraped = finished.Format(adminTimeFormat)
+	}
+
+	fb.sendMessage(chatID, tr(lang, "about", sourceWebsite, scraped, len(fb.dataset()), botVersion()))
+}
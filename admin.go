@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// adminTimeFormat is how times are shown in admin reports.
+const adminTimeFormat = "02/01/2006 15:04"
+
+// scrapeStarted is when the running scrape started, in Unix seconds, or 0
+// when no scrape is running.
+var scrapeStarted atomic.Int64
+
+// scrapeMu is held by whatever is writing the dataset file from the
+// website, the scraper or a check of a reported fatwa, so neither saves
+// over the other's changes.
+var scrapeMu sync.Mutex
+
+// lastScrape returns when the latest successful scrape finished, from the
+// name of the snapshot it saved, so it survives restarts.
+func lastScrape() (time.Time, bool) {
+	snapshots, err := listSnapshots()
+	if err != nil || len(snapshots) == 0 {
+		return time.Time{}, false
+	}
+
+	name := filepath.Base(snapshots[len(snapshots)-1])
+	name = strings.TrimSuffix(strings.TrimPrefix(name, "fatwa-"), ".csv.gz")
+	finished, err := time.ParseInLocation("20060102-150405", name, time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return finished, true
+}
+
+// handleAdmin runs an admin command: /reload, /users, /gaps, /topics,
+// /activity, /feedback, or /admin for the list of them. /stats is open to
+// everyone, and shows admins the showAdminStats report as well.
+func (fb *FatwaBot) handleAdmin(chatID int64, command string) {
+	switch command {
+	case "/reload":
+		fb.adminReload(chatID)
+	case "/users":
+		fb.showUserCounts(chatID)
+	case "/gaps":
+		fb.showSearchGaps(chatID)
+	case "/activity":
+		fb.showActivity(chatID)
+	case "/feedback":
+		fb.showFeedback(chatID)
+	case "/topics":
+		fb.showTopicRequests(chatID)
+	default:
+		fb.reply(chatID, "admin.help")
+	}
+}
+
+// showAdminStats reports on the dataset being served and the scraper.
+func (fb *FatwaBot) showAdminStats(chatID int64) {
+	lang := fb.language(chatID)
+
+	fb.mu.RLock()
+	fatwas, loaded := fb.fatwas, fb.loaded
+	fb.mu.RUnlock()
+
+	sources := make(map[string]int)
+	categories := make(map[string]bool)
+	for _, fatwa := range fatwas {
+		sources[fatwa.Source]++
+		categories[fatwa.Category] = true
+	}
+
+	var sourceCounts []string
+	for source, count := range sources {
+		sourceCounts = append(sourceCounts, fmt.Sprintf("%s (%d)", source, count))
+	}
+	sort.Strings(sourceCounts)
+
+	scraped := tr(lang, "admin.never")
+	if finished, ok := lastScrape(); ok {
+		scraped = finished.Format(adminTimeFormat)
+	}
+
+	scrape := tr(lang, "admin.scrape_idle")
+	if started := scrapeStarted.Load(); started != 0 {
+		scrape = tr(lang, "admin.scrape_running", time.Unix(started, 0).Format(adminTimeFormat))
+	}
+
+	fb.reply(chatID, "admin.stats",
+		len(fatwas),
+		strings.Join(sourceCounts, ", "),
+		len(categories),
+		loaded.Format(adminTimeFormat),
+		scraped,
+		scrape,
+	)
+}
+
+// adminReload loads the dataset from disk again, for when it was changed
+// without the watcher noticing.
+func (fb *FatwaBot) adminReload(chatID int64) {
+	if err := fb.reloadDataset(); err != nil {
+		log.Printf("Error reloading dataset: %v", err)
+		fb.reply(chatID, "admin.reload_failed", err)
+		return
+	}
+	fb.reply(chatID, "admin.reloaded", len(fb.dataset()))
+}
+
+// showUserCounts reports how many chats use the bot, in total and
+// recently.
+func (fb *FatwaBot) showUserCounts(chatID int64) {
+	now := time.Now()
+	periods := []time.Time{{}, now.AddDate(0, 0, -1), now.AddDate(0, 0, -7), now.AddDate(0, 0, -30)}
+
+	counts := make([]any, 0, len(periods)+1)
+	for i, since := range periods {
+		chats, groups, err := fb.store.CountUsers(since)
+		if err != nil {
+			log.Printf("Error counting users: %v", err)
+			fb.reply(chatID, "error.users")
+			return
+		}
+		counts = append(counts, chats)
+		if i == 0 {
+			counts = append(counts, groups)
+		}
+	}
+
+	fb.reply(chatID, "admin.users", counts...)
+}
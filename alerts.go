@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// maxAlerts is the number of saved searches a chat may have.
+	maxAlerts = 10
+
+	// maxAlertResults is the number of new fatwas listed per alert.
+	maxAlertResults = 5
+)
+
+// handleAlert saves the query as an alert, or lists the chat's alerts when
+// no query is given.
+func (fb *FatwaBot) handleAlert(chatID int64, query string) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		fb.showAlerts(chatID)
+		return
+	}
+
+	if _, index := fb.searchData(); parseQuery(query, index).excludesOnly() {
+		fb.reply(chatID, "search.excludes_only")
+		return
+	}
+
+	alerts, err := fb.store.Alerts(chatID)
+	if err != nil {
+		log.Printf("Error reading alerts: %v", err)
+		fb.reply(chatID, "alerts.save_error")
+		return
+	}
+	if len(alerts) >= maxAlerts {
+		fb.reply(chatID, "alerts.limit", maxAlerts)
+		return
+	}
+
+	if err := fb.store.AddAlert(chatID, query); err != nil {
+		log.Printf("Error saving alert: %v", err)
+		fb.reply(chatID, "alerts.save_error")
+		return
+	}
+
+	fb.reply(chatID, "alerts.saved", query)
+}
+
+// showAlerts lists the chat's saved searches, each with a button to
+// delete it.
+func (fb *FatwaBot) showAlerts(chatID int64) {
+	alerts, err := fb.store.Alerts(chatID)
+	if err != nil {
+		log.Printf("Error reading alerts: %v", err)
+		fb.reply(chatID, "alerts.read_error")
+		return
+	}
+
+	if len(alerts) == 0 {
+		fb.reply(chatID, "alerts.empty")
+		return
+	}
+
+	message := fb.t(chatID, "alerts.title") + "\n\n"
+	var keyboard [][]tgbotapi.InlineKeyboardButton
+	for i, alert := range alerts {
+		message += fmt.Sprintf("%d. %s\n", i+1, html.EscapeString(alert.Query))
+		keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fb.t(chatID, "button.delete", i+1), fmt.Sprintf("unalert_%d", alert.ID)),
+		))
+	}
+
+	msg := tgbotapi.NewMessage(chatID, message)
+	msg.ParseMode = tgbotapi.ModeHTML
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(keyboard...)
+	fb.send(msg)
+}
+
+// removeAlert deletes a saved search. idStr is the alert ID from the
+// button.
+func (fb *FatwaBot) removeAlert(chatID int64, idStr string) {
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		fb.reply(chatID, "error.alert")
+		return
+	}
+
+	if err := fb.store.RemoveAlert(chatID, id); err != nil {
+		log.Printf("Error removing alert: %v", err)
+		fb.reply(chatID, "alerts.remove_error")
+		return
+	}
+
+	fb.reply(chatID, "alerts.removed")
+}
+
+// notifyAlerts runs every saved search against the dataset and tells each
+// chat about matching fatwas among those just added.
+func (fb *FatwaBot) notifyAlerts(added map[FatwaKey]bool) {
+	if len(added) == 0 {
+		return
+	}
+
+	alerts, err := fb.store.AllAlerts()
+	if err != nil {
+		log.Printf("Error reading alerts: %v", err)
+		return
+	}
+
+	fatwas, index := fb.searchData()
+	for _, alert := range alerts {
+		var matches []Fatwa
+		for _, result := range searchIndexed(fatwas, index, alert.Query, "keyword") {
+			if added[result.Fatwa.Key()] && !result.Fuzzy {
+				matches = append(matches, result.Fatwa)
+			}
+		}
+		if len(matches) == 0 {
+			continue
+		}
+
+		lang := fb.language(alert.ChatID)
+		message := tr(lang, "alerts.new", alert.Query) + "\n\n"
+		var keyboard [][]tgbotapi.InlineKeyboardButton
+		for i, fatwa := range matches[:min(len(matches), maxAlertResults)] {
+			message += fmt.Sprintf("<b>%d. %s</b>\n📅 %s\n\n", i+1, html.EscapeString(fatwa.Title), html.EscapeString(fatwa.Date))
+			keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(tr(lang, "button.read_fatwa", i+1), fatwaCallback("view", fatwa.Key())),
+			))
+		}
+		if len(matches) > maxAlertResults {
+			message += tr(lang, "alerts.more", len(matches)-maxAlertResults, alert.Query) + "\n"
+		}
+
+		msg := tgbotapi.NewMessage(alert.ChatID, message)
+		msg.ParseMode = tgbotapi.ModeHTML
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(keyboard...)
+		if _, err := fb.trySendBulk(msg); err != nil {
+			log.Printf("Error sending alert to %d: %v", alert.ChatID, err)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	// analyticsRetention is how long the query log and daily activity are
+	// kept.
+	analyticsRetention = 180 * 24 * time.Hour
+
+	// gapsPeriod and gapsShown bound the zero-result report.
+	gapsPeriod = 30 * 24 * time.Hour
+	gapsShown  = 20
+
+	// activityDays is how many days the daily active users report covers.
+	activityDays = 14
+)
+
+// logQuery records a search and how many results it found in the query
+// log. Failures are only logged, as they shouldn't fail the search.
+func (fb *FatwaBot) logQuery(chatID int64, query, searchType string, results int) {
+	if err := fb.store.LogQuery(chatID, query, searchType, results); err != nil {
+		log.Printf("Error logging query: %v", err)
+	}
+}
+
+// pruneAnalytics deletes usage data older than analyticsRetention.
+func (fb *FatwaBot) pruneAnalytics() {
+	if err := fb.store.PruneAnalytics(time.Now().Add(-analyticsRetention)); err != nil {
+		log.Printf("Error pruning analytics: %v", err)
+	}
+}
+
+// showSearchGaps lists the searches that found nothing over the last
+// month, pointing at topics the dataset lacks.
+func (fb *FatwaBot) showSearchGaps(chatID int64) {
+	queries, err := fb.store.ZeroResultQueries(time.Now().Add(-gapsPeriod), gapsShown)
+	if err != nil {
+		log.Printf("Error reading zero-result queries: %v", err)
+		fb.reply(chatID, "analytics.error")
+		return
+	}
+	if len(queries) == 0 {
+		fb.reply(chatID, "analytics.gaps_none")
+		return
+	}
+
+	message := fb.t(chatID, "analytics.gaps") + "\n\n"
+	for i, query := range queries {
+		message += fmt.Sprintf("%d. <code>%s</code> × %d\n", i+1, html.EscapeString(query.Query), query.Count)
+	}
+	fb.sendMessage(chatID, message)
+}
+
+// showActivity charts the chats that used the bot on each of the last
+// activityDays days.
+func (fb *FatwaBot) showActivity(chatID int64) {
+	since := time.Now().UTC().AddDate(0, 0, -(activityDays - 1))
+	days, err := fb.store.DailyActiveUsers(since)
+	if err != nil {
+		log.Printf("Error reading daily active users: %v", err)
+		fb.reply(chatID, "analytics.error")
+		return
+	}
+
+	counts := make(map[string]int, len(days))
+	highest := 0
+	for _, day := range days {
+		counts[day.Day] = day.Count
+		highest = max(highest, day.Count)
+	}
+
+	// Bars are scaled to the busiest day, in a monospace block so they line
+	// up
+	const barWidth = 15
+	var chart strings.Builder
+	for i := range activityDays {
+		day := since.AddDate(0, 0, i)
+		count := counts[day.Format(time.DateOnly)]
+		bar := 0
+		if highest > 0 {
+			bar = (count*barWidth + highest - 1) / highest
+		}
+		fmt.Fprintf(&chart, "%s %-*s %d\n", day.Format("02/01"), barWidth, strings.Repeat("█", bar), count)
+	}
+
+	fb.sendMessage(chatID, fb.t(chatID, "analytics.activity", activityDays)+"\n\n<pre>"+chart.String()+"</pre>")
+}
@@ -0,0 +1,36 @@
+package main
+
+import "strings"
+
+// arabicReplacer folds the spelling variants of Arabic letters that users
+// rarely type consistently: the hamza forms of alef, alef maksura, ta
+// marbuta and hamza seats. Tatweel is dropped.
+var arabicReplacer = strings.NewReplacer(
+	"أ", "ا", "إ", "ا", "آ", "ا", "ٱ", "ا",
+	"ى", "ي", "ئ", "ي",
+	"ؤ", "و",
+	"ة", "ه",
+	"\u0640", "",
+)
+
+// normalizeArabic strips tashkeel (vowel marks, shadda, sukun and the
+// superscript alef) and unifies letter variants, so a query matches Arabic
+// text with or without diacritics.
+func normalizeArabic(text string) string {
+	if !strings.ContainsFunc(text, isArabic) {
+		return text
+	}
+
+	text = strings.Map(func(r rune) rune {
+		if (r >= '\u064B' && r <= '\u065F') || r == '\u0670' {
+			return -1
+		}
+		return r
+	}, text)
+
+	return arabicReplacer.Replace(text)
+}
+
+func isArabic(r rune) bool {
+	return r >= '\u0600' && r <= '\u06FF'
+}
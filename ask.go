@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// askSources is how many of the most relevant fatwas a question is
+	// answered from.
+	askSources = 5
+
+	// askExcerptLength is how much of each fatwa the chat model is given,
+	// in characters, to bound the cost of a question.
+	askExcerptLength = 3000
+
+	// askNoAnswer is what the chat model replies when the fatwas don't
+	// answer the question.
+	askNoAnswer = "NO_ANSWER"
+
+	// askPrompt instructs the chat model, given the language to answer in.
+	// Answers may only use the numbered fatwas they are given, citing them,
+	// so every statement can be checked against its source.
+	askPrompt = "You answer questions using only the numbered fatwas that follow the question, " +
+		"each headed by its title and who issued it. Answer in %s, in at most 150 words of plain text. " +
+		"Cite the fatwa behind every statement by its number in square brackets, such as [1]. " +
+		"Do not use any other knowledge, do not guess, and do not give rulings the fatwas don't state. " +
+		"If the fatwas don't answer the question, reply with " + askNoAnswer + " only."
+)
+
+// askCitation matches a citation such as "[2]" in an answer.
+var askCitation = regexp.MustCompile(`\[(\d+)\]`)
+
+// askQuestion answers a question from the fatwas most relevant to it, as
+// /tanya. The chat model is only given excerpts of those fatwas and must
+// cite them, and the answer lists the fatwas it cited with links and
+// buttons to read them in full.
+func (fb *FatwaBot) askQuestion(chatID int64, question string) {
+	question = strings.TrimSpace(question)
+	if question == "" {
+		fb.reply(chatID, "ask.usage")
+		return
+	}
+	if fb.llm == nil {
+		fb.reply(chatID, "ask.disabled")
+		return
+	}
+
+	fatwas, index := fb.searchData()
+	sources := retrieveFatwas(fatwas, index, question, askSources)
+	fb.logQuery(chatID, question, "ask", len(sources))
+	if len(sources) == 0 {
+		fb.reply(chatID, "ask.no_sources")
+		return
+	}
+
+	lang := fb.language(chatID)
+	fb.sendTyping(chatID)
+	fb.goSafe(chatID, func() {
+		answer, err := fb.answer(question, sources, lang)
+		if err != nil {
+			log.Printf("Error answering question: %v", err)
+			fb.reply(chatID, "ask.error")
+			return
+		}
+
+		// An answer citing nothing, including askNoAnswer, isn't grounded
+		// in the fatwas, so the closest fatwas are offered instead
+		cited := citedFatwas(answer, sources)
+		if len(cited) == 0 {
+			msg := tgbotapi.NewMessage(chatID, tr(lang, "ask.no_answer"))
+			msg.ParseMode = tgbotapi.ModeHTML
+			msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(relatedButtons(sources)...)
+			fb.send(msg)
+			return
+		}
+
+		msg := tgbotapi.NewMessage(chatID, answerMessage(question, answer, sources, cited, lang))
+		msg.ParseMode = tgbotapi.ModeHTML
+		msg.DisableWebPagePreview = true
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(relatedButtons(citedSources(sources, cited))...)
+		fb.send(msg)
+	})
+}
+
+// retrieveFatwas returns up to limit fatwas most relevant to a question,
+// ranked like keyword searches. Unlike a search, a fatwa doesn't need every
+// word of the question: any of its word roots, other than stopwords, makes
+// a fatwa a candidate.
+func retrieveFatwas(fatwas []Fatwa, index *searchIndex, question string, limit int) []Fatwa {
+	stemmer := index.malayStemmer()
+	var roots []string
+	for _, word := range removeStopwords(tokenize(normalizeSearchText(question))) {
+		roots = append(roots, stemmer.stem(word))
+	}
+	roots = uniqueWords(roots)
+
+	var docs []int
+	for _, root := range roots {
+		docs = unionDocs(docs, index.rootDocs(root, true))
+	}
+	if len(docs) == 0 {
+		return nil
+	}
+
+	results := make([]searchResult, len(docs))
+	for n, i := range docs {
+		results[n] = searchResult{Fatwa: fatwas[i], Doc: i}
+	}
+	rankResults(results, index, strings.Join(roots, " "))
+
+	var sources []Fatwa
+	for _, result := range results[:min(limit, len(results))] {
+		sources = append(sources, result.Fatwa)
+	}
+	return sources
+}
+
+// answer asks the chat model to answer a question from the numbered
+// excerpts of sources.
+func (fb *FatwaBot) answer(question string, sources []Fatwa, lang string) (string, error) {
+	language, ok := summaryLanguages[lang]
+	if !ok {
+		language = summaryLanguages[langMalay]
+	}
+
+	var message strings.Builder
+	fmt.Fprintf(&message, "Question: %s\n", question)
+	for n, fatwa := range sources {
+		fmt.Fprintf(&message, "\n[%d] %s (%s)\n%s\n", n+1, fatwa.Title, sourceName(fatwa.Source), plainExcerpt(fatwa.Content, askExcerptLength))
+	}
+
+	return fb.llm.complete(fmt.Sprintf(askPrompt, language), message.String())
+}
+
+// citedFatwas returns the numbers, from 1, of the sources an answer cites,
+// in order. Numbers outside the sources are ignored.
+func citedFatwas(answer string, sources []Fatwa) []int {
+	seen := make(map[int]bool)
+	for _, match := range askCitation.FindAllStringSubmatch(answer, -1) {
+		if n, err := strconv.Atoi(match[1]); err == nil && n >= 1 && n <= len(sources) {
+			seen[n] = true
+		}
+	}
+
+	cited := make([]int, 0, len(seen))
+	for n := range seen {
+		cited = append(cited, n)
+	}
+	sort.Ints(cited)
+	return cited
+}
+
+// citedSources returns the sources with the given numbers.
+func citedSources(sources []Fatwa, cited []int) []Fatwa {
+	fatwas := make([]Fatwa, 0, len(cited))
+	for _, n := range cited {
+		fatwas = append(fatwas, sources[n-1])
+	}
+	return fatwas
+}
+
+// answerMessage formats an answer under its question, followed by the
+// fatwas it cited, numbered as in the answer and linked to the website.
+func answerMessage(question, answer string, sources []Fatwa, cited []int, lang string) string {
+	message := tr(lang, "ask.title", plainExcerpt(question, relatedTitleLength*2)) + "\n\n"
+	message += html.EscapeString(answer) + "\n\n"
+	message += tr(lang, "ask.sources") + "\n"
+	for _, n := range cited {
+		fatwa := sources[n-1]
+		message += fmt.Sprintf("[%d] <a href=\"%s\">%s</a>\n", n, html.EscapeString(fatwa.URL), html.EscapeString(fatwa.Title))
+	}
+	message += "\n" + tr(lang, "ask.disclaimer")
+	return message
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// backupConfig holds the settings for uploading dataset backups to an
+// S3-compatible bucket (AWS S3, GCS interoperability, MinIO, R2, ...).
+type backupConfig struct {
+	Endpoint      string
+	Bucket        string
+	AccessKey     string
+	SecretKey     string
+	Region        string
+	Prefix        string
+	UseSSL        bool
+	RetentionDays int
+}
+
+// loadBackupConfig reads the backup settings from the environment. Backups
+// are disabled unless BACKUP_S3_BUCKET is set.
+func loadBackupConfig() (backupConfig, bool) {
+	cfg := backupConfig{
+		Endpoint:      os.Getenv("BACKUP_S3_ENDPOINT"),
+		Bucket:        os.Getenv("BACKUP_S3_BUCKET"),
+		AccessKey:     os.Getenv("BACKUP_S3_ACCESS_KEY"),
+		SecretKey:     os.Getenv("BACKUP_S3_SECRET_KEY"),
+		Region:        os.Getenv("BACKUP_S3_REGION"),
+		Prefix:        os.Getenv("BACKUP_S3_PREFIX"),
+		UseSSL:        os.Getenv("BACKUP_S3_USE_SSL") != "false",
+		RetentionDays: 30,
+	}
+
+	if cfg.Bucket == "" {
+		return cfg, false
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "s3.amazonaws.com"
+	}
+	if cfg.Prefix == "" {
+		cfg.Prefix = "fatwa-backups/"
+	} else if !strings.HasSuffix(cfg.Prefix, "/") {
+		cfg.Prefix += "/"
+	}
+	if days, err := strconv.Atoi(os.Getenv("BACKUP_RETENTION_DAYS")); err == nil {
+		cfg.RetentionDays = days
+	}
+
+	return cfg, true
+}
+
+// backupDataset uploads the current dataset files under a timestamped
+// prefix and then removes backups older than the retention period.
+func backupDataset() error {
+	cfg, enabled := loadBackupConfig()
+	if !enabled {
+		return nil
+	}
+
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure: cfg.UseSSL,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return fmt.Errorf("cannot create backup client: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	files, cleanup, err := backupFiles()
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	folder := cfg.Prefix + time.Now().UTC().Format("20060102-150405") + "/"
+	for name, path := range files {
+		_, err := client.FPutObject(ctx, cfg.Bucket, folder+name, path, minio.PutObjectOptions{})
+		if err != nil {
+			return fmt.Errorf("error uploading %s: %v", name, err)
+		}
+		fmt.Printf("Uploaded backup s3://%s/%s%s\n", cfg.Bucket, folder, name)
+	}
+
+	if cfg.RetentionDays > 0 {
+		removed, err := pruneBackups(ctx, client, cfg)
+		if err != nil {
+			return fmt.Errorf("error pruning old backups: %v", err)
+		}
+		if removed > 0 {
+			fmt.Printf("Removed %d backup objects older than %d days\n", removed, cfg.RetentionDays)
+		}
+	}
+
+	return nil
+}
+
+// backupFiles returns the object names and local paths to upload. SQLite
+// databases are copied with VACUUM INTO so the backup is consistent even
+// while the bot has the database open.
+func backupFiles() (map[string]string, func(), error) {
+	files := make(map[string]string)
+	var temps []string
+	cleanup := func() {
+		for _, path := range temps {
+			os.Remove(path)
+		}
+	}
+
+	if os.Getenv("DATABASE_PATH") != "" {
+		path, err := snapshotDatabase(databasePath(), datasetMigrations)
+		if err != nil {
+			return nil, cleanup, err
+		}
+		temps = append(temps, path)
+		files[filepath.Base(databasePath())] = path
+	} else {
+		files[filepath.Base(datasetPath())] = datasetPath()
+	}
+
+	// Include bookmarks, subscriptions and other per-user state
+	if _, err := os.Stat(userDatabasePath()); err == nil {
+		path, err := snapshotDatabase(userDatabasePath(), userMigrations)
+		if err != nil {
+			return nil, cleanup, err
+		}
+		temps = append(temps, path)
+		files[filepath.Base(userDatabasePath())] = path
+	}
+
+	if _, err := os.Stat("fatwa_history.csv"); err == nil {
+		files["fatwa_history.csv"] = "fatwa_history.csv"
+	}
+
+	return files, cleanup, nil
+}
+
+func snapshotDatabase(path string, migrations migrationSet) (string, error) {
+	db, err := openDatabase(path, migrations)
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
+
+	target := filepath.Join(os.TempDir(), fmt.Sprintf("fatwa-backup-%d.db", time.Now().UnixNano()))
+	if _, err := db.Exec("VACUUM INTO ?", target); err != nil {
+		return "", fmt.Errorf("cannot snapshot database: %v", err)
+	}
+	return target, nil
+}
+
+func pruneBackups(ctx context.Context, client *minio.Client, cfg backupConfig) (int, error) {
+	cutoff := time.Now().AddDate(0, 0, -cfg.RetentionDays)
+	removed := 0
+
+	for object := range client.ListObjects(ctx, cfg.Bucket, minio.ListObjectsOptions{Prefix: cfg.Prefix, Recursive: true}) {
+		if object.Err != nil {
+			return removed, object.Err
+		}
+		if object.LastModified.After(cutoff) {
+			continue
+		}
+		if err := client.RemoveObject(ctx, cfg.Bucket, object.Key, minio.RemoveObjectOptions{}); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+
+	return removed, nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"log"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// loadBans reads the banned chats and users from the store into memory,
+// so checking every update doesn't touch the database.
+func (fb *FatwaBot) loadBans() error {
+	bans, err := fb.store.Bans()
+	if err != nil {
+		return err
+	}
+
+	fb.bannedMu.Lock()
+	defer fb.bannedMu.Unlock()
+	for _, chatID := range bans {
+		fb.banned[chatID] = true
+	}
+	return nil
+}
+
+// isBanned reports whether an update comes from a banned chat or user.
+// Banning a user also silences them in groups, and banning a group
+// silences everyone in it. Admins are never banned.
+func (fb *FatwaBot) isBanned(update tgbotapi.Update) bool {
+	chatID, user, ok := updateChat(update)
+	if !ok || fb.isAdmin(user) {
+		return false
+	}
+
+	fb.bannedMu.RLock()
+	defer fb.bannedMu.RUnlock()
+	return fb.banned[chatID] || (user != nil && fb.banned[user.ID])
+}
+
+// handleBan runs /ban ID or /unban ID for an admin. /ban without an ID
+// lists the banned chats and users.
+func (fb *FatwaBot) handleBan(chatID, adminID int64, command, idStr string) {
+	idStr = strings.TrimSpace(idStr)
+	if idStr == "" {
+		if command == "/ban" {
+			fb.showBans(chatID)
+		} else {
+			fb.reply(chatID, "ban.usage")
+		}
+		return
+	}
+
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		fb.reply(chatID, "ban.usage")
+		return
+	}
+
+	if command == "/unban" {
+		if err := fb.store.Unban(id); err != nil {
+			log.Printf("Error unbanning: %v", err)
+			fb.reply(chatID, "ban.error")
+			return
+		}
+		fb.bannedMu.Lock()
+		wasBanned := fb.banned[id]
+		delete(fb.banned, id)
+		fb.bannedMu.Unlock()
+
+		if !wasBanned {
+			fb.reply(chatID, "ban.not_banned", id)
+			return
+		}
+		fb.reply(chatID, "ban.lifted", id)
+		return
+	}
+
+	if fb.admins[id] {
+		fb.reply(chatID, "ban.admin")
+		return
+	}
+	if err := fb.store.Ban(id, adminID); err != nil {
+		log.Printf("Error banning: %v", err)
+		fb.reply(chatID, "ban.error")
+		return
+	}
+	fb.bannedMu.Lock()
+	fb.banned[id] = true
+	fb.bannedMu.Unlock()
+
+	log.Printf("Admin %d banned %d", adminID, id)
+	fb.reply(chatID, "ban.done", id)
+}
+
+// showBans lists the banned chats and users.
+func (fb *FatwaBot) showBans(chatID int64) {
+	bans, err := fb.store.Bans()
+	if err != nil {
+		log.Printf("Error reading bans: %v", err)
+		fb.reply(chatID, "ban.error")
+		return
+	}
+	if len(bans) == 0 {
+		fb.reply(chatID, "ban.none")
+		return
+	}
+
+	message := fb.t(chatID, "ban.list") + "\n"
+	for _, id := range bans {
+		message += "\n• <code>" + strconv.FormatInt(id, 10) + "</code>"
+	}
+	fb.sendMessage(chatID, message)
+}
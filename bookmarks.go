@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// bookmarksShown is the number of saved fatwas listed by /bookmarks, each
+// with its own row of buttons.
+const bookmarksShown = 20
+
+// saveButton is the "💾 Simpan" button under fatwa details.
+func saveButton(key FatwaKey, lang string) tgbotapi.InlineKeyboardButton {
+	return tgbotapi.NewInlineKeyboardButtonData(tr(lang, "button.save"), fatwaCallback("save", key))
+}
+
+// saveBookmark adds a fatwa to a chat's bookmarks. idStr is the fatwa ID
+// from /save or the save button.
+func (fb *FatwaBot) saveBookmark(chatID int64, idStr string) {
+	idStr = strings.TrimSpace(idStr)
+	if idStr == "" {
+		fb.reply(chatID, "bookmarks.no_id")
+		return
+	}
+
+	key, err := parseFatwaKey(idStr)
+	if err != nil {
+		fb.reply(chatID, "id.invalid")
+		return
+	}
+	if _, ok := fb.findFatwa(key); !ok {
+		fb.reply(chatID, "id.not_found", key)
+		return
+	}
+
+	if err := fb.store.AddBookmark(chatID, key); err != nil {
+		log.Printf("Error saving bookmark: %v", err)
+		fb.reply(chatID, "bookmarks.save_error")
+		return
+	}
+
+	fb.reply(chatID, "bookmarks.saved")
+}
+
+// removeBookmark removes a fatwa from a chat's bookmarks. idStr is the
+// fatwa ID from the button.
+func (fb *FatwaBot) removeBookmark(chatID int64, idStr string) {
+	key, err := parseFatwaKey(idStr)
+	if err != nil {
+		fb.reply(chatID, "error.fatwa_id")
+		return
+	}
+
+	if err := fb.store.RemoveBookmark(chatID, key); err != nil {
+		log.Printf("Error removing bookmark: %v", err)
+		fb.reply(chatID, "bookmarks.remove_error")
+		return
+	}
+
+	fb.reply(chatID, "bookmarks.removed")
+}
+
+// showBookmarks lists a chat's saved fatwas, newest first, with buttons to
+// read or remove each. Fatwas no longer in the dataset are listed by ID.
+func (fb *FatwaBot) showBookmarks(chatID int64) {
+	keys, err := fb.store.Bookmarks(chatID)
+	if err != nil {
+		log.Printf("Error reading bookmarks: %v", err)
+		fb.reply(chatID, "bookmarks.read_error")
+		return
+	}
+
+	if len(keys) == 0 {
+		fb.reply(chatID, "bookmarks.empty")
+		return
+	}
+
+	lang := fb.language(chatID)
+	message := tr(lang, "bookmarks.title") + "\n\n"
+	var keyboard [][]tgbotapi.InlineKeyboardButton
+	for i, key := range keys[:min(len(keys), bookmarksShown)] {
+		number := i + 1
+		if fatwa, ok := fb.findFatwa(key); ok {
+			message += fmt.Sprintf("%d. %s\n", number, html.EscapeString(fatwa.Title))
+		} else {
+			message += fmt.Sprintf("%d. %s\n", number, tr(lang, "bookmarks.missing", key))
+		}
+		keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(tr(lang, "button.read", number), fatwaCallback("view", key)),
+			tgbotapi.NewInlineKeyboardButtonData(tr(lang, "button.remove", number), fatwaCallback("unsave", key)),
+		))
+	}
+	if len(keys) > bookmarksShown {
+		message += "\n" + tr(lang, "bookmarks.shown", bookmarksShown, len(keys))
+	}
+
+	msg := tgbotapi.NewMessage(chatID, message)
+	msg.ParseMode = tgbotapi.ModeHTML
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(keyboard...)
+	fb.send(msg)
+}
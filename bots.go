@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// botConfig holds the settings of one of the bots the process serves. All
+// bots share the dataset; each has its own user store, so users, their
+// settings and their subscriptions belong to the bot they started.
+type botConfig struct {
+	Name     string   // "" for the main bot, which runs the shared jobs
+	Token    string   // from @BotFather
+	Language string   // for users who haven't picked one with /lang
+	Commands []string // shown in the "/" menu, every command if empty
+	UserDB   string   // path of the bot's user store
+}
+
+// loadBotConfigs reads the bots to serve from the environment: the main bot
+// from BOT_TOKEN, BOT_LANG and BOT_COMMANDS, then one bot for each name in
+// BOTS, such as "en,staging", from BOT_EN_TOKEN, BOT_EN_LANG,
+// BOT_EN_COMMANDS and BOT_EN_USER_DB (default users-en.db).
+func loadBotConfigs() ([]botConfig, error) {
+	main := botConfig{UserDB: userDatabasePath()}
+	if err := main.load("BOT_"); err != nil {
+		return nil, err
+	}
+	configs := []botConfig{main}
+
+	for _, name := range strings.Split(os.Getenv("BOTS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		config := botConfig{Name: name, UserDB: fmt.Sprintf("users-%s.db", name)}
+		if err := config.load("BOT_" + strings.ToUpper(name) + "_"); err != nil {
+			return nil, err
+		}
+		configs = append(configs, config)
+	}
+
+	tokens := make(map[string]bool)
+	for _, config := range configs {
+		if tokens[config.Token] {
+			return nil, fmt.Errorf("bot %q uses the same token as another bot", config.Name)
+		}
+		tokens[config.Token] = true
+	}
+	return configs, nil
+}
+
+// load reads a bot's settings from the environment variables starting with
+// prefix.
+func (config *botConfig) load(prefix string) error {
+	config.Token = os.Getenv(prefix + "TOKEN")
+	if config.Token == "" {
+		return fmt.Errorf("%sTOKEN not set in environment", prefix)
+	}
+
+	config.Language = strings.ToLower(strings.TrimSpace(os.Getenv(prefix + "LANG")))
+	if config.Language == "" {
+		config.Language = langMalay
+	}
+	if !isLanguage(config.Language) {
+		return fmt.Errorf("%sLANG: unknown language %q", prefix, config.Language)
+	}
+
+	for _, name := range strings.Split(os.Getenv(prefix+"COMMANDS"), ",") {
+		name = strings.TrimPrefix(strings.TrimSpace(name), "/")
+		if name == "" {
+			continue
+		}
+		if !slices.Contains(botCommands, name) {
+			return fmt.Errorf("%sCOMMANDS: unknown command %q", prefix, name)
+		}
+		config.Commands = append(config.Commands, name)
+	}
+
+	if path := os.Getenv(prefix + "USER_DB"); path != "" && config.Name != "" {
+		config.UserDB = path
+	}
+	return nil
+}
+
+// menuCommands are the commands in the bot's "/" menu.
+func (fb *FatwaBot) menuCommands() []string {
+	if len(fb.commands) > 0 {
+		return fb.commands
+	}
+	return botCommands
+}
+
+// isMainBot reports whether the bot is the main one, which alone posts to
+// the fatwa of the day channel.
+func (fb *FatwaBot) isMainBot() bool {
+	return fb.name == ""
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// prepareBroadcast shows an admin how an announcement will look and how
+// many chats it will reach, with buttons to send or cancel it. Only the
+// latest prepared announcement of each admin can be sent.
+func (fb *FatwaBot) prepareBroadcast(chatID int64, text string) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		fb.reply(chatID, "broadcast.no_text")
+		return
+	}
+
+	chatIDs, err := fb.store.ChatIDs()
+	if err != nil {
+		log.Printf("Error reading users: %v", err)
+		fb.reply(chatID, "error.users")
+		return
+	}
+
+	fb.broadcastsMu.Lock()
+	fb.broadcasts[chatID] = text
+	fb.broadcastsMu.Unlock()
+
+	fb.reply(chatID, "broadcast.preview")
+
+	// Announcements are sent as plain text, so stray markup can't make
+	// them fail to send
+	preview := tgbotapi.NewMessage(chatID, text)
+	preview.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(fb.t(chatID, "broadcast.send", len(chatIDs)), "broadcast_send"),
+		tgbotapi.NewInlineKeyboardButtonData(fb.t(chatID, "broadcast.cancel"), "broadcast_cancel"),
+	))
+	fb.send(preview)
+}
+
+// previewBroadcast shows an admin how an announcement will look and how
+// many chats it would reach, without preparing it to be sent.
+func (fb *FatwaBot) previewBroadcast(chatID int64, text string) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		fb.reply(chatID, "broadcast.no_text")
+		return
+	}
+
+	chatIDs, err := fb.store.ChatIDs()
+	if err != nil {
+		log.Printf("Error reading users: %v", err)
+		fb.reply(chatID, "error.users")
+		return
+	}
+
+	fb.reply(chatID, "broadcast.preview")
+	fb.send(tgbotapi.NewMessage(chatID, text))
+	fb.reply(chatID, "broadcast.reach", len(chatIDs))
+}
+
+// confirmBroadcast sends or cancels an admin's prepared announcement.
+// action is "send" or "cancel" from the preview buttons.
+func (fb *FatwaBot) confirmBroadcast(chatID int64, action string) {
+	fb.broadcastsMu.Lock()
+	text, ok := fb.broadcasts[chatID]
+	delete(fb.broadcasts, chatID)
+	fb.broadcastsMu.Unlock()
+
+	if !ok {
+		fb.reply(chatID, "broadcast.none")
+		return
+	}
+	if action != "send" {
+		fb.reply(chatID, "broadcast.cancelled")
+		return
+	}
+
+	chatIDs, err := fb.store.ChatIDs()
+	if err != nil {
+		log.Printf("Error reading users: %v", err)
+		fb.reply(chatID, "error.users")
+		return
+	}
+
+	statusID := fb.sendStatus(chatID, "broadcast.sending", len(chatIDs))
+	fb.goSafe(chatID, func() { fb.broadcast(chatID, statusID, text, chatIDs) })
+}
+
+// broadcast sends an announcement to every chat, paced by the outbox, and
+// reports to the admin how many chats it reached, in place of the status
+// message.
+func (fb *FatwaBot) broadcast(adminChatID int64, statusID int, text string, chatIDs []int64) {
+	started := time.Now()
+	delivered, failed := 0, 0
+
+	for _, chatID := range chatIDs {
+		if _, err := fb.trySendBulk(tgbotapi.NewMessage(chatID, text)); err != nil {
+			log.Printf("Error broadcasting to %d: %v", chatID, err)
+			failed++
+		} else {
+			delivered++
+		}
+	}
+
+	log.Printf("Broadcast delivered to %d chats, failed for %d", delivered, failed)
+	report := fb.t(adminChatID, "broadcast.report", delivered, failed, time.Since(started).Round(time.Second))
+	fb.replaceStatus(adminChatID, statusID, report, nil)
+}
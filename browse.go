@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// popularShown is the number of most viewed fatwas listed by the
+// ⭐ Popular menu button.
+const popularShown = 50
+
+// isBrowseType reports whether a search type lists fatwas by browsing
+// rather than by matching a query.
+func isBrowseType(searchType string) bool {
+	return searchType == "year" || searchType == "letter" || searchType == "popular" || searchType == "new"
+}
+
+// browseFatwas lists the fatwas published in a year ("year"), whose title
+// starts with a letter ("letter"), with the most views ("popular") or
+// added by the latest scrape ("new", whose value is the day it finished),
+// for users who don't know what to search for. Years and new fatwas are
+// listed newest first, letters by title.
+func browseFatwas(fatwas []Fatwa, value, browseType string) []searchResult {
+	var results []searchResult
+
+	switch browseType {
+	case "year":
+		year, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return nil
+		}
+		for i, fatwa := range fatwas {
+			if date, ok := parseFatwaDate(fatwa.Date); ok && date.Year() == year {
+				results = append(results, searchResult{Fatwa: fatwa, Doc: i})
+			}
+		}
+		sortResults(results, sortNewest)
+	case "letter":
+		letter := normalizeSearchText(strings.TrimSpace(value))
+		for i, fatwa := range fatwas {
+			if initial := titleInitial(fatwa.Title); initial != "" && initial == letter {
+				results = append(results, searchResult{Fatwa: fatwa, Doc: i})
+			}
+		}
+		sort.SliceStable(results, func(a, b int) bool {
+			return titleSortKey(results[a].Fatwa.Title) < titleSortKey(results[b].Fatwa.Title)
+		})
+	case "popular":
+		for i, fatwa := range fatwas {
+			results = append(results, searchResult{Fatwa: fatwa, Doc: i})
+		}
+		sortResults(results, sortPopular)
+		results = results[:min(len(results), popularShown)]
+	case "new":
+		additions, err := loadNewFatwas(newFatwasFile)
+		if err != nil {
+			return nil
+		}
+		added := make(map[FatwaKey]bool, len(additions.Fatwas))
+		for _, key := range additions.Fatwas {
+			added[key] = true
+		}
+		for i, fatwa := range fatwas {
+			if added[fatwa.Key()] {
+				results = append(results, searchResult{Fatwa: fatwa, Doc: i})
+			}
+		}
+		sortResults(results, sortNewest)
+	}
+
+	return results
+}
+
+// titleSortKey orders titles alphabetically, ignoring case, accents and
+// leading quotes.
+func titleSortKey(title string) string {
+	return normalizeSearchText(strings.TrimLeftFunc(title, func(r rune) bool { return !unicode.IsLetter(r) }))
+}
+
+// titleInitial returns the normalized first letter of a title, skipping
+// leading quotes and punctuation.
+func titleInitial(title string) string {
+	i := strings.IndexFunc(title, unicode.IsLetter)
+	if i < 0 {
+		return ""
+	}
+	r, _ := utf8.DecodeRuneInString(title[i:])
+	return normalizeSearchText(string(r))
+}
+
+// browse lists the fatwas of a year, starting with a letter or the most
+// popular ones, paged like search results. Without a year or letter it
+// offers the years or letters there are fatwas for.
+func (fb *FatwaBot) browse(chatID int64, value, browseType string) {
+	value = strings.TrimSpace(value)
+	if value == "" && browseType != "popular" {
+		fb.sendBrowseOptions(chatID, browseType)
+		return
+	}
+
+	switch browseType {
+	case "year":
+		if year, err := strconv.Atoi(value); err != nil || year < 1000 || year > 9999 {
+			fb.reply(chatID, "browse.bad_year")
+			return
+		}
+	case "letter":
+		if utf8.RuneCountInString(value) != 1 || !unicode.IsLetter([]rune(value)[0]) {
+			fb.reply(chatID, "browse.bad_letter")
+			return
+		}
+		value = strings.ToUpper(value)
+	}
+
+	lang := fb.language(chatID)
+	fatwas, index := fb.searchData()
+	results := runSearch(fatwas, index, value, browseType)
+	if len(results) == 0 {
+		fb.sendMessage(chatID, tr(lang, "browse.none", browseTitle(value, browseType, lang)))
+		return
+	}
+
+	session := fb.startSearchSession(chatID, value, "", browseType, "")
+	message, keyboard := searchResultsPage(results, session, 0, newHighlighter(value, browseType, index), lang)
+
+	msg := tgbotapi.NewMessage(chatID, message)
+	msg.ParseMode = tgbotapi.ModeHTML
+	msg.ReplyMarkup = keyboard
+	fb.send(msg)
+}
+
+// browseTitle describes the fatwas being browsed.
+func browseTitle(value, browseType, lang string) string {
+	switch browseType {
+	case "year":
+		return tr(lang, "browse.year", value)
+	case "popular":
+		return tr(lang, "browse.popular")
+	case "new":
+		return tr(lang, "browse.new", value)
+	}
+	return tr(lang, "browse.letter", value)
+}
+
+// sendBrowseOptions offers a button for every year or title initial that
+// has fatwas, with the number of fatwas for each.
+func (fb *FatwaBot) sendBrowseOptions(chatID int64, browseType string) {
+	counts := make(map[string]int)
+	for _, fatwa := range fb.dataset() {
+		if browseType == "year" {
+			if date, ok := parseFatwaDate(fatwa.Date); ok {
+				counts[strconv.Itoa(date.Year())]++
+			}
+		} else if initial := titleInitial(fatwa.Title); initial != "" {
+			counts[strings.ToUpper(initial)]++
+		}
+	}
+	if len(counts) == 0 {
+		fb.reply(chatID, "browse.empty")
+		return
+	}
+
+	values := make([]string, 0, len(counts))
+	for value := range counts {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+
+	message := fb.t(chatID, "browse.pick_letter")
+	prefix := "abjad_"
+	perRow := 6
+	if browseType == "year" {
+		// Newest years first
+		sort.Sort(sort.Reverse(sort.StringSlice(values)))
+		message = fb.t(chatID, "browse.pick_year")
+		prefix = "tahun_"
+		perRow = 4
+	}
+
+	var keyboard [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	for _, value := range values {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(
+			fmt.Sprintf("%s (%d)", value, counts[value]),
+			prefix+value,
+		))
+		if len(row) == perRow {
+			keyboard = append(keyboard, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		keyboard = append(keyboard, row)
+	}
+
+	msg := tgbotapi.NewMessage(chatID, message)
+	msg.ParseMode = tgbotapi.ModeHTML
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(keyboard...)
+	fb.send(msg)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+)
+
+// searchCacheSize is the number of queries whose results are kept, and
+// searchCacheTTL how long they stay valid. The TTL is short, as results
+// are cheap to recompute and the cache only needs to absorb bursts of the
+// same query.
+const (
+	searchCacheSize = 256
+	searchCacheTTL  = 5 * time.Minute
+)
+
+// cachedResult is a search result without its fatwa, which is looked up
+// again by position when the entry is used.
+type cachedResult struct {
+	Doc   int
+	Fuzzy bool
+	Score float64
+}
+
+type searchCacheEntry struct {
+	key     string
+	results []cachedResult
+	expires time.Time
+}
+
+// searchCache keeps the results of recent queries, evicting the least
+// recently used once full. It belongs to a search index, so a reloaded
+// dataset starts with an empty cache. The zero value is ready to use.
+type searchCache struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   list.List // most recently used first
+}
+
+func (c *searchCache) get(key string) ([]cachedResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*searchCacheEntry)
+	if time.Now().After(entry.expires) {
+		c.order.Remove(element)
+		delete(c.entries, key)
+		return nil, false
+	}
+
+	c.order.MoveToFront(element)
+	return entry.results, true
+}
+
+func (c *searchCache) add(key string, results []cachedResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries == nil {
+		c.entries = make(map[string]*list.Element)
+	}
+
+	entry := &searchCacheEntry{key: key, results: results, expires: time.Now().Add(searchCacheTTL)}
+	if element, ok := c.entries[key]; ok {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(entry)
+	if c.order.Len() > searchCacheSize {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*searchCacheEntry).key)
+	}
+}
+
+// searchCacheKey identifies a query regardless of spacing, case and
+// accents. Operators keep their case, as lowercase "dan" is a search word.
+// Regex patterns are used as typed.
+func searchCacheKey(query, searchType string) string {
+	if searchType == "regex" {
+		return searchType + "\x00" + query
+	}
+
+	words := strings.Fields(query)
+	for i, word := range words {
+		if queryOperators[word] == "" {
+			words[i] = foldText(word)
+		}
+	}
+	return searchType + "\x00" + strings.Join(words, " ")
+}
+
+// cachedSearch returns the results of a query from the index's cache, or
+// runs search and caches its results. Callers get their own copy to sort.
+func cachedSearch(fatwas []Fatwa, index *searchIndex, query, searchType string, search func() []searchResult) []searchResult {
+	key := searchCacheKey(query, searchType)
+
+	if cached, ok := index.results.get(key); ok {
+		results := make([]searchResult, len(cached))
+		for i, result := range cached {
+			results[i] = searchResult{Fatwa: fatwas[result.Doc], Doc: result.Doc, Fuzzy: result.Fuzzy, Score: result.Score}
+		}
+		return results
+	}
+
+	results := search()
+	cached := make([]cachedResult, len(results))
+	for i, result := range results {
+		cached[i] = cachedResult{Doc: result.Doc, Fuzzy: result.Fuzzy, Score: result.Score}
+	}
+	index.results.add(key, cached)
+	return results
+}
@@ -0,0 +1,390 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// callbackVersion starts callback data in the structured encoding, so the
+// encoding can change again while buttons in older messages stay readable.
+const callbackVersion = "1"
+
+// callbackData is what a button sends back when it is tapped. Telegram
+// limits it to 64 bytes. Buttons acting on a fatwa, a search, a query or a
+// category use the structured encoding "1|ACTION|SOURCE:ID|PAGE|HASH|ARG",
+// with empty fields at the end left out, such as "1|view|muftiwp:123" or
+// "1|page||20|9f3c1a2b". Buttons carrying a short value, such as a year or
+// an offset, send it plainly as "ACTION_VALUE", which is also how buttons
+// read before the structured encoding.
+type callbackData struct {
+	Action string
+	Key    FatwaKey // the fatwa acted on
+	Page   int      // a results offset or a part of a fatwa
+	Hash   string   // the search acted on, see searchSession.hash, or a query or category, see textHash
+	Arg    string   // anything else, such as a vote or a category
+}
+
+// String encodes data in the structured encoding.
+func (d callbackData) String() string {
+	fields := []string{callbackVersion, d.Action, "", "", d.Hash, d.Arg}
+	if d.Key != (FatwaKey{}) {
+		fields[2] = d.Key.String()
+	}
+	if d.Page != 0 {
+		fields[3] = strconv.Itoa(d.Page)
+	}
+	for len(fields) > 2 && fields[len(fields)-1] == "" {
+		fields = fields[:len(fields)-1]
+	}
+	return strings.Join(fields, "|")
+}
+
+// fatwaCallback is the callback data of a button acting on a fatwa.
+func fatwaCallback(action string, key FatwaKey) string {
+	return callbackData{Action: action, Key: key}.String()
+}
+
+// callbackText is a query carried by buttons, such as a suggested
+// spelling or a trending search, which may be too long for callback data.
+// Buttons carry its hash instead, and the text is kept for callbackTextTTL.
+type callbackText struct {
+	Text  string
+	Saved time.Time
+}
+
+// textHash identifies a query in callback data. It is longer than the
+// hash of a search, as texts of every chat share one map.
+func textHash(text string) string {
+	h := fnv.New64a()
+	h.Write([]byte(text))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// textCallback is the callback data of a button carrying a query, which is
+// kept so the button can find it again.
+func (fb *FatwaBot) textCallback(action, arg, text string) string {
+	hash := textHash(text)
+	fb.callbackTextsMu.Lock()
+	fb.callbackTexts[hash] = callbackText{Text: text, Saved: time.Now()}
+	fb.callbackTextsMu.Unlock()
+	return callbackData{Action: action, Hash: hash, Arg: arg}.String()
+}
+
+// callbackText returns the query a button carries by its hash, unless it
+// has expired.
+func (fb *FatwaBot) callbackText(hash string) (string, bool) {
+	fb.callbackTextsMu.Lock()
+	defer fb.callbackTextsMu.Unlock()
+
+	text, ok := fb.callbackTexts[hash]
+	if !ok || time.Since(text.Saved) > callbackTextTTL {
+		return "", false
+	}
+	return text.Text, true
+}
+
+// parseCallbackData decodes the data of a tapped button in either
+// encoding.
+func parseCallbackData(data string) (callbackData, error) {
+	rest, ok := strings.CutPrefix(data, callbackVersion+"|")
+	if !ok {
+		return parsePlainCallback(data)
+	}
+
+	fields := strings.SplitN(rest, "|", 5)
+	d := callbackData{Action: fields[0]}
+	if len(fields) > 1 && fields[1] != "" {
+		key, err := parseFatwaKey(fields[1])
+		if err != nil {
+			return callbackData{}, err
+		}
+		d.Key = key
+	}
+	if len(fields) > 2 && fields[2] != "" {
+		page, err := strconv.Atoi(fields[2])
+		if err != nil || page < 0 {
+			return callbackData{}, fmt.Errorf("invalid page %q", fields[2])
+		}
+		d.Page = page
+	}
+	if len(fields) > 3 {
+		d.Hash = fields[3]
+	}
+	if len(fields) > 4 {
+		d.Arg = fields[4]
+	}
+	return d, nil
+}
+
+// parsePlainCallback decodes "ACTION_VALUE" data. Buttons acting on a fatwa
+// sent it this way before the structured encoding, as "view_SOURCE:ID",
+// "part_SOURCE:ID_PART", "vote_up_SOURCE:ID" or "kuiz_SOURCE:ID_RULING",
+// and are still answered. So are buttons that carried their query as
+// "topic_QUERY", "trend_TYPE_QUERY" or "suggest_TYPE_QUERY".
+// Older page, sort and list buttons aren't: the searches they belong to
+// only last until the bot restarts.
+func parsePlainCallback(data string) (callbackData, error) {
+	action, value, _ := strings.Cut(data, "_")
+	d := callbackData{Action: action}
+
+	var err error
+	switch action {
+	case "view", "save", "unsave", "pdf", "tts", "translate", "summary", "report", "similar":
+		d.Key, err = parseFatwaKey(value)
+	case "part":
+		i := strings.LastIndex(value, "_")
+		if i < 0 {
+			return callbackData{}, fmt.Errorf("invalid part %q", value)
+		}
+		if d.Key, err = parseFatwaKey(value[:i]); err == nil {
+			d.Page, err = strconv.Atoi(value[i+1:])
+		}
+	case "vote":
+		d.Arg, value, _ = strings.Cut(value, "_")
+		d.Key, err = parseFatwaKey(value)
+	case "kuiz":
+		d.Arg = value
+		if i := strings.LastIndex(value, "_"); i >= 0 {
+			if d.Key, err = parseFatwaKey(value[:i]); err == nil {
+				d.Arg = value[i+1:]
+			}
+		}
+	default:
+		d.Arg = value
+	}
+	if err != nil {
+		return callbackData{}, err
+	}
+	return d, nil
+}
+
+func (fb *FatwaBot) handleCallbackQuery(callbackQuery *tgbotapi.CallbackQuery) {
+	chatID := callbackQuery.Message.Chat.ID
+	message := callbackQuery.Message
+
+	data, err := parseCallbackData(callbackQuery.Data)
+	if err != nil {
+		log.Printf("Error parsing callback %q: %v", callbackQuery.Data, err)
+		fb.reply(chatID, "error.fatwa_id")
+		fb.bot.Request(tgbotapi.NewCallback(callbackQuery.ID, ""))
+		return
+	}
+
+	switch data.Action {
+	// Show a fatwa, which may be gone since the button was sent; that
+	// answers the callback itself. Hash is the search it was found by,
+	// for results.
+	case "view":
+		fatwa, ok := fb.findFatwa(data.Key)
+		if !ok {
+			fb.staleFatwa(callbackQuery, data.Key)
+			return
+		}
+		text, keyboard := fb.detailsPart(chatID, fatwa, 1, data.Hash)
+		fb.replaceStatus(chatID, 0, text, &keyboard)
+
+	// Browse by year or title initial (format: "tahun_YEAR" or "abjad_LETTER")
+	case "tahun":
+		fb.browse(chatID, data.Arg, "year")
+	case "abjad":
+		fb.browse(chatID, data.Arg, "letter")
+
+	// Show a random fatwa, from a category unless Hash is empty, see
+	// categoryButtons, or page through the categories (format:
+	// "randpage_OFFSET")
+	case "rand":
+		categories, _ := fb.datasetCategories()
+		if category, ok := fb.callbackCategory(chatID, data, categories); ok {
+			fb.sendRandomFatwa(chatID, category)
+		}
+	case "randpage":
+		fb.showRandomCategoriesPage(message, data.Arg)
+
+	// Search a category, or page through them (format: "catpage_OFFSET"),
+	// or through the topics of a series, Hash being the series and Page
+	// the offset
+	case "cat":
+		categories, _ := fb.datasetCategories()
+		if category, ok := fb.callbackCategory(chatID, data, categories); ok {
+			fb.searchFatwas(chatID, category, "category")
+		}
+	case "catpage":
+		fb.showCategoriesPage(message, data.Arg)
+	case "catdir":
+		if data.Hash == "" {
+			// Older buttons sent "catdir_OFFSET_SERIES"
+			offsetStr, series, _ := strings.Cut(data.Arg, "_")
+			data.Page, _ = strconv.Atoi(offsetStr)
+			data.Hash = textHash(series)
+		}
+		fb.showCategorySeries(message, data.Hash, max(data.Page, 0))
+
+	// Subscribe to a category or stop, Hash being the category
+	case "sub":
+		categories, _ := fb.datasetCategories()
+		if category, ok := fb.callbackCategory(chatID, data, categories); ok {
+			fb.subscribe(chatID, category)
+		}
+	case "unsub":
+		// Subscriptions may be to categories that have since disappeared
+		subscriptions, err := fb.store.Subscriptions(chatID)
+		if err != nil {
+			log.Printf("Error reading subscriptions: %v", err)
+			fb.reply(chatID, "subscriptions.read_error")
+			break
+		}
+		if category, ok := fb.callbackCategory(chatID, data, subscriptions); ok {
+			fb.unsubscribe(chatID, category)
+		}
+
+	// Bookmark a fatwa or remove it
+	case "save":
+		fb.saveBookmark(chatID, data.Key.String())
+	case "unsave":
+		fb.removeBookmark(chatID, data.Key.String())
+
+	// Send a fatwa as a PDF document
+	case "pdf":
+		fb.sendFatwaPDF(chatID, data.Key.String())
+
+	// Send the whole of a long fatwa as a file
+	case "file":
+		fb.sendFatwaFile(chatID, data.Key.String())
+
+	// Read a fatwa aloud as voice notes
+	case "tts":
+		fb.sendFatwaAudio(chatID, data.Key.String())
+
+	// Translate a fatwa into English
+	case "translate":
+		fb.sendTranslation(chatID, data.Key.String())
+
+	// Summarize a fatwa with a chat model
+	case "summary":
+		fb.sendSummary(chatID, data.Key.String())
+
+	// Report a broken link or outdated fatwa
+	case "report":
+		fb.reportFatwa(chatID, data.Key.String())
+
+	// List fatwas with similar content
+	case "similar":
+		fb.sendSimilarFatwas(chatID, data.Key.String())
+
+	// Page through a long fatwa, Page being the part and Hash the search
+	// it was opened from
+	case "part":
+		fb.showDetailsPart(message, data.Key, data.Page, data.Hash)
+
+	// Move the /cari wizard on (format: "cari_type_TYPE", "cari_catpage_OFFSET"
+	// or "cari_cancel", or Arg "cat" with Hash the category)
+	case "cari":
+		fb.handleWizardButton(message, data.Arg, data.Hash)
+
+	// Page through search results, Page being the offset
+	case "page":
+		fb.showResultsPage(message, data.Hash, data.Page)
+
+	// Request a topic a search found nothing on, Hash being the query; the
+	// request is acknowledged in the answer
+	case "topic":
+		query, ok := data.Arg, true
+		if data.Hash != "" {
+			query, ok = fb.callbackText(data.Hash)
+		}
+		if !ok {
+			fb.reply(chatID, "search.expired")
+			break
+		}
+		fb.requestTopic(callbackQuery, query)
+		return
+
+	// Search again with a suggested spelling, Hash being the query and Arg
+	// the search type
+	case "suggest":
+		searchType, query, ok := data.Arg, "", true
+		if data.Hash != "" {
+			query, ok = fb.callbackText(data.Hash)
+		} else {
+			searchType, query, _ = strings.Cut(data.Arg, "_")
+		}
+		if !ok {
+			fb.reply(chatID, "search.expired")
+		} else if searchType == "keyword" || searchType == "title" {
+			fb.searchFatwas(chatID, query, searchType)
+		}
+
+	// Delete a saved search (format: "unalert_ALERT")
+	case "unalert":
+		fb.removeAlert(chatID, data.Arg)
+
+	// Run a trending search, Hash being the query and Arg the search type
+	case "trend":
+		searchType, query, ok := data.Arg, "", true
+		if data.Hash != "" {
+			query, ok = fb.callbackText(data.Hash)
+		} else if searchType, query, _ = strings.Cut(data.Arg, "_"); searchType != "keyword" && searchType != "title" {
+			// Buttons from before search types were kept were keyword searches
+			searchType, query = "keyword", data.Arg
+		}
+		if !ok {
+			fb.reply(chatID, "search.expired")
+		} else if searchType == "keyword" || searchType == "title" {
+			fb.searchFatwas(chatID, query, searchType)
+		}
+
+	// Run a search from the history again (format: "rerun_ENTRY")
+	case "rerun":
+		fb.rerunSearch(chatID, data.Arg)
+
+	// Download every result of a search
+	case "list":
+		fb.sendResultsList(chatID, data.Hash)
+
+	// Change the order of search results, Arg being the order
+	case "sort":
+		fb.sortResultsPage(message, data.Hash, data.Arg)
+
+	// Change the interface language (format: "lang_CODE")
+	case "lang":
+		fb.handleLanguage(chatID, data.Arg)
+
+	// Answer a quiz question on Key, Arg being the ruling picked, or ask
+	// another when Arg is "next"; the answer is acknowledged with its outcome
+	case "kuiz":
+		fb.answerQuiz(callbackQuery, data.Key, data.Arg)
+		return
+
+	// Vote on whether a fatwa helped, Arg being "up" or "down" and Hash the
+	// search it was opened from; the vote is acknowledged in the answer
+	case "vote":
+		fb.voteFatwa(callbackQuery, data.Key, data.Arg, data.Hash)
+		return
+
+	// Change a display preference (format: "set_NAME_OPTION")
+	case "set":
+		fb.changeSetting(message, data.Arg)
+
+	// Send an invoice for a donation in Stars, Arg being the amount
+	case "sokong":
+		fb.sendSupportInvoice(chatID, data.Arg)
+
+	// Send or cancel a prepared announcement (format: "broadcast_ACTION")
+	case "broadcast":
+		if !fb.isAdmin(callbackQuery.From) {
+			fb.reply(chatID, "admin_only")
+		} else {
+			fb.confirmBroadcast(chatID, data.Arg)
+		}
+	}
+
+	// Answer callback query
+	callback := tgbotapi.NewCallback(callbackQuery.ID, "")
+	fb.bot.Request(callback)
+}
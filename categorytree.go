@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// categoryNode is an entry of the top level of the category tree: a series
+// such as "Irsyad Hukum" with its topics, or a category of its own when it
+// has no series or is the only one in its series.
+type categoryNode struct {
+	Name     string
+	Category string   // set for a category of its own
+	Topics   []string // full category names, for a series
+	Count    int
+}
+
+// categoryTree groups sorted categories into series, in order of name.
+func categoryTree(categories []string, counts map[string]int) []categoryNode {
+	bySeries := make(map[string][]string)
+	var nodes []categoryNode
+	for _, category := range categories {
+		if series, topic := splitCategory(category); topic != "" {
+			bySeries[series] = append(bySeries[series], category)
+			continue
+		}
+		nodes = append(nodes, categoryNode{Name: category, Category: category, Count: counts[category]})
+	}
+
+	for series, topics := range bySeries {
+		if len(topics) == 1 {
+			category := topics[0]
+			nodes = append(nodes, categoryNode{Name: category, Category: category, Count: counts[category]})
+			continue
+		}
+		node := categoryNode{Name: series, Topics: topics}
+		for _, category := range topics {
+			node.Count += counts[category]
+		}
+		nodes = append(nodes, node)
+	}
+
+	sort.Slice(nodes, func(a, b int) bool { return nodes[a].Name < nodes[b].Name })
+	return nodes
+}
+
+// categoryNodeButtons are the buttons of the top level of the category
+// tree: series open their topics, and categories search themselves.
+func categoryNodeButtons(nodes []categoryNode) [][]tgbotapi.InlineKeyboardButton {
+	var keyboard [][]tgbotapi.InlineKeyboardButton
+	for _, node := range nodes {
+		if node.Category != "" {
+			keyboard = append(keyboard, categoryButtons([]string{node.Category}, map[string]int{node.Category: node.Count}, "📂", callbackData{Action: "cat"})...)
+			continue
+		}
+		keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(
+			fmt.Sprintf("📁 %s (%d) ›", node.Name, node.Count),
+			callbackData{Action: "catdir", Hash: textHash(node.Name)}.String(),
+		)))
+	}
+	return keyboard
+}
+
+// showCategorySeries replaces a categories message with the page of the
+// topics of a series starting at offset, the series given by its hash.
+func (fb *FatwaBot) showCategorySeries(message *tgbotapi.Message, hash string, offset int) {
+	chatID := message.Chat.ID
+
+	text, keyboard, ok := fb.categorySeriesPage(hash, offset, fb.language(chatID))
+	if !ok {
+		// The series is gone since the button was sent
+		text, keyboard = fb.categoriesPage(0, fb.language(chatID))
+	}
+	edit := tgbotapi.NewEditMessageTextAndMarkup(chatID, message.MessageID, text, keyboard)
+	edit.ParseMode = tgbotapi.ModeHTML
+	fb.send(edit)
+}
+
+// categorySeriesPage formats the page of a series' topics starting at
+// offset, under a breadcrumb, with a button back to the page of the
+// top level the series is on. The series is given by the hash of its name,
+// as series names can be longer than callback data.
+func (fb *FatwaBot) categorySeriesPage(hash string, offset int, lang string) (string, tgbotapi.InlineKeyboardMarkup, bool) {
+	categories, counts := fb.datasetCategories()
+	nodes := categoryTree(categories, counts)
+	position := -1
+	for i, node := range nodes {
+		if textHash(node.Name) == hash && node.Category == "" {
+			position = i
+			break
+		}
+	}
+	if position < 0 {
+		return "", tgbotapi.InlineKeyboardMarkup{}, false
+	}
+	series, topics := nodes[position].Name, nodes[position].Topics
+
+	if offset >= len(topics) {
+		offset = max(len(topics)-1, 0) / categoriesPerPage * categoriesPerPage
+	}
+	end := min(offset+categoriesPerPage, len(topics))
+
+	message := tr(lang, "categories.breadcrumb", series) + "\n\n"
+	if len(topics) > categoriesPerPage {
+		message += tr(lang, "categories.range", offset+1, end, len(topics)) + "\n\n"
+	}
+	message += tr(lang, "categories.pick")
+
+	var keyboard [][]tgbotapi.InlineKeyboardButton
+	for _, category := range topics[offset:end] {
+		_, topic := splitCategory(category)
+		keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(
+			fmt.Sprintf("📂 %s (%d)", topic, counts[category]),
+			callbackData{Action: "cat", Hash: textHash(category)}.String(),
+		)))
+	}
+
+	var navigation []tgbotapi.InlineKeyboardButton
+	if offset > 0 {
+		navigation = append(navigation, tgbotapi.NewInlineKeyboardButtonData(
+			tr(lang, "button.previous"),
+			callbackData{Action: "catdir", Page: max(offset-categoriesPerPage, 0), Hash: hash}.String(),
+		))
+	}
+	if end < len(topics) {
+		navigation = append(navigation, tgbotapi.NewInlineKeyboardButtonData(
+			tr(lang, "button.next"),
+			callbackData{Action: "catdir", Page: end, Hash: hash}.String(),
+		))
+	}
+	if len(navigation) > 0 {
+		keyboard = append(keyboard, navigation)
+	}
+	keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(
+		tr(lang, "button.categories_back"),
+		fmt.Sprintf("catpage_%d", position/categoriesPerPage*categoriesPerPage),
+	)))
+
+	return message, tgbotapi.InlineKeyboardMarkup{InlineKeyboard: keyboard}, true
+}
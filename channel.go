@@ -0,0 +1,75 @@
+package main
+
+import (
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// channelPostLimit caps the fatwas posted after one scrape, so a scrape
+// that adds a whole new source doesn't flood the channel; the rest are
+// summed up in a closing post. The outbox spaces the posts out.
+const channelPostLimit = 20
+
+// newFatwasChannel returns the channel every new fatwa is posted to, from
+// NEW_FATWAS_CHANNEL as "@username" or a numeric chat ID, if one is
+// configured.
+func newFatwasChannel() (string, bool) {
+	channel := strings.TrimSpace(os.Getenv("NEW_FATWAS_CHANNEL"))
+	return channel, channel != ""
+}
+
+// channelMessage addresses a message to a channel given as "@username" or
+// a numeric chat ID.
+func channelMessage(channel, text string) tgbotapi.MessageConfig {
+	var msg tgbotapi.MessageConfig
+	if id, err := strconv.ParseInt(channel, 10, 64); err == nil {
+		msg = tgbotapi.NewMessage(id, text)
+	} else {
+		msg = tgbotapi.NewMessageToChannel(channel, text)
+	}
+	msg.ParseMode = tgbotapi.ModeHTML
+	return msg
+}
+
+// postNewFatwas posts each fatwa a scrape added to the new fatwas channel,
+// oldest first, as a card with a button that opens it in the bot, so the
+// channel can be followed as a feed. Only the main bot posts.
+func (fb *FatwaBot) postNewFatwas(added map[FatwaKey]bool) {
+	channel, ok := newFatwasChannel()
+	if !ok || !fb.isMainBot() || len(added) == 0 {
+		return
+	}
+
+	var fatwas []Fatwa
+	for _, fatwa := range fb.dataset() {
+		if added[fatwa.Key()] {
+			fatwas = append(fatwas, fatwa)
+		}
+	}
+	sort.SliceStable(fatwas, func(a, b int) bool {
+		dateA, _ := parseFatwaDate(fatwas[a].Date)
+		dateB, _ := parseFatwaDate(fatwas[b].Date)
+		return dateA.Before(dateB)
+	})
+
+	// The newest are posted when there are too many
+	skipped := max(len(fatwas)-channelPostLimit, 0)
+	for _, fatwa := range fatwas[skipped:] {
+		msg := channelMessage(channel, tr(fb.lang, "channel.new")+"\n\n"+fb.fatwaCard(fatwa, fb.lang))
+		msg.ReplyMarkup = openInBotButton(fb.bot.Self.UserName, fatwa.Key(), fb.lang)
+		if _, err := fb.trySendBulk(msg); err != nil {
+			log.Printf("Error posting fatwa %s to %s: %v", fatwa.Key(), channel, err)
+		}
+	}
+
+	if skipped > 0 {
+		if _, err := fb.trySendBulk(channelMessage(channel, tr(fb.lang, "channel.more", skipped, fb.bot.Self.UserName))); err != nil {
+			log.Printf("Error posting to %s: %v", channel, err)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// errChecksumMismatch is returned when a dataset no longer matches the
+// checksum recorded when it was exported.
+var errChecksumMismatch = errors.New("dataset checksum mismatch")
+
+// checksumPath returns the manifest location for a dataset file. The
+// manifest uses the sha256sum format, so it can also be checked by hand
+// with `sha256sum -c`.
+func checksumPath(filename string) string {
+	return filename + ".sha256"
+}
+
+func writeChecksumManifest(filename, sum string) error {
+	manifest := checksumPath(filename)
+	content := fmt.Sprintf("%s  %s\n", sum, filepath.Base(filename))
+
+	tmp := manifest + ".tmp"
+	if err := os.WriteFile(tmp, []byte(content), 0644); err != nil {
+		return fmt.Errorf("cannot write checksum manifest: %v", err)
+	}
+	return os.Rename(tmp, manifest)
+}
+
+// verifyDatasetChecksum compares a dataset against its manifest. Datasets
+// exported before manifests existed have none and are accepted as-is.
+func verifyDatasetChecksum(filename string) error {
+	manifest, err := os.ReadFile(checksumPath(filename))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cannot read checksum manifest: %v", err)
+	}
+
+	fields := strings.Fields(string(manifest))
+	if len(fields) == 0 {
+		return fmt.Errorf("%w: empty manifest for %s", errChecksumMismatch, filename)
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("cannot open dataset: %v", err)
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return fmt.Errorf("cannot read dataset: %v", err)
+	}
+
+	if sum := hex.EncodeToString(hasher.Sum(nil)); sum != fields[0] {
+		return fmt.Errorf("%w: %s has sha256 %s, expected %s", errChecksumMismatch, filename, sum, fields[0])
+	}
+
+	return nil
+}
+
+// loadStartupDataset loads the dataset for the bot. If the dataset fails
+// its integrity check, the newest snapshot that passes is served instead.
+func loadStartupDataset() ([]Fatwa, *searchIndex, error) {
+	fatwas, index, err := loadIndexedDataset()
+	if err == nil || !errors.Is(err, errChecksumMismatch) {
+		return fatwas, index, err
+	}
+
+	log.Printf("Dataset failed integrity check: %v", err)
+
+	snapshots, serr := listSnapshots()
+	if serr != nil {
+		return nil, nil, err
+	}
+
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		if verr := verifyDatasetChecksum(snapshots[i]); verr != nil {
+			log.Printf("Skipping snapshot %s: %v", snapshots[i], verr)
+			continue
+		}
+
+		fatwas, lerr := loadFatwaData(snapshots[i])
+		if lerr != nil {
+			log.Printf("Skipping snapshot %s: %v", snapshots[i], lerr)
+			continue
+		}
+
+		log.Printf("Serving %d fatwas from snapshot %s instead", len(fatwas), snapshots[i])
+		return fatwas, buildSearchIndex(fatwas), nil
+	}
+
+	return nil, nil, fmt.Errorf("%v (and no valid snapshot to fall back to)", err)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"log"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// botCommands are the commands shown in Telegram's "/" menu, in order,
+// each described by the message "command.NAME". Commands that only work
+// with an argument, such as /search, are left out, since picking a
+// command from the menu sends it straight away. Add new commands here as
+// well as to handleMessage.
+var botCommands = []string{
+	"cari",
+	"categories",
+	"random",
+	"new",
+	"trending",
+	"kuiz",
+	"tahun",
+	"abjad",
+	"bookmarks",
+	"history",
+	"alert",
+	"subscribe",
+	"digest",
+	"fatwaharian",
+	"stats",
+	"settings",
+	"lang",
+	"menu",
+	"help",
+	"about",
+	"sokong",
+	"paysupport",
+}
+
+// adminCommands are added to the menu in the admins' own chats.
+var adminCommands = []string{
+	"admin",
+	"reload",
+	"users",
+	"activity",
+	"gaps",
+	"topics",
+	"feedback",
+	"broadcastpreview",
+	"broadcast",
+	"supporttext",
+	"refund",
+	"rollback",
+	"ban",
+	"unban",
+}
+
+// commandList returns commands with their descriptions in a language.
+func commandList(names []string, lang string) []tgbotapi.BotCommand {
+	commands := make([]tgbotapi.BotCommand, 0, len(names))
+	for _, name := range names {
+		commands = append(commands, tgbotapi.BotCommand{Command: name, Description: tr(lang, "command."+name)})
+	}
+	return commands
+}
+
+// registerCommands sets the "/" menu at startup, so it always matches the
+// commands of the running version and the bot's command set: in the bot's
+// default language, in each other interface language for Telegram users
+// with that language, and with the admin commands added in admins' chats,
+// in their chosen language.
+func (fb *FatwaBot) registerCommands() {
+	for _, lang := range languageCodes() {
+		config := tgbotapi.NewSetMyCommands(commandList(fb.menuCommands(), lang)...)
+		if lang != fb.lang {
+			config = tgbotapi.NewSetMyCommandsWithScopeAndLanguage(tgbotapi.NewBotCommandScopeDefault(), lang, config.Commands...)
+		}
+		if _, err := fb.bot.Request(config); err != nil {
+			log.Printf("Error registering %s commands: %v", lang, err)
+		}
+	}
+
+	for adminID := range fb.admins {
+		fb.registerAdminCommands(adminID)
+	}
+}
+
+// registerAdminCommands sets the menu of an admin's chat, in the admin's
+// language.
+func (fb *FatwaBot) registerAdminCommands(adminID int64) {
+	lang := fb.language(adminID)
+	commands := append(commandList(fb.menuCommands(), lang), commandList(adminCommands, lang)...)
+	config := tgbotapi.NewSetMyCommandsWithScope(tgbotapi.NewBotCommandScopeChat(adminID), commands...)
+	if _, err := fb.bot.Request(config); err != nil {
+		log.Printf("Error registering admin commands for %d: %v", adminID, err)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+const sealedPrefix = "enc:v1:"
+
+// fieldCipher encrypts individual user-data values before they are written
+// to the user store. Encryption is deterministic (the nonce is derived from
+// an HMAC of the plaintext) so that equal values still compare equal in SQL,
+// which keeps uniqueness constraints and lookups such as "subscribers of a
+// category" working. A nil *fieldCipher stores values in plain text.
+type fieldCipher struct {
+	aead    cipher.AEAD
+	macKey  []byte
+	chatKey []byte // keys the hashes identifying chats in usage data
+}
+
+// newFieldCipher builds a cipher from a base64-encoded 32-byte key. An
+// empty key disables encryption.
+func newFieldCipher(encodedKey string) (*fieldCipher, error) {
+	if encodedKey == "" {
+		return nil, nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("USER_DATA_KEY must be 32 bytes encoded as base64")
+	}
+
+	// Use separate subkeys for encryption and nonce derivation
+	block, err := aes.NewCipher(deriveKey(key, "encrypt"))
+	if err != nil {
+		return nil, fmt.Errorf("cannot create cipher: %v", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create GCM: %v", err)
+	}
+
+	return &fieldCipher{aead: aead, macKey: deriveKey(key, "nonce"), chatKey: deriveKey(key, "chat")}, nil
+}
+
+func deriveKey(key []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+func (c *fieldCipher) seal(value string) string {
+	if c == nil {
+		return value
+	}
+
+	mac := hmac.New(sha256.New, c.macKey)
+	mac.Write([]byte(value))
+	nonce := mac.Sum(nil)[:c.aead.NonceSize()]
+
+	sealed := c.aead.Seal(nonce, nonce, []byte(value), nil)
+	return sealedPrefix + base64.RawURLEncoding.EncodeToString(sealed)
+}
+
+// open decrypts a stored value. Values written before encryption was
+// enabled are returned unchanged.
+func (c *fieldCipher) open(value string) (string, error) {
+	if !strings.HasPrefix(value, sealedPrefix) {
+		return value, nil
+	}
+	if c == nil {
+		return "", fmt.Errorf("value is encrypted but USER_DATA_KEY is not set")
+	}
+
+	sealed, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(value, sealedPrefix))
+	if err != nil || len(sealed) < c.aead.NonceSize() {
+		return "", fmt.Errorf("malformed encrypted value")
+	}
+
+	nonce, ciphertext := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("cannot decrypt value: %v", err)
+	}
+	return string(plaintext), nil
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// defaultDailyFatwaHour is the hour the fatwa of the day is sent at,
+	// unless DAILY_FATWA_HOUR says otherwise, in the server's time zone.
+	defaultDailyFatwaHour = 7
+
+	// dailyFatwaRepeatAfter is how long a fatwa of the day isn't picked
+	// again, as long as there are others left to pick.
+	dailyFatwaRepeatAfter = 365 * 24 * time.Hour
+
+	// dailyFatwaPreview is the snippet radius of the fatwa of the day's
+	// preview, longer than that of search results.
+	dailyFatwaPreview = snippetRadius * 3
+)
+
+// dailyFatwaHour returns the hour the fatwa of the day is sent at.
+func dailyFatwaHour() int {
+	if hour, err := strconv.Atoi(os.Getenv("DAILY_FATWA_HOUR")); err == nil && hour >= 0 && hour <= 23 {
+		return hour
+	}
+	return defaultDailyFatwaHour
+}
+
+// dailyFatwaChannel returns the channel the fatwa of the day is also posted
+// to, from DAILY_FATWA_CHANNEL as "@username" or a numeric chat ID, if one
+// is configured.
+func dailyFatwaChannel() (string, bool) {
+	channel := strings.TrimSpace(os.Getenv("DAILY_FATWA_CHANNEL"))
+	return channel, channel != ""
+}
+
+// handleDailyFatwa opts a chat in with "/fatwaharian on" and out with
+// "/fatwaharian off", and otherwise shows today's fatwa and whether the
+// chat receives it.
+func (fb *FatwaBot) handleDailyFatwa(chatID int64, args string) {
+	switch strings.ToLower(strings.TrimSpace(args)) {
+	case "on", "mula":
+		if err := fb.store.SubscribeDailyFatwa(chatID); err != nil {
+			log.Printf("Error saving fatwa of the day: %v", err)
+			fb.reply(chatID, "daily.save_error")
+			return
+		}
+		fb.reply(chatID, "daily.started", dailyFatwaHour())
+	case "off", "henti":
+		if err := fb.store.UnsubscribeDailyFatwa(chatID); err != nil {
+			log.Printf("Error removing fatwa of the day: %v", err)
+			fb.reply(chatID, "daily.save_error")
+			return
+		}
+		fb.reply(chatID, "daily.stopped")
+	case "":
+		if fatwa, ok := fb.fatwaOfTheDay(time.Now()); ok {
+			if _, err := fb.trySend(fb.dailyFatwaTo(chatID, fatwa)); err != nil {
+				log.Printf("Error sending fatwa of the day: %v", err)
+			}
+		}
+
+		subscribed, err := fb.store.DailyFatwaSubscribed(chatID)
+		if err != nil {
+			log.Printf("Error reading fatwa of the day: %v", err)
+			fb.reply(chatID, "daily.read_error")
+			return
+		}
+		status := fb.t(chatID, "daily.off")
+		if subscribed {
+			status = fb.t(chatID, "daily.on", dailyFatwaHour())
+		}
+		fb.sendMessage(chatID, status+"\n\n"+fb.t(chatID, "daily.usage"))
+	default:
+		fb.reply(chatID, "daily.usage")
+	}
+}
+
+// fatwaOfTheDay returns the fatwa picked for a day, picking one at random
+// the first time it is asked for. Fatwas picked in the last
+// dailyFatwaRepeatAfter are skipped, unless every fatwa has been. Chats
+// asking at once may each pick one; the first saved is the day's.
+func (fb *FatwaBot) fatwaOfTheDay(now time.Time) (Fatwa, bool) {
+	day := now.Format(time.DateOnly)
+	if key, ok, err := fb.store.DailyFatwa(day); err != nil {
+		log.Printf("Error reading fatwa of the day: %v", err)
+	} else if ok {
+		if fatwa, ok := fb.findFatwa(key); ok {
+			return fatwa, true
+		}
+	}
+
+	picked, err := fb.store.DailyFatwasSince(now.Add(-dailyFatwaRepeatAfter).Format(time.DateOnly))
+	if err != nil {
+		log.Printf("Error reading fatwas of the day: %v", err)
+	}
+
+	var candidates, all []Fatwa
+	for _, fatwa := range fb.dataset() {
+		if strings.TrimSpace(fatwa.Content) == "" {
+			continue
+		}
+		all = append(all, fatwa)
+		if !picked[fatwa.Key()] {
+			candidates = append(candidates, fatwa)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = all
+	}
+	if len(candidates) == 0 {
+		return Fatwa{}, false
+	}
+
+	fatwa := candidates[rand.IntN(len(candidates))]
+	if err := fb.store.SetDailyFatwa(day, fatwa.Key()); err != nil {
+		log.Printf("Error saving fatwa of the day: %v", err)
+		return fatwa, true
+	}
+
+	// Another chat may have picked the day's fatwa first
+	if key, ok, err := fb.store.DailyFatwa(day); err != nil {
+		log.Printf("Error reading fatwa of the day: %v", err)
+	} else if ok && key != fatwa.Key() {
+		if saved, ok := fb.findFatwa(key); ok {
+			return saved, true
+		}
+	}
+	return fatwa, true
+}
+
+// dailyFatwaMessage formats the fatwa of the day with the start of its
+// content.
+func (fb *FatwaBot) dailyFatwaMessage(fatwa Fatwa, lang string) string {
+	return tr(lang, "daily.title") + "\n\n" + fb.fatwaCard(fatwa, lang)
+}
+
+// fatwaCard formats a fatwa for a post that stands on its own, such as in
+// a channel: its title, date, views and category, and the start of its
+// content.
+func (fb *FatwaBot) fatwaCard(fatwa Fatwa, lang string) string {
+	_, index := fb.searchData()
+	card := fmt.Sprintf("📖 <b>%s</b>\n", html.EscapeString(fatwa.Title))
+	card += tr(lang, "results.stats", fatwa.Date, fatwa.Hits) + "\n"
+	card += tr(lang, "details.category", fatwa.Category) + "\n\n"
+	card += newHighlighter("", "keyword", index).snippet(fatwa.Content, dailyFatwaPreview)
+	return card
+}
+
+// dailyFatwaTo addresses the fatwa of the day to a chat, with a button to
+// read it in full.
+func (fb *FatwaBot) dailyFatwaTo(chatID int64, fatwa Fatwa) tgbotapi.MessageConfig {
+	lang := fb.language(chatID)
+	msg := tgbotapi.NewMessage(chatID, fb.dailyFatwaMessage(fatwa, lang))
+	msg.ParseMode = tgbotapi.ModeHTML
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(tr(lang, "button.read_full"), fatwaCallback("view", fatwa.Key())),
+	))
+	return msg
+}
+
+// postDailyFatwa sends the fatwa of the day to every chat that opted in
+// and to the configured channel. It runs daily from cron.
+func (fb *FatwaBot) postDailyFatwa() {
+	fatwa, ok := fb.fatwaOfTheDay(time.Now())
+	if !ok {
+		return
+	}
+
+	chatIDs, err := fb.store.DailyFatwaChats()
+	if err != nil {
+		log.Printf("Error reading fatwa of the day chats: %v", err)
+	}
+	for _, chatID := range chatIDs {
+		if _, err := fb.trySendBulk(fb.dailyFatwaTo(chatID, fatwa)); err != nil {
+			log.Printf("Error sending fatwa of the day to %d: %v", chatID, err)
+		}
+	}
+
+	// Buttons in a channel can't open the fatwa in the channel, so its
+	// post links to the bot instead; only the main bot posts it
+	if channel, ok := dailyFatwaChannel(); ok && fb.isMainBot() {
+		msg := channelMessage(channel, fb.dailyFatwaMessage(fatwa, fb.lang))
+		msg.ReplyMarkup = openInBotButton(fb.bot.Self.UserName, fatwa.Key(), fb.lang)
+		if _, err := fb.trySendBulk(msg); err != nil {
+			log.Printf("Error posting fatwa of the day to %s: %v", channel, err)
+		}
+	}
+}
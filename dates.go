@@ -0,0 +1,59 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// malayMonths maps Malay month names and abbreviations to English ones so
+// the dates shown on the source site can be parsed with time.Parse.
+var malayMonths = strings.NewReplacer(
+	"januari", "january",
+	"februari", "february",
+	"mac", "march",
+	"mei", "may",
+	"julai", "july",
+	"ogos", "august",
+	"ogo", "aug",
+	"oktober", "october",
+	"okt", "oct",
+	"disember", "december",
+	"dis", "dec",
+)
+
+var fatwaDateLayouts = []string{
+	"2 January 2006",
+	"2 Jan 2006",
+	"January 2, 2006",
+	"Jan 2, 2006",
+	"2006-01-02",
+	"2006-01-02 15:04:05",
+	"02/01/2006",
+	"2/1/2006",
+	"02-01-2006",
+}
+
+// parseFatwaDate parses the publication date scraped from the site. It
+// accepts Malay or English month names and an optional leading weekday
+// such as "Isnin, 12 Jun 2023".
+func parseFatwaDate(value string) (time.Time, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "" {
+		return time.Time{}, false
+	}
+
+	// Drop a leading weekday name
+	if i := strings.Index(value, ","); i >= 0 && i < 12 && !strings.ContainsAny(value[:i], "0123456789") {
+		value = strings.TrimSpace(value[i+1:])
+	}
+
+	value = strings.Join(strings.Fields(malayMonths.Replace(value)), " ")
+
+	for _, layout := range fatwaDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// Schema changes live in NNNN_description.sql files and are applied in
+// order, each exactly once, whenever a database is opened. The dataset and
+// the user store have their own sets, so neither holds the other's tables.
+// Both sets once shared one directory, so existing databases have recorded
+// versions up to 0018 whichever set they belong to: new migrations in
+// either set take numbers after the highest in both.
+//
+//go:embed migrations/dataset/*.sql
+var datasetMigrationFiles embed.FS
+
+//go:embed migrations/users/*.sql
+var userMigrationFiles embed.FS
+
+// migrationSet is a directory of migrations for one kind of database.
+type migrationSet struct {
+	files embed.FS
+	dir   string
+}
+
+var (
+	datasetMigrations = migrationSet{datasetMigrationFiles, "migrations/dataset"}
+	userMigrations    = migrationSet{userMigrationFiles, "migrations/users"}
+)
+
+// databasePath returns the SQLite database location used by the migrate
+// command when DATABASE_PATH is not set.
+func databasePath() string {
+	if path := os.Getenv("DATABASE_PATH"); path != "" {
+		return path
+	}
+	return "fatwa.db"
+}
+
+func openDatabase(path string, migrations migrationSet) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, fmt.Errorf("cannot open database: %v", err)
+	}
+
+	if err := applyMigrations(db, migrations); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// applyMigrations runs every migration of a set that has not yet been
+// recorded in the schema_migrations table.
+func applyMigrations(db *sql.DB, migrations migrationSet) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TEXT NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("cannot create schema_migrations table: %v", err)
+	}
+
+	entries, err := migrations.files.ReadDir(migrations.dir)
+	if err != nil {
+		return fmt.Errorf("cannot read migrations: %v", err)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	for _, entry := range entries {
+		name := entry.Name()
+		prefix, _, _ := strings.Cut(name, "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return fmt.Errorf("invalid migration name %q", name)
+		}
+
+		var applied int
+		err = db.QueryRow("SELECT COUNT(*) FROM schema_migrations WHERE version = ?", version).Scan(&applied)
+		if err != nil {
+			return fmt.Errorf("cannot check migration %s: %v", name, err)
+		}
+		if applied > 0 {
+			continue
+		}
+
+		script, err := migrations.files.ReadFile(migrations.dir + "/" + name)
+		if err != nil {
+			return fmt.Errorf("cannot read migration %s: %v", name, err)
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("cannot begin migration %s: %v", name, err)
+		}
+		if _, err := tx.Exec(string(script)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("error applying migration %s: %v", name, err)
+		}
+		if _, err := tx.Exec("INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)", version, time.Now().UTC().Format(time.RFC3339)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("error recording migration %s: %v", name, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("cannot commit migration %s: %v", name, err)
+		}
+	}
+
+	return nil
+}
+
+func loadFatwasFromDB(db *sql.DB) ([]Fatwa, error) {
+	rows, err := db.Query("SELECT source, id, title, url, date, hits, category, content FROM fatwas ORDER BY rowid")
+	if err != nil {
+		return nil, fmt.Errorf("cannot query fatwas: %v", err)
+	}
+	defer rows.Close()
+
+	var fatwas []Fatwa
+	for rows.Next() {
+		var fatwa Fatwa
+		err := rows.Scan(&fatwa.Source, &fatwa.ID, &fatwa.Title, &fatwa.URL, &fatwa.Date, &fatwa.Hits, &fatwa.Category, &fatwa.Content)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read fatwa row: %v", err)
+		}
+		fatwas = append(fatwas, fatwa)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read fatwas: %v", err)
+	}
+
+	if len(fatwas) == 0 {
+		return nil, fmt.Errorf("database contains no fatwas")
+	}
+
+	return fatwas, nil
+}
+
+// saveFatwasToDB replaces the stored dataset with the given fatwas in a
+// single transaction.
+func saveFatwasToDB(db *sql.DB, fatwas []Fatwa) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("cannot begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM fatwas"); err != nil {
+		return fmt.Errorf("cannot clear fatwas: %v", err)
+	}
+
+	stmt, err := tx.Prepare("INSERT OR REPLACE INTO fatwas (source, id, title, url, date, hits, category, content) VALUES (?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return fmt.Errorf("cannot prepare insert: %v", err)
+	}
+	defer stmt.Close()
+
+	for _, fatwa := range fatwas {
+		source := fatwa.Source
+		if source == "" {
+			source = defaultSource
+		}
+		_, err := stmt.Exec(source, fatwa.ID, fatwa.Title, fatwa.URL, fatwa.Date, fatwa.Hits, fatwa.Category, fatwa.Content)
+		if err != nil {
+			return fmt.Errorf("cannot insert fatwa %s: %v", fatwa.Key(), err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("cannot commit fatwas: %v", err)
+	}
+
+	return nil
+}
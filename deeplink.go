@@ -0,0 +1,65 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// startPayloadPrefix starts the /start payload of links that open a fatwa.
+const startPayloadPrefix = "fatwa_"
+
+// fatwaStartPayload returns the /start payload that opens a fatwa:
+// "fatwa_123" for the default source and "fatwa_SOURCE_123" for others,
+// as payloads may only contain letters, digits, "_" and "-".
+func fatwaStartPayload(key FatwaKey) string {
+	if key.Source == defaultSource {
+		return startPayloadPrefix + strconv.Itoa(key.ID)
+	}
+	return startPayloadPrefix + key.Source + "_" + strconv.Itoa(key.ID)
+}
+
+// parseStartPayload returns the fatwa a /start payload opens.
+func parseStartPayload(payload string) (FatwaKey, bool) {
+	value, ok := strings.CutPrefix(payload, startPayloadPrefix)
+	if !ok {
+		return FatwaKey{}, false
+	}
+
+	source := defaultSource
+	if i := strings.LastIndex(value, "_"); i >= 0 {
+		source, value = value[:i], value[i+1:]
+	}
+	id, err := strconv.Atoi(value)
+	if err != nil || source == "" {
+		return FatwaKey{}, false
+	}
+	return FatwaKey{Source: source, ID: id}, true
+}
+
+// fatwaDeepLink returns a t.me link that opens the bot on a fatwa, for
+// sharing, channel posts and QR codes.
+func fatwaDeepLink(botName string, key FatwaKey) string {
+	return "https://t.me/" + botName + "?start=" + fatwaStartPayload(key)
+}
+
+// handleStart welcomes a user, or shows the fatwa a deep link was for.
+// Links to fatwas no longer in the dataset fall back to the welcome.
+func (fb *FatwaBot) handleStart(chatID int64, payload string) {
+	if key, ok := parseStartPayload(strings.TrimSpace(payload)); ok {
+		if fatwa, ok := fb.findFatwa(key); ok {
+			fb.sendFatwaDetails(chatID, fatwa)
+			return
+		}
+	}
+	fb.sendWelcomeMessage(chatID)
+}
+
+// openInBotButton is a button under a shared fatwa card that opens the bot
+// on the full fatwa.
+func openInBotButton(botName string, key FatwaKey, lang string) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonURL(tr(lang, "button.open_in_bot"), fatwaDeepLink(botName, key)),
+	))
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Digest frequencies, as typed after /digest.
+const (
+	digestDaily  = "harian"
+	digestWeekly = "mingguan"
+)
+
+// defaultDigestHour is the hour digests are sent at unless a chat picks
+// another one, in the server's time zone.
+const defaultDigestHour = 8
+
+// maxDigestFatwas is the number of new, and of trending, fatwas listed in
+// a digest.
+const maxDigestFatwas = 5
+
+// digestName returns the message ID of the name of a digest frequency.
+func digestName(frequency string) string {
+	if frequency == digestWeekly {
+		return "digest.weekly"
+	}
+	return "digest.daily"
+}
+
+// digestPeriod returns the time a digest covers.
+func digestPeriod(frequency string) time.Duration {
+	if frequency == digestWeekly {
+		return 7 * 24 * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// handleDigest sets up the digest from "/digest harian|mingguan [hour]",
+// stops it with "/digest off", and shows the current setting without
+// arguments.
+func (fb *FatwaBot) handleDigest(chatID int64, args string) {
+	fields := strings.Fields(strings.ToLower(args))
+	if len(fields) == 0 {
+		fb.showDigest(chatID)
+		return
+	}
+
+	if fields[0] == "off" || fields[0] == "henti" {
+		if err := fb.store.RemoveDigest(chatID); err != nil {
+			log.Printf("Error removing digest: %v", err)
+			fb.reply(chatID, "digest.stop_error")
+			return
+		}
+		fb.reply(chatID, "digest.stopped")
+		return
+	}
+
+	frequency := fields[0]
+	if frequency != digestDaily && frequency != digestWeekly {
+		fb.reply(chatID, "digest.bad_frequency")
+		return
+	}
+
+	hour := defaultDigestHour
+	if len(fields) > 1 {
+		value, err := strconv.Atoi(strings.TrimSuffix(fields[1], ":00"))
+		if err != nil || value < 0 || value > 23 {
+			fb.reply(chatID, "digest.bad_hour")
+			return
+		}
+		hour = value
+	}
+
+	if err := fb.store.SetDigest(chatID, frequency, hour); err != nil {
+		log.Printf("Error saving digest: %v", err)
+		fb.reply(chatID, "digest.save_error")
+		return
+	}
+
+	fb.reply(chatID, "digest.saved", fb.t(chatID, digestName(frequency)), hour)
+}
+
+func (fb *FatwaBot) showDigest(chatID int64) {
+	digest, ok, err := fb.store.Digest(chatID)
+	if err != nil {
+		log.Printf("Error reading digest: %v", err)
+		fb.reply(chatID, "digest.read_error")
+		return
+	}
+
+	status := fb.t(chatID, "digest.off")
+	if ok {
+		status = fb.t(chatID, "digest.on", fb.t(chatID, digestName(digest.Frequency)), digest.Hour)
+	}
+	fb.sendMessage(chatID, fb.t(chatID, "digest.about")+"\n\n"+status+"\n\n"+fb.t(chatID, "digest.usage"))
+}
+
+// sendDigests sends the digest to every chat whose hour has come and whose
+// last digest is a full period ago. It runs hourly from cron.
+func (fb *FatwaBot) sendDigests() {
+	digests, err := fb.store.Digests()
+	if err != nil {
+		log.Printf("Error reading digests: %v", err)
+		return
+	}
+
+	now := time.Now()
+	for _, digest := range digests {
+		period := digestPeriod(digest.Frequency)
+		// Allow for cron firing a little early or late
+		if now.Hour() != digest.Hour || (!digest.LastSent.IsZero() && now.Sub(digest.LastSent) < period-time.Hour) {
+			continue
+		}
+
+		since := now.Add(-period)
+		if !digest.LastSent.IsZero() && digest.LastSent.Before(since) {
+			since = digest.LastSent
+		}
+
+		if message, keyboard, ok := fb.digestMessage(digest.Frequency, since, fb.language(digest.ChatID)); ok {
+			msg := tgbotapi.NewMessage(digest.ChatID, message)
+			msg.ParseMode = tgbotapi.ModeHTML
+			msg.ReplyMarkup = keyboard
+			if _, err := fb.trySendBulk(msg); err != nil {
+				log.Printf("Error sending digest to %d: %v", digest.ChatID, err)
+				continue
+			}
+		}
+
+		if err := fb.store.MarkDigestSent(digest.ChatID); err != nil {
+			log.Printf("Error recording digest: %v", err)
+		}
+	}
+}
+
+// digestMessage summarizes the fatwas published since a time and the top
+// result of each of the period's most searched queries. It reports false
+// if there is nothing to tell.
+func (fb *FatwaBot) digestMessage(frequency string, since time.Time, lang string) (string, tgbotapi.InlineKeyboardMarkup, bool) {
+	fatwas, index := fb.searchData()
+
+	// Publication dates have no time of day, so the whole first day counts
+	start := time.Date(since.Year(), since.Month(), since.Day(), 0, 0, 0, 0, time.UTC)
+	var added []Fatwa
+	for _, fatwa := range fatwas {
+		if date, ok := parseFatwaDate(fatwa.Date); ok && !date.Before(start) {
+			added = append(added, fatwa)
+		}
+	}
+	sort.SliceStable(added, func(a, b int) bool {
+		dateA, _ := parseFatwaDate(added[a].Date)
+		dateB, _ := parseFatwaDate(added[b].Date)
+		return dateA.After(dateB)
+	})
+
+	queries, err := fb.store.TopSearches(since, maxDigestFatwas*2)
+	if err != nil {
+		log.Printf("Error reading top searches: %v", err)
+	}
+	var trending []Fatwa
+	trendingQueries := make(map[FatwaKey]string)
+	for _, query := range queries {
+		if len(trending) == maxDigestFatwas {
+			break
+		}
+		results := runSearch(fatwas, index, query, "keyword")
+		if len(results) == 0 || results[0].Fuzzy {
+			continue
+		}
+		top := results[0].Fatwa
+		if _, ok := trendingQueries[top.Key()]; ok {
+			continue
+		}
+		trendingQueries[top.Key()] = query
+		trending = append(trending, top)
+	}
+
+	if len(added) == 0 && len(trending) == 0 {
+		return "", tgbotapi.InlineKeyboardMarkup{}, false
+	}
+
+	message := tr(lang, "digest.title", tr(lang, digestName(frequency))) + "\n\n"
+	var keyboard [][]tgbotapi.InlineKeyboardButton
+	number := 0
+	addFatwa := func(fatwa Fatwa, note string) {
+		number++
+		message += fmt.Sprintf("%d. %s%s\n", number, html.EscapeString(fatwa.Title), note)
+		keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(tr(lang, "button.read_fatwa", number), fatwaCallback("view", fatwa.Key())),
+		))
+	}
+
+	if len(added) > 0 {
+		message += tr(lang, "digest.new") + "\n"
+		for _, fatwa := range added[:min(len(added), maxDigestFatwas)] {
+			addFatwa(fatwa, fmt.Sprintf(" (%s)", html.EscapeString(fatwa.Date)))
+		}
+		if len(added) > maxDigestFatwas {
+			message += tr(lang, "digest.more", len(added)-maxDigestFatwas) + "\n"
+		}
+		message += "\n"
+	}
+
+	if len(trending) > 0 {
+		message += tr(lang, "digest.trending") + "\n"
+		for _, fatwa := range trending {
+			addFatwa(fatwa, tr(lang, "digest.searched", trendingQueries[fatwa.Key()]))
+		}
+	}
+
+	return message, tgbotapi.NewInlineKeyboardMarkup(keyboard...), true
+}
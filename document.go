@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// fileMinParts is how many parts a fatwa must be read in before its
+// details offer to send the whole of it as a file instead.
+const fileMinParts = 4
+
+// fileStyle lays out a fatwa sent as a file for reading on a phone, with
+// Arabic passages right to left and a size larger.
+const fileStyle = `body { font-family: Georgia, serif; line-height: 1.6; max-width: 40em; margin: 2em auto; padding: 0 1em; color: #222; }
+h1 { font-size: 1.5em; line-height: 1.3; }
+h2 { font-size: 1.1em; margin-top: 1.5em; }
+.meta { color: #666; font-size: 0.9em; }
+[lang=ar] { font-family: "Amiri", "Scheherazade New", serif; font-size: 1.3em; }
+a { color: #0645ad; }`
+
+// fileButton is the "📎 Send as file" button under the parts of a long
+// fatwa.
+func fileButton(key FatwaKey, lang string) tgbotapi.InlineKeyboardButton {
+	return tgbotapi.NewInlineKeyboardButtonData(tr(lang, "button.file"), fatwaCallback("file", key))
+}
+
+// sendFatwaFile sends the whole of a fatwa as an HTML file, given its ID,
+// for reading a long fatwa in one go rather than part by part. Unlike the
+// PDF, the file keeps the vowel marks of the Arabic passages.
+func (fb *FatwaBot) sendFatwaFile(chatID int64, idStr string) {
+	key, err := parseFatwaKey(idStr)
+	if err != nil {
+		fb.reply(chatID, "id.invalid")
+		return
+	}
+
+	fatwa, ok := fb.findFatwa(key)
+	if !ok {
+		fb.reply(chatID, "id.not_found", key)
+		return
+	}
+
+	fb.bot.Request(tgbotapi.NewChatAction(chatID, tgbotapi.ChatUploadDocument))
+
+	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{
+		Name:  fmt.Sprintf("fatwa-%s-%d.html", fatwa.Source, fatwa.ID),
+		Bytes: []byte(fatwaHTML(fatwa, fb.language(chatID))),
+	})
+	doc.Caption = fmt.Sprintf("📎 <b>%s</b>", html.EscapeString(fatwa.Title))
+	doc.ParseMode = tgbotapi.ModeHTML
+	if _, err := fb.trySend(doc); err != nil {
+		log.Printf("Error sending fatwa file: %v", err)
+		fb.reply(chatID, "file.error")
+	}
+}
+
+// fatwaHTML renders a fatwa as a standalone web page: the title, its
+// details, the content in paragraphs as in the PDF and a link to the
+// source. Labels are in the reader's language.
+func fatwaHTML(fatwa Fatwa, lang string) string {
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<html lang=\"ms\">\n<head>\n<meta charset=\"utf-8\">\n")
+	b.WriteString("<meta name=\"viewport\" content=\"width=device-width, initial-scale=1\">\n")
+	fmt.Fprintf(&b, "<title>%s</title>\n<style>\n%s\n</style>\n</head>\n<body>\n", html.EscapeString(fatwa.Title), fileStyle)
+
+	fmt.Fprintf(&b, "<h1>%s</h1>\n<p class=\"meta\">\n", html.EscapeString(fatwa.Title))
+	meta := []string{
+		tr(lang, "details.id", fatwa.Key()),
+		tr(lang, "details.date", fatwa.Date),
+		tr(lang, "details.category", fatwa.Category),
+		tr(lang, "details.views", fatwa.Hits),
+	}
+	b.WriteString(strings.Join(meta, "<br>\n"))
+	b.WriteString("\n</p>\n<hr>\n")
+
+	for _, paragraph := range pdfParagraphs(fatwa.Content) {
+		if heading := pdfHeading.FindStringIndex(paragraph); heading != nil && heading[0] == 0 {
+			fmt.Fprintf(&b, "<h2>%s</h2>\n", html.EscapeString(paragraph[:heading[1]]))
+			paragraph = strings.TrimSpace(paragraph[heading[1]:])
+		}
+		if paragraph != "" {
+			fmt.Fprintf(&b, "<p>%s</p>\n", markArabic(html.EscapeString(paragraph)))
+		}
+	}
+
+	if fatwa.URL != "" {
+		fmt.Fprintf(&b, "<hr>\n<p>%s</p>\n", tr(lang, "details.link", fatwa.URL))
+	}
+	b.WriteString("</body>\n</html>\n")
+	return b.String()
+}
+
+// markArabic marks the Arabic passages of escaped text, so they are laid
+// out right to left within the Malay text around them.
+func markArabic(text string) string {
+	return arabicRun.ReplaceAllStringFunc(text, func(run string) string {
+		return `<span lang="ar" dir="rtl">` + run + `</span>`
+	})
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+const (
+	// sessionTTL is how long a search's page, sort and list buttons keep
+	// working after they were last tapped.
+	sessionTTL = time.Hour
+
+	// callbackTextTTL is how long buttons carrying a query, such as
+	// suggested spellings and trending searches, keep working.
+	callbackTextTTL = 24 * time.Hour
+
+	// languageTTL is how long a chat's language is kept in memory before
+	// it is read from the store again.
+	languageTTL = time.Hour
+
+	// sweepInterval is how often expired per-chat state is forgotten, so
+	// memory and the conversations table only hold the chats in use rather
+	// than every chat the bot has seen.
+	sweepInterval = 10 * time.Minute
+)
+
+// sweepState forgets the per-chat state that has expired: searches unused
+// for sessionTTL, queries buttons carried past callbackTextTTL, languages
+// read more than languageTTL ago, wizard steps
+// left unanswered for longer than wizardTimeout, pages of inline results
+// past inlineCacheTTL and the outbox's pacing of chats it has finished
+// with. Lookups already ignore expired state; this only frees it.
+func (fb *FatwaBot) sweepState() {
+	now := time.Now()
+
+	fb.sessionsMu.Lock()
+	sessions := 0
+	for chatID, session := range fb.sessions {
+		if now.Sub(session.Used) > sessionTTL {
+			delete(fb.sessions, chatID)
+			sessions++
+		}
+	}
+	fb.sessionsMu.Unlock()
+
+	fb.callbackTextsMu.Lock()
+	for hash, text := range fb.callbackTexts {
+		if now.Sub(text.Saved) > callbackTextTTL {
+			delete(fb.callbackTexts, hash)
+		}
+	}
+	fb.callbackTextsMu.Unlock()
+
+	fb.languagesMu.Lock()
+	languages := 0
+	for chatID, cached := range fb.languages {
+		if now.Sub(cached.Loaded) > languageTTL {
+			delete(fb.languages, chatID)
+			languages++
+		}
+	}
+	fb.languagesMu.Unlock()
+
+	fb.outbox.sweep(now)
+	pages := fb.inline.sweep(now)
+
+	conversations, err := fb.store.PruneConversations(now.Add(-wizardTimeout))
+	if err != nil {
+		log.Printf("Error pruning conversations: %v", err)
+	}
+
+	if sessions > 0 || languages > 0 || pages > 0 || conversations > 0 {
+		log.Printf("Forgot %d expired searches, %d languages, %d inline pages and %d wizard steps", sessions, languages, pages, conversations)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// parquetFatwa is the row layout of the Parquet export. Dates and
+// categories are parsed so the file can be analysed directly in pandas or
+// DuckDB without further cleaning.
+type parquetFatwa struct {
+	Source         string     `parquet:"source,dict"`
+	ID             int64      `parquet:"id"`
+	Title          string     `parquet:"title,zstd"`
+	URL            string     `parquet:"url,zstd"`
+	Date           string     `parquet:"date"`
+	PublishedAt    *time.Time `parquet:"published_at,optional"`
+	Year           *int32     `parquet:"year,optional"`
+	Hits           int64      `parquet:"hits"`
+	Category       string     `parquet:"category,dict"`
+	CategorySeries string     `parquet:"category_series,dict"`
+	CategoryTopic  string     `parquet:"category_topic,dict"`
+	Content        string     `parquet:"content,zstd"`
+	ContentLength  int64      `parquet:"content_length"`
+}
+
+// runExport writes the current dataset to a file in the requested format.
+func runExport(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	format := flags.String("format", "parquet", "output format: parquet, csv or csv.gz")
+	output := flags.String("o", "", "output file (default fatwa.<format>)")
+	flags.Parse(args)
+
+	if *output == "" {
+		*output = "fatwa." + *format
+	}
+
+	fatwas, err := loadDataset()
+	if err != nil {
+		return fmt.Errorf("cannot load dataset: %v", err)
+	}
+
+	switch *format {
+	case "parquet":
+		err = exportToParquet(fatwas, *output)
+	case "csv", "csv.gz":
+		err = exportToCSV(fatwas, *output)
+	default:
+		return fmt.Errorf("unknown export format %q", *format)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Exported %d fatwas to %s\n", len(fatwas), *output)
+	return nil
+}
+
+func exportToParquet(fatwas []Fatwa, filename string) error {
+	rows := make([]parquetFatwa, 0, len(fatwas))
+
+	for _, fatwa := range fatwas {
+		series, topic := splitCategory(fatwa.Category)
+		row := parquetFatwa{
+			Source:         fatwa.Source,
+			ID:             int64(fatwa.ID),
+			Title:          fatwa.Title,
+			URL:            fatwa.URL,
+			Date:           fatwa.Date,
+			Hits:           int64(fatwa.Hits),
+			Category:       fatwa.Category,
+			CategorySeries: series,
+			CategoryTopic:  topic,
+			Content:        fatwa.Content,
+			ContentLength:  int64(len([]rune(fatwa.Content))),
+		}
+
+		if published, ok := parseFatwaDate(fatwa.Date); ok {
+			year := int32(published.Year())
+			row.PublishedAt = &published
+			row.Year = &year
+		}
+
+		rows = append(rows, row)
+	}
+
+	if err := parquet.WriteFile(filename, rows); err != nil {
+		return fmt.Errorf("error writing Parquet file: %v", err)
+	}
+
+	return nil
+}
+
+// splitCategory splits a category such as "Irsyad Hukum - Umum" into its
+// series and topic parts.
+func splitCategory(category string) (series, topic string) {
+	series, topic, found := strings.Cut(category, " - ")
+	if !found {
+		return strings.TrimSpace(category), ""
+	}
+	return strings.TrimSpace(series), strings.TrimSpace(topic)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// feedbackPeriod and feedbackShown bound the unhelpful results report.
+	feedbackPeriod = 30 * 24 * time.Hour
+	feedbackShown  = 20
+)
+
+// feedbackButtons ask whether a fatwa answered the question it was found
+// for, search being the hash of that search, if any.
+func feedbackButtons(key FatwaKey, search string) []tgbotapi.InlineKeyboardButton {
+	return []tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardButtonData("👍", callbackData{Action: "vote", Key: key, Hash: search, Arg: "up"}.String()),
+		tgbotapi.NewInlineKeyboardButtonData("👎", callbackData{Action: "vote", Key: key, Hash: search, Arg: "down"}.String()),
+	}
+}
+
+// voteFatwa records a 👍 or 👎 on a fatwa, given as "up" or "down",
+// against the keyword or title search it was opened from, so the report
+// shows which searches lead to unhelpful results. search is the hash of
+// that search, which must still be the chat's latest for its query to be
+// known. Fatwas reached any other way are recorded without a query. The
+// vote is acknowledged in the callback answer, which it sends itself.
+func (fb *FatwaBot) voteFatwa(callbackQuery *tgbotapi.CallbackQuery, key FatwaKey, vote, search string) {
+	chatID := callbackQuery.Message.Chat.ID
+	lang := fb.language(chatID)
+
+	answer := func(id string) {
+		if _, err := fb.bot.Request(tgbotapi.NewCallback(callbackQuery.ID, htmlToText(tr(lang, id)))); err != nil {
+			log.Printf("Error answering feedback: %v", err)
+		}
+	}
+
+	if vote != "up" && vote != "down" {
+		answer("id.invalid")
+		return
+	}
+
+	var query string
+	session, ok := fb.searchSession(chatID)
+	if ok && search != "" && session.hash() == search && (session.Type == "keyword" || session.Type == "title") {
+		query = session.Query
+	}
+
+	if err := fb.store.AddFeedback(chatID, key, query, vote == "up"); err != nil {
+		log.Printf("Error saving feedback: %v", err)
+		answer("feedback.error")
+		return
+	}
+	answer("feedback.thanks")
+}
+
+// showFeedback lists the searches whose results were voted unhelpful over
+// the last month, with the fatwa voted on, pointing at rankings and
+// synonyms to tune.
+func (fb *FatwaBot) showFeedback(chatID int64) {
+	counts, err := fb.store.UnhelpfulResults(time.Now().Add(-feedbackPeriod), feedbackShown)
+	if err != nil {
+		log.Printf("Error reading feedback: %v", err)
+		fb.reply(chatID, "analytics.error")
+		return
+	}
+	if len(counts) == 0 {
+		fb.reply(chatID, "feedback.none")
+		return
+	}
+
+	message := fb.t(chatID, "feedback.report") + "\n\n"
+	for i, count := range counts {
+		query := fb.t(chatID, "feedback.no_query")
+		if count.Query != "" {
+			query = fmt.Sprintf("<code>%s</code>", html.EscapeString(count.Query))
+		}
+		title := count.Key.String()
+		if fatwa, ok := fb.findFatwa(count.Key); ok {
+			title = fatwa.Title
+		}
+		message += fmt.Sprintf("%d. %s → %s (%s) 👍 %d 👎 %d\n", i+1, query,
+			html.EscapeString(title), count.Key, count.Helpful, count.Unhelpful)
+	}
+	fb.sendMessage(chatID, message)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// normalizeSearchText prepares text for matching: case-folded, without
+// accents, and with Arabic normalized. It is applied to indexed fields
+// when the dataset is loaded and to queries, so searches never have to
+// transform the corpus.
+func normalizeSearchText(text string) string {
+	return normalizeArabic(foldText(text))
+}
+
+// foldText folds case and strips combining marks, so "Ṣalāh", "SALAH" and
+// "salah" compare equal. Decomposing first separates accents from their
+// letters, whether the text stored them precomposed or not.
+func foldText(text string) string {
+	isASCII := true
+	for i := 0; i < len(text); i++ {
+		if text[i] >= utf8.RuneSelf {
+			isASCII = false
+			break
+		}
+	}
+	if isASCII {
+		return strings.ToLower(text)
+	}
+
+	var folded strings.Builder
+	folded.Grow(len(text))
+	for _, r := range norm.NFD.String(text) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		folded.WriteRune(foldRune(r))
+	}
+	return norm.NFC.String(folded.String())
+}
+
+// foldRune maps a rune to a single representative of its case-folding
+// orbit, lowercased. Unlike unicode.ToLower this also unifies runes such as
+// the final sigma "ς" with "σ" and the long s "ſ" with "s", as
+// strings.EqualFold does.
+func foldRune(r rune) rune {
+	smallest := r
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		if f < smallest {
+			smallest = f
+		}
+	}
+	return unicode.ToLower(smallest)
+}
@@ -0,0 +1,40 @@
+package main
+
+import "strings"
+
+// groupResultsPerPage is the number of search results shown per message in
+// group chats, where long result lists would drown the conversation.
+const groupResultsPerPage = 5
+
+// groupExcerptLength is how much of a fatwa's content is shown in group
+// chats, which link to the rest instead of sending several messages.
+const groupExcerptLength = 1000
+
+// isGroupChat reports whether a chat is a group or supergroup, which
+// Telegram gives negative IDs.
+func isGroupChat(chatID int64) bool {
+	return chatID < 0
+}
+
+// commandText removes the bot's username from a command, so
+// "/search@ApaHukumBot zakat" is handled as "/search zakat". In groups with
+// several bots, commands are addressed to one of them; ok is false for a
+// command meant for another bot.
+func commandText(text, botName string) (command string, ok bool) {
+	if !strings.HasPrefix(text, "/") {
+		return text, true
+	}
+
+	end := strings.IndexAny(text, " \n")
+	if end < 0 {
+		end = len(text)
+	}
+	name, addressee, found := strings.Cut(text[:end], "@")
+	if !found {
+		return text, true
+	}
+	if !strings.EqualFold(addressee, botName) {
+		return "", false
+	}
+	return name + text[end:], true
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"html"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// FatwaVersion is a previous revision of a fatwa's content, archived when
+// the scraper notices that the article on the source site has changed.
+type FatwaVersion struct {
+	Key        FatwaKey
+	Title      string
+	Content    string
+	ReplacedAt time.Time
+}
+
+func loadFatwaHistory(filename string) (map[FatwaKey][]FatwaVersion, error) {
+	history := make(map[FatwaKey][]FatwaVersion)
+
+	file, err := os.Open(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return history, nil
+		}
+		return nil, fmt.Errorf("cannot open history file: %v", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read history file: %v", err)
+	}
+
+	// Skip header row
+	for i := 1; i < len(records); i++ {
+		record := records[i]
+		if len(record) < 4 {
+			continue // Skip invalid records
+		}
+
+		id, err := strconv.Atoi(record[0])
+		if err != nil {
+			continue
+		}
+		replacedAt, _ := time.Parse(time.RFC3339, record[1])
+
+		key := FatwaKey{Source: defaultSource, ID: id}
+		if len(record) > 4 && record[4] != "" {
+			key.Source = record[4]
+		}
+
+		history[key] = append(history[key], FatwaVersion{
+			Key:        key,
+			Title:      record[2],
+			Content:    record[3],
+			ReplacedAt: replacedAt,
+		})
+	}
+
+	// Keep each fatwa's versions oldest first
+	for key := range history {
+		versions := history[key]
+		sort.SliceStable(versions, func(a, b int) bool {
+			return versions[a].ReplacedAt.Before(versions[b].ReplacedAt)
+		})
+	}
+
+	return history, nil
+}
+
+// recordContentChanges compares a freshly scraped dataset against the
+// previous one and appends the old content of every changed fatwa to the
+// history file. It returns the number of versions archived.
+func recordContentChanges(previous, current []Fatwa, filename string) (int, error) {
+	old := make(map[FatwaKey]Fatwa, len(previous))
+	for _, fatwa := range previous {
+		if fatwa.ID != 0 {
+			old[fatwa.Key()] = fatwa
+		}
+	}
+
+	var changed []Fatwa
+	for _, fatwa := range current {
+		prev, exists := old[fatwa.Key()]
+		if !exists || prev.Content == fatwa.Content {
+			continue
+		}
+		// A failed extraction is not a real change on the source site
+		if fatwa.Content == "Error extracting content" || prev.Content == "Error extracting content" || prev.Content == "" {
+			continue
+		}
+		changed = append(changed, prev)
+	}
+
+	if len(changed) == 0 {
+		return 0, nil
+	}
+
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return 0, fmt.Errorf("cannot open history file: %v", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("cannot stat history file: %v", err)
+	}
+
+	writer := csv.NewWriter(file)
+
+	if info.Size() == 0 {
+		header := []string{"ID", "ReplacedAt", "Title", "Content", "Source"}
+		if err := writer.Write(header); err != nil {
+			return 0, fmt.Errorf("error writing history header: %v", err)
+		}
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	for _, fatwa := range changed {
+		record := []string{
+			strconv.Itoa(fatwa.ID),
+			now,
+			fatwa.Title,
+			fatwa.Content,
+			fatwa.Source,
+		}
+		if err := writer.Write(record); err != nil {
+			return 0, fmt.Errorf("error writing history record: %v", err)
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return 0, fmt.Errorf("error flushing history file: %v", err)
+	}
+
+	return len(changed), nil
+}
+
+// lastUpdated reports when the content of a fatwa was last replaced.
+func (fb *FatwaBot) lastUpdated(key FatwaKey) (time.Time, bool) {
+	versions := fb.contentVersions(key)
+	if len(versions) == 0 {
+		return time.Time{}, false
+	}
+	return versions[len(versions)-1].ReplacedAt, true
+}
+
+func (fb *FatwaBot) showContentHistory(chatID int64, idStr string) {
+	key, err := parseFatwaKey(idStr)
+	if err != nil {
+		fb.reply(chatID, "content_history.bad_id")
+		return
+	}
+
+	versions := fb.contentVersions(key)
+	if len(versions) == 0 {
+		fb.reply(chatID, "content_history.none", key)
+		return
+	}
+
+	message := fb.t(chatID, "content_history.title", key) + "\n\n"
+
+	// Show the most recent versions first
+	const maxVersions = 10
+	shown := 0
+	for i := len(versions) - 1; i >= 0 && shown < maxVersions; i-- {
+		version := versions[i]
+
+		preview := plainExcerpt(version.Content, 200)
+
+		message += fb.t(chatID, "content_history.version", i+1, version.ReplacedAt.Format("02/01/2006 15:04")) + "\n"
+		message += fmt.Sprintf("📄 %s\n\n", html.EscapeString(preview))
+		shown++
+	}
+
+	if len(versions) > maxVersions {
+		message += fb.t(chatID, "content_history.shown", maxVersions, len(versions))
+	}
+
+	fb.sendMessage(chatID, message)
+}
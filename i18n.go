@@ -0,0 +1,298 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// langMalay is the default interface language, and the fallback for
+// messages missing in another language.
+const langMalay = "ms"
+
+// The bot's strings live in locales/LANG.json, one file per interface
+// language, mapping message IDs to text. A message may also be a list of
+// variants to try different copy; each chat is always shown the same one.
+//
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+// localesPath returns the directory of locale files that override the
+// built-in ones.
+func localesPath() string {
+	if path := os.Getenv("LOCALES_DIR"); path != "" {
+		return path
+	}
+	return "locales"
+}
+
+// messageText is a message in a locale file: a string, or a list of
+// variants.
+type messageText []string
+
+func (m *messageText) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*m = messageText{text}
+		return nil
+	}
+
+	var variants []string
+	if err := json.Unmarshal(data, &variants); err != nil || len(variants) == 0 {
+		return fmt.Errorf("message must be a string or a non-empty list of strings")
+	}
+	*m = variants
+	return nil
+}
+
+// messageCatalog loads the messages on first use. Locale files in the
+// locales directory are optional; their messages replace the built-in ones
+// of the same ID, and a file for a new language adds that language, so
+// wording can be changed without a rebuild.
+var messageCatalog = sync.OnceValue(func() map[string]map[string]messageText {
+	catalog := make(map[string]map[string]messageText)
+
+	entries, err := localeFiles.ReadDir("locales")
+	if err != nil {
+		log.Fatalf("Error reading built-in locales: %v", err)
+	}
+	for _, entry := range entries {
+		data, err := localeFiles.ReadFile("locales/" + entry.Name())
+		if err != nil {
+			log.Fatalf("Error reading built-in locale %s: %v", entry.Name(), err)
+		}
+		if err := mergeLocale(catalog, entry.Name(), data); err != nil {
+			log.Fatalf("Error loading built-in locale %s: %v", entry.Name(), err)
+		}
+	}
+
+	entries, err = os.ReadDir(localesPath())
+	if err != nil && !os.IsNotExist(err) {
+		log.Printf("Error reading locales: %v", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(localesPath(), entry.Name()))
+		if err == nil {
+			err = mergeLocale(catalog, entry.Name(), data)
+		}
+		if err != nil {
+			log.Printf("Error loading locale %s: %v", entry.Name(), err)
+		}
+	}
+
+	return catalog
+})
+
+// mergeLocale adds the messages of a locale file to the catalog. Message
+// IDs unknown to the default language are most likely typos, and ignored.
+func mergeLocale(catalog map[string]map[string]messageText, name string, data []byte) error {
+	var messages map[string]messageText
+	if err := json.Unmarshal(data, &messages); err != nil {
+		return err
+	}
+
+	lang := strings.TrimSuffix(name, ".json")
+	if catalog[lang] == nil {
+		catalog[lang] = make(map[string]messageText, len(messages))
+	}
+	for id, text := range messages {
+		if lang != langMalay && catalog[langMalay] != nil {
+			if _, ok := catalog[langMalay][id]; !ok {
+				log.Printf("Ignoring unknown message %q in locale %s", id, name)
+				continue
+			}
+		}
+		catalog[lang][id] = text
+	}
+	return nil
+}
+
+func isLanguage(code string) bool {
+	_, ok := messageCatalog()[code]
+	return ok
+}
+
+// languageCodes returns the interface languages in the order /lang offers
+// them, the default first.
+func languageCodes() []string {
+	var codes []string
+	for code := range messageCatalog() {
+		if code != langMalay {
+			codes = append(codes, code)
+		}
+	}
+	sort.Strings(codes)
+	return append([]string{langMalay}, codes...)
+}
+
+// tr returns the message with the given ID in a language, formatted with
+// args. Messages are HTML, and text among args is escaped to match.
+// Messages with variants give their first variant.
+func tr(lang, id string, args ...any) string {
+	return trVariant(lang, id, 0, args...)
+}
+
+// trVariant returns one variant of a message; variant may be any number,
+// such as a chat ID, and is reduced to the number of variants.
+func trVariant(lang, id string, variant int64, args ...any) string {
+	catalog := messageCatalog()
+	text, ok := catalog[lang][id]
+	if !ok {
+		text, ok = catalog[langMalay][id]
+	}
+	if !ok {
+		log.Printf("Missing message %q", id)
+		return id
+	}
+
+	chosen := text[uint64(variant)%uint64(len(text))]
+	if len(args) > 0 {
+		return fmt.Sprintf(chosen, escapeArgs(args)...)
+	}
+	return chosen
+}
+
+// escapeArgs escapes the text among a message's arguments for Telegram's
+// HTML mode, so titles, queries and errors can't break the message.
+func escapeArgs(args []any) []any {
+	escaped := make([]any, len(args))
+	for i, arg := range args {
+		switch value := arg.(type) {
+		case string:
+			escaped[i] = html.EscapeString(value)
+		case error:
+			escaped[i] = html.EscapeString(value.Error())
+		case fmt.Stringer:
+			escaped[i] = html.EscapeString(value.String())
+		default:
+			escaped[i] = arg
+		}
+	}
+	return escaped
+}
+
+// cachedLanguage is a chat's language as read from the store at Loaded.
+type cachedLanguage struct {
+	Lang   string
+	Loaded time.Time
+}
+
+// language returns the interface language of a chat. Preferences are read
+// from the store and then kept in memory for languageTTL.
+func (fb *FatwaBot) language(chatID int64) string {
+	fb.languagesMu.Lock()
+	cached, ok := fb.languages[chatID]
+	fb.languagesMu.Unlock()
+	if ok && time.Since(cached.Loaded) <= languageTTL {
+		return cached.Lang
+	}
+
+	lang, err := fb.store.Language(chatID)
+	if err != nil {
+		log.Printf("Error reading language: %v", err)
+	}
+	if !isLanguage(lang) {
+		lang = fb.lang
+	}
+
+	fb.languagesMu.Lock()
+	fb.languages[chatID] = cachedLanguage{Lang: lang, Loaded: time.Now()}
+	fb.languagesMu.Unlock()
+	return lang
+}
+
+// telegramLanguage returns the interface language matching a Telegram
+// language code, such as "en-GB", if there is one.
+func telegramLanguage(code string) (string, bool) {
+	base, _, _ := strings.Cut(strings.ToLower(code), "-")
+	if !isLanguage(base) {
+		return "", false
+	}
+	return base, true
+}
+
+// adoptTelegramLanguage starts a new private chat in the language of the
+// user's Telegram app, so English users get English menus on first contact
+// without finding /lang. Groups keep the bot's language, as their members
+// may not share one.
+func (fb *FatwaBot) adoptTelegramLanguage(chatID int64, user *tgbotapi.User) {
+	if user == nil || user.ID != chatID {
+		return
+	}
+	lang, ok := telegramLanguage(user.LanguageCode)
+	if !ok || lang == fb.lang {
+		return
+	}
+
+	if err := fb.store.SetLanguage(chatID, lang); err != nil {
+		log.Printf("Error saving language: %v", err)
+		return
+	}
+	fb.languagesMu.Lock()
+	fb.languages[chatID] = cachedLanguage{Lang: lang, Loaded: time.Now()}
+	fb.languagesMu.Unlock()
+}
+
+// t returns a message in the language of a chat, in the chat's variant.
+func (fb *FatwaBot) t(chatID int64, id string, args ...any) string {
+	return trVariant(fb.language(chatID), id, chatID, args...)
+}
+
+// reply sends a message to a chat in its language.
+func (fb *FatwaBot) reply(chatID int64, id string, args ...any) {
+	fb.sendMessage(chatID, fb.t(chatID, id, args...))
+}
+
+// handleLanguage changes the interface language with "/lang en|ms", or
+// offers the languages as buttons without an argument.
+func (fb *FatwaBot) handleLanguage(chatID int64, code string) {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if code == "" {
+		var row []tgbotapi.InlineKeyboardButton
+		for _, language := range languageCodes() {
+			row = append(row, tgbotapi.NewInlineKeyboardButtonData(tr(language, "lang.name"), "lang_"+language))
+		}
+
+		msg := tgbotapi.NewMessage(chatID, fb.t(chatID, "lang.prompt"))
+		msg.ParseMode = tgbotapi.ModeHTML
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(row)
+		fb.send(msg)
+		return
+	}
+
+	if !isLanguage(code) {
+		fb.reply(chatID, "lang.invalid", strings.Join(languageCodes(), ", "))
+		return
+	}
+
+	if err := fb.store.SetLanguage(chatID, code); err != nil {
+		log.Printf("Error saving language: %v", err)
+		fb.reply(chatID, "lang.error")
+		return
+	}
+
+	fb.languagesMu.Lock()
+	fb.languages[chatID] = cachedLanguage{Lang: code, Loaded: time.Now()}
+	fb.languagesMu.Unlock()
+
+	// Admins' command menus are in their own language
+	if fb.admins[chatID] {
+		fb.registerAdminCommands(chatID)
+	}
+
+	// Resend the main menu so its labels follow the new language
+	fb.sendMenu(chatID, "lang.changed")
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// importFields are the dataset fields an imported column can be mapped to.
+var importFields = []string{"id", "title", "url", "date", "hits", "category", "content", "source"}
+
+// runImport merges a CSV or JSON dataset produced elsewhere into the local
+// dataset, deduplicating on the (source, id) key and the canonical URL.
+func runImport(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	file := flags.String("file", "", "CSV or JSON dataset to import")
+	format := flags.String("format", "", "input format: csv or json (default: from file extension)")
+	mapping := flags.String("map", "", `column mapping, e.g. "title=Tajuk,url=Pautan,date=Tarikh"`)
+	source := flags.String("source", defaultSource, "source name for rows without a source column")
+	dryRun := flags.Bool("dry-run", false, "report what would change without saving")
+	flags.Parse(args)
+
+	if *file == "" {
+		return fmt.Errorf("import: -file is required")
+	}
+	if *format == "" {
+		*format = "csv"
+		if strings.HasSuffix(strings.ToLower(strings.TrimSuffix(*file, ".gz")), ".json") {
+			*format = "json"
+		}
+	}
+
+	columns, err := parseFieldMapping(*mapping)
+	if err != nil {
+		return err
+	}
+
+	var rows []map[string]string
+	switch *format {
+	case "csv":
+		rows, err = readImportCSV(*file)
+	case "json":
+		rows, err = readImportJSON(*file)
+	default:
+		return fmt.Errorf("unknown import format %q", *format)
+	}
+	if err != nil {
+		return err
+	}
+
+	incoming, skipped := mapImportRows(rows, columns, *source)
+
+	existing, err := loadDataset()
+	if err != nil {
+		return fmt.Errorf("cannot load local dataset: %v", err)
+	}
+
+	merged, added, updated, unchanged := mergeFatwas(existing, incoming)
+
+	fmt.Printf("Import summary for %s:\n", *file)
+	fmt.Printf("  added:     %d\n", added)
+	fmt.Printf("  updated:   %d\n", updated)
+	fmt.Printf("  unchanged: %d\n", unchanged)
+	fmt.Printf("  skipped:   %d\n", len(skipped))
+	for _, reason := range skipped {
+		fmt.Printf("    %s\n", reason)
+	}
+
+	if *dryRun || added+updated == 0 {
+		return nil
+	}
+
+	// Keep the old content of updated fatwas, as the scraper does
+	if _, err := recordContentChanges(existing, merged, "fatwa_history.csv"); err != nil {
+		return err
+	}
+
+	return saveDataset(merged)
+}
+
+// parseFieldMapping parses "field=column" pairs. Fields that aren't
+// mapped are read from a column of the same name.
+func parseFieldMapping(value string) (map[string]string, error) {
+	columns := make(map[string]string)
+	for _, field := range importFields {
+		columns[field] = field
+	}
+
+	for _, part := range strings.Split(value, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		field, column, found := strings.Cut(part, "=")
+		field = strings.ToLower(strings.TrimSpace(field))
+		if !found {
+			return nil, fmt.Errorf("invalid mapping %q, expected field=column", part)
+		}
+		if _, known := columns[field]; !known {
+			return nil, fmt.Errorf("unknown field %q in mapping (fields: %s)", field, strings.Join(importFields, ", "))
+		}
+		columns[field] = strings.TrimSpace(column)
+	}
+
+	return columns, nil
+}
+
+// readImportCSV reads a CSV file with a header row into column→value maps.
+// Column names are matched case-insensitively.
+func readImportCSV(filename string) ([]map[string]string, error) {
+	input, err := openDataset(filename)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open import file: %v", err)
+	}
+	defer input.Close()
+
+	reader := csv.NewReader(input)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read import header: %v", err)
+	}
+
+	var rows []map[string]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot read import file: %v", err)
+		}
+
+		row := make(map[string]string, len(header))
+		for i, column := range header {
+			if i < len(record) {
+				row[strings.ToLower(strings.TrimSpace(column))] = record[i]
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// readImportJSON reads a JSON array of objects. Non-string values such as
+// numeric IDs are converted to their text form.
+func readImportJSON(filename string) ([]map[string]string, error) {
+	input, err := openDataset(filename)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open import file: %v", err)
+	}
+	defer input.Close()
+
+	var objects []map[string]any
+	decoder := json.NewDecoder(input)
+	decoder.UseNumber()
+	if err := decoder.Decode(&objects); err != nil {
+		return nil, fmt.Errorf("cannot parse import JSON: %v", err)
+	}
+
+	rows := make([]map[string]string, 0, len(objects))
+	for _, object := range objects {
+		row := make(map[string]string, len(object))
+		for key, value := range object {
+			if value == nil {
+				continue
+			}
+			if text, ok := value.(string); ok {
+				row[strings.ToLower(key)] = text
+			} else {
+				row[strings.ToLower(key)] = fmt.Sprint(value)
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// mapImportRows converts raw rows into fatwas, returning a reason for
+// every row that had to be skipped.
+func mapImportRows(rows []map[string]string, columns map[string]string, source string) ([]Fatwa, []string) {
+	idPattern := regexp.MustCompile(`/(\d+)-`)
+
+	var fatwas []Fatwa
+	var skipped []string
+
+	for i, row := range rows {
+		get := func(field string) string {
+			return strings.TrimSpace(row[strings.ToLower(columns[field])])
+		}
+
+		fatwa := Fatwa{
+			Title:    get("title"),
+			URL:      get("url"),
+			Date:     get("date"),
+			Category: get("category"),
+			Content:  get("content"),
+			Source:   get("source"),
+		}
+		if fatwa.Source == "" {
+			fatwa.Source = source
+		}
+		if fatwa.URL != "" {
+			fatwa.URL = canonicalURL(fatwa.URL)
+		}
+		fatwa.Hits, _ = strconv.Atoi(get("hits"))
+
+		// Fall back to the ID embedded in article URLs, as the scraper does
+		id, err := strconv.Atoi(get("id"))
+		if err != nil {
+			if matches := idPattern.FindStringSubmatch(fatwa.URL); len(matches) > 1 {
+				id, err = strconv.Atoi(matches[1])
+			}
+		}
+		if err != nil || id <= 0 {
+			skipped = append(skipped, fmt.Sprintf("row %d: no valid ID", i+1))
+			continue
+		}
+		fatwa.ID = id
+
+		if fatwa.Title == "" || fatwa.URL == "" {
+			skipped = append(skipped, fmt.Sprintf("row %d: missing title or URL", i+1))
+			continue
+		}
+
+		fatwas = append(fatwas, fatwa)
+	}
+
+	return fatwas, skipped
+}
+
+// mergeFatwas merges incoming fatwas into the existing dataset. A fatwa is
+// considered the same record when its key or its canonical URL matches.
+func mergeFatwas(existing, incoming []Fatwa) (merged []Fatwa, added, updated, unchanged int) {
+	merged = append([]Fatwa(nil), existing...)
+
+	byKey := make(map[FatwaKey]int, len(merged))
+	byURL := make(map[string]int, len(merged))
+	for i, fatwa := range merged {
+		byKey[fatwa.Key()] = i
+		byURL[canonicalURL(fatwa.URL)] = i
+	}
+
+	for _, fatwa := range incoming {
+		i, found := byKey[fatwa.Key()]
+		if !found {
+			i, found = byURL[fatwa.URL]
+		}
+
+		if !found {
+			byKey[fatwa.Key()] = len(merged)
+			byURL[fatwa.URL] = len(merged)
+			merged = append(merged, fatwa)
+			added++
+			continue
+		}
+
+		// Keep the local identity of records matched by URL, and any
+		// fields the imported dataset leaves empty
+		current := merged[i]
+		fatwa.ID, fatwa.Source = current.ID, current.Source
+		if fatwa.Date == "" {
+			fatwa.Date = current.Date
+		}
+		if fatwa.Hits == 0 {
+			fatwa.Hits = current.Hits
+		}
+		if fatwa.Category == "" {
+			fatwa.Category = current.Category
+		}
+		if fatwa.Content == "" {
+			fatwa.Content = current.Content
+		}
+
+		if fatwa == current {
+			unchanged++
+			continue
+		}
+		merged[i] = fatwa
+		updated++
+	}
+
+	return merged, added, updated, unchanged
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/gob"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// searchIndex holds precomputed, normalized copies of the searchable fields
+// so queries don't have to lowercase the whole corpus on every message.
+// Entries are parallel to the fatwa slice they were built from.
+// Vocabulary lists every distinct word, for typo-tolerant matching.
+// The inverted index maps words to the documents containing them, and
+// word roots to the titles and contents they occur in, so different forms
+// of a word match each other. Category roots only weigh in the ranking of
+// matches. ContentLengths counts the words of each content, for relevance
+// ranking. Results of recent queries are cached with the index they were
+// found in.
+type searchIndex struct {
+	Titles         []string
+	Contents       []string
+	Categories     []string
+	Vocabulary     []string
+	WordDocs       map[string][]int
+	TitleRoots     map[string][]posting
+	ContentRoots   map[string][]posting
+	CategoryRoots  map[string][]posting
+	ContentLengths []int
+
+	stemmerOnce sync.Once
+	stemmer     *malayStemmer
+	jawiOnce    sync.Once
+	jawiWords   map[string]string
+	deletesOnce sync.Once
+	deletes     map[string][]string
+	results     searchCache
+}
+
+func buildSearchIndex(fatwas []Fatwa) *searchIndex {
+	index := &searchIndex{
+		Titles:         make([]string, len(fatwas)),
+		Contents:       make([]string, len(fatwas)),
+		Categories:     make([]string, len(fatwas)),
+		WordDocs:       make(map[string][]int),
+		TitleRoots:     make(map[string][]posting),
+		ContentRoots:   make(map[string][]posting),
+		CategoryRoots:  make(map[string][]posting),
+		ContentLengths: make([]int, len(fatwas)),
+	}
+
+	titleWords := make([][]string, len(fatwas))
+	contentWords := make([][]string, len(fatwas))
+	categoryWords := make([][]string, len(fatwas))
+	for i, fatwa := range fatwas {
+		index.Titles[i] = normalizeSearchText(fatwa.Title)
+		index.Contents[i] = normalizeSearchText(fatwa.Content)
+		index.Categories[i] = normalizeSearchText(fatwa.Category)
+
+		titleWords[i] = tokenize(index.Titles[i])
+		contentWords[i] = tokenize(index.Contents[i])
+		categoryWords[i] = tokenize(index.Categories[i])
+		for _, word := range titleWords[i] {
+			addDoc(index.WordDocs, word, i)
+		}
+		for _, word := range contentWords[i] {
+			addDoc(index.WordDocs, word, i)
+		}
+		index.ContentLengths[i] = len(contentWords[i])
+	}
+
+	index.Vocabulary = make([]string, 0, len(index.WordDocs))
+	for word := range index.WordDocs {
+		index.Vocabulary = append(index.Vocabulary, word)
+	}
+	sort.Strings(index.Vocabulary)
+
+	// Roots are looked up in the finished vocabulary, so they can only be
+	// worked out once every document has been read
+	stemmer := index.malayStemmer()
+	roots := make(map[string]string, len(index.Vocabulary))
+	for _, word := range index.Vocabulary {
+		roots[word] = stemmer.stem(word)
+	}
+	for i := range fatwas {
+		for _, word := range titleWords[i] {
+			addPosting(index.TitleRoots, roots[word], i)
+		}
+		for _, word := range contentWords[i] {
+			addPosting(index.ContentRoots, roots[word], i)
+		}
+		// Category words are not searched on, so some are not in the
+		// vocabulary and are stemmed here
+		for _, word := range categoryWords[i] {
+			root, ok := roots[word]
+			if !ok {
+				root = stemmer.stem(word)
+			}
+			addPosting(index.CategoryRoots, root, i)
+		}
+	}
+
+	return index
+}
+
+// malayStemmer returns a stemmer using the index vocabulary as its
+// lexicon. It is built on first use, as cached indexes are decoded
+// without one.
+func (index *searchIndex) malayStemmer() *malayStemmer {
+	index.stemmerOnce.Do(func() {
+		index.stemmer = newMalayStemmer(index.Vocabulary)
+	})
+	return index.stemmer
+}
+
+// indexCacheVersion must be bumped whenever searchIndex or Fatwa change
+// shape, so stale caches are rebuilt instead of decoded.
+const indexCacheVersion = 9
+
+// indexCache is the on-disk form of a parsed dataset and its index. It is
+// only reused when the dataset file's size and modification time match.
+type indexCache struct {
+	Version int
+	Size    int64
+	ModTime time.Time
+	Fatwas  []Fatwa
+	Index   *searchIndex
+}
+
+func indexCachePath() string {
+	if path := os.Getenv("INDEX_CACHE_PATH"); path != "" {
+		return path
+	}
+	return datasetPath() + ".idx"
+}
+
+// loadIndexedDataset loads the dataset together with its search index,
+// reusing the binary cache when the CSV dataset hasn't changed since it
+// was written. CSV datasets are verified against their checksum manifest
+// first. Database-backed datasets are always loaded directly.
+func loadIndexedDataset() ([]Fatwa, *searchIndex, error) {
+	if os.Getenv("DATABASE_PATH") != "" {
+		fatwas, err := loadDataset()
+		if err != nil {
+			return nil, nil, err
+		}
+		return fatwas, buildSearchIndex(fatwas), nil
+	}
+
+	if err := verifyDatasetChecksum(datasetPath()); err != nil {
+		return nil, nil, err
+	}
+
+	info, err := os.Stat(datasetPath())
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot stat dataset: %v", err)
+	}
+
+	if cache, err := readIndexCache(indexCachePath()); err == nil &&
+		cache.Version == indexCacheVersion && cache.Size == info.Size() && cache.ModTime.Equal(info.ModTime()) {
+		return cache.Fatwas, cache.Index, nil
+	}
+
+	fatwas, err := loadDataset()
+	if err != nil {
+		return nil, nil, err
+	}
+	index := buildSearchIndex(fatwas)
+
+	cache := indexCache{
+		Version: indexCacheVersion,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		Fatwas:  fatwas,
+		Index:   index,
+	}
+	if err := writeIndexCache(indexCachePath(), cache); err != nil {
+		log.Printf("Error writing index cache: %v", err)
+	}
+
+	return fatwas, index, nil
+}
+
+func readIndexCache(filename string) (indexCache, error) {
+	var cache indexCache
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return cache, err
+	}
+	defer file.Close()
+
+	if err := gob.NewDecoder(file).Decode(&cache); err != nil {
+		return cache, fmt.Errorf("cannot decode index cache: %v", err)
+	}
+	return cache, nil
+}
+
+func writeIndexCache(filename string, cache indexCache) error {
+	file, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("cannot create index cache: %v", err)
+	}
+	defer os.Remove(file.Name())
+
+	if err := gob.NewEncoder(file).Encode(cache); err != nil {
+		file.Close()
+		return fmt.Errorf("cannot encode index cache: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("cannot write index cache: %v", err)
+	}
+
+	return os.Rename(file.Name(), filename)
+}
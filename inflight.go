@@ -0,0 +1,41 @@
+package main
+
+import (
+	"slices"
+	"sync"
+)
+
+// inFlight tracks the requests to a slow service under way, so a fatwa
+// tapped again, or by another chat, while its translation or summary is
+// still being made is only requested once. Every chat that asked is sent
+// the result. The zero value is ready to use.
+type inFlight struct {
+	mu      sync.Mutex
+	waiting map[string][]int64 // chats waiting on each request
+}
+
+// join adds chatID to the chats waiting on the request for key, and
+// reports whether the request is new and should be made by the caller.
+func (f *inFlight) join(key string, chatID int64) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.waiting == nil {
+		f.waiting = make(map[string][]int64)
+	}
+	chats, ok := f.waiting[key]
+	if !slices.Contains(chats, chatID) {
+		f.waiting[key] = append(chats, chatID)
+	}
+	return !ok
+}
+
+// done ends the request for key and returns the chats waiting on it.
+func (f *inFlight) done(key string) []int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	chats := f.waiting[key]
+	delete(f.waiting, key)
+	return chats
+}
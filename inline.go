@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// inlineResultsPerPage is the number of fatwas returned per inline query
+// answer. Telegram asks for more with the next offset as the user scrolls.
+const inlineResultsPerPage = 20
+
+// inlineCacheSeconds is how long Telegram may reuse an inline answer for
+// the same query. Answers are in the language of the user asking, so they
+// are only reused for that user, see handleInlineQuery.
+const inlineCacheSeconds = 300
+
+// inlineSharedCacheSeconds is how long Telegram may reuse the answer
+// listing a shared fatwa, which only changes when the dataset is reloaded.
+const inlineSharedCacheSeconds = 3600
+
+// inlineDescriptionLength is the length of the content preview shown under
+// each title in the pick-list, in characters.
+const inlineDescriptionLength = 100
+
+// handleInlineQuery answers "@ApaHukumBot zakat emas" typed in any chat
+// with a pick-list of matching fatwas. Picking one sends a summary of it,
+// with a link to the full text, into that chat. A fatwa ID such as
+// "muftiwp:5123", as filled in by the share button, lists just that fatwa.
+func (fb *FatwaBot) handleInlineQuery(inlineQuery *tgbotapi.InlineQuery) {
+	query := strings.TrimSpace(inlineQuery.Query)
+	answer := tgbotapi.InlineConfig{
+		InlineQueryID: inlineQuery.ID,
+		Results:       []interface{}{},
+		CacheTime:     inlineCacheSeconds,
+		// The articles' labels are in the asking user's language
+		IsPersonal: true,
+	}
+
+	if fatwa, ok := fb.sharedFatwa(query); ok {
+		answer.CacheTime = inlineSharedCacheSeconds
+		_, index := fb.searchData()
+		// Without query words the summary starts at the top of the content
+		highlight := newHighlighter("", "keyword", index)
+		answer.Results = append(answer.Results, inlineArticle(fatwa, highlight, fb.language(inlineQuery.From.ID), fb.bot.Self.UserName))
+	} else if query != "" {
+		page := fb.inlineResults(query, inlineQuery.Offset, fb.language(inlineQuery.From.ID))
+		answer.Results = append(answer.Results, page.Results...)
+		answer.NextOffset = page.NextOffset
+	}
+
+	if _, err := fb.bot.Request(answer); err != nil {
+		log.Printf("Error answering inline query: %v", err)
+	}
+}
+
+// inlineResults returns a page of the pick-list for a query, from the
+// bot's cache of recent pages when it has it. Telegram sends a query for
+// every keystroke, and popular lookups are typed by many users, so pages
+// are kept rather than searched and highlighted again.
+func (fb *FatwaBot) inlineResults(query, offset, lang string) inlinePage {
+	fatwas, index := fb.searchData()
+	key := inlineCacheKey(query, offset, lang)
+	if page, ok := fb.inline.get(key, index); ok {
+		return page
+	}
+
+	var page inlinePage
+	start, _ := strconv.Atoi(offset)
+	results := runSearch(fatwas, index, query, "keyword")
+	highlight := newHighlighter(query, "keyword", index)
+	if start >= 0 && start < len(results) {
+		end := min(start+inlineResultsPerPage, len(results))
+		for _, result := range results[start:end] {
+			page.Results = append(page.Results, inlineArticle(result.Fatwa, highlight, lang, fb.bot.Self.UserName))
+		}
+		if end < len(results) {
+			page.NextOffset = strconv.Itoa(end)
+		}
+	}
+
+	fb.inline.add(key, index, page)
+	return page
+}
+
+// inlineArticle is the pick-list entry for a fatwa, and the summary sent
+// when it is picked, in the language of the user sharing it. The summary
+// has a button that opens the full fatwa in the bot.
+func inlineArticle(fatwa Fatwa, highlight *highlighter, lang, botName string) tgbotapi.InlineQueryResultArticle {
+	summary := fmt.Sprintf("📖 <b>%s</b>\n", html.EscapeString(fatwa.Title))
+	summary += fmt.Sprintf("📅 %s | 📂 %s\n\n", html.EscapeString(fatwa.Date), html.EscapeString(fatwa.Category))
+	summary += fmt.Sprintf("📄 %s\n\n", highlight.snippet(fatwa.Content, snippetRadius))
+	summary += tr(lang, "details.link", fatwa.URL)
+
+	article := tgbotapi.NewInlineQueryResultArticleHTML(fatwa.Key().String(), fatwa.Title, summary)
+	article.Description = plainExcerpt(fatwa.Content, inlineDescriptionLength)
+	keyboard := openInBotButton(botName, fatwa.Key(), lang)
+	article.ReplyMarkup = &keyboard
+	return article
+}
+
+// plainExcerpt returns the start of text with its whitespace collapsed,
+// cut to at most length characters.
+func plainExcerpt(text string, length int) string {
+	runes := []rune(strings.Join(strings.Fields(text), " "))
+	if len(runes) <= length {
+		return string(runes)
+	}
+	return string(runes[:length]) + "..."
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// inlineCacheTTL is how long a page of inline results is kept, as long
+	// as Telegram may reuse it.
+	inlineCacheTTL = inlineCacheSeconds * time.Second
+
+	// inlineCacheSize is the number of pages kept. Once full, expired pages
+	// are dropped first, and new pages aren't kept until some expire.
+	inlineCacheSize = 512
+)
+
+// inlinePage is a page of the pick-list answering an inline query.
+type inlinePage struct {
+	Results    []interface{}
+	NextOffset string
+}
+
+type inlineCacheEntry struct {
+	page    inlinePage
+	index   *searchIndex // the index the page was found in
+	expires time.Time
+}
+
+// inlineCache keeps the pages recently sent in answer to inline queries.
+// Pages found in an index that has since been reloaded aren't used.
+type inlineCache struct {
+	mu      sync.Mutex
+	entries map[string]inlineCacheEntry
+}
+
+func newInlineCache() *inlineCache {
+	return &inlineCache{entries: make(map[string]inlineCacheEntry)}
+}
+
+func (c *inlineCache) get(key string, index *searchIndex) (inlinePage, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || entry.index != index || time.Now().After(entry.expires) {
+		return inlinePage{}, false
+	}
+	return entry.page, true
+}
+
+func (c *inlineCache) add(key string, index *searchIndex, page inlinePage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if len(c.entries) >= inlineCacheSize {
+		c.expire(now)
+		if len(c.entries) >= inlineCacheSize {
+			return
+		}
+	}
+	c.entries[key] = inlineCacheEntry{page: page, index: index, expires: now.Add(inlineCacheTTL)}
+}
+
+// sweep forgets the pages that have expired, and returns how many.
+func (c *inlineCache) sweep(now time.Time) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.expire(now)
+}
+
+// expire deletes the expired pages. The caller holds mu.
+func (c *inlineCache) expire(now time.Time) int {
+	expired := 0
+	for key, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, key)
+			expired++
+		}
+	}
+	return expired
+}
+
+// inlineCacheKey identifies a page of an inline query in a language,
+// regardless of the query's spacing, case and accents.
+func inlineCacheKey(query, offset, lang string) string {
+	return strings.Join([]string{lang, searchCacheKey(query, "keyword"), offset}, "\x00")
+}
@@ -0,0 +1,136 @@
+package main
+
+import "strings"
+
+// jawiLetters gives the Rumi spelling of each Jawi letter. Vowels are
+// mostly left unwritten in Jawi, so words are matched on their consonants
+// only. Ta marbuta is spelt "t" in some loanwords (zakat) and "h" in
+// others (ibadah), so both are tried. Hamza and ain have no Rumi letter.
+var jawiLetters = map[rune][]string{
+	'ا': {"a"}, 'ٱ': {"a"},
+	'ب': {"b"},
+	'ت': {"t"},
+	'ة': {"t", "h"},
+	'ث': {"s"},
+	'ج': {"j"},
+	'چ': {"c"},
+	'ح': {"h"},
+	'خ': {"kh"},
+	'د': {"d"},
+	'ذ': {"z"},
+	'ر': {"r"},
+	'ز': {"z"},
+	'س': {"s"},
+	'ش': {"sy"},
+	'ص': {"s"},
+	'ض': {"d"},
+	'ط': {"t"},
+	'ظ': {"z"},
+	'ع': {""},
+	'غ': {"gh"},
+	'ڠ': {"ng"},
+	'ف': {"f"},
+	'ڤ': {"p"},
+	'ق': {"k"},
+	'ك': {"k"}, 'ک': {"k"},
+	'ݢ': {"g"}, 'ڬ': {"g"},
+	'ل': {"l"},
+	'م': {"m"},
+	'ن': {"n"},
+	'ڽ': {"ny"},
+	'و': {"w"},
+	'ۏ': {"v"},
+	'ه': {"h"},
+	'ي': {"y"}, 'ی': {"y"}, 'ى': {"y"},
+	'ء': {""},
+	'ـ': {""},
+}
+
+// rumiSpellings folds Rumi spellings that Jawi doesn't tell apart.
+var rumiSpellings = strings.NewReplacer("q", "k", "dh", "d", "th", "s")
+
+// consonantSkeleton reduces a Rumi word to the consonants Jawi reliably
+// writes. W and y are dropped with the vowels, as the same Jawi letters
+// spell o/u and i/e.
+func consonantSkeleton(word string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune("aeiouwy'", r) {
+			return -1
+		}
+		return r
+	}, rumiSpellings.Replace(word))
+}
+
+// jawiSkeletons returns the consonant skeletons a Jawi word may stand for,
+// or nil if it contains anything but Jawi letters. Letters are reduced one
+// at a time, so neighbouring letters never read as a Rumi digraph.
+func jawiSkeletons(word string) []string {
+	skeletons := []string{""}
+	for _, r := range word {
+		letters, ok := jawiLetters[r]
+		if !ok {
+			return nil
+		}
+		next := make([]string, 0, len(skeletons)*len(letters))
+		for _, skeleton := range skeletons {
+			for _, letter := range letters {
+				next = append(next, skeleton+consonantSkeleton(letter))
+			}
+		}
+		skeletons = next
+	}
+
+	if skeletons[0] == "" {
+		return nil
+	}
+	return skeletons
+}
+
+// jawiLexicon returns the most common Rumi word of the corpus for each
+// consonant skeleton. It is built on first use, like the stemmer.
+func (index *searchIndex) jawiLexicon() map[string]string {
+	index.jawiOnce.Do(func() {
+		index.jawiWords = make(map[string]string)
+		for _, word := range index.Vocabulary {
+			if strings.ContainsFunc(word, func(r rune) bool { return (r < 'a' || r > 'z') && r != '\'' }) {
+				continue
+			}
+			skeleton := consonantSkeleton(word)
+			if skeleton == "" {
+				continue
+			}
+			if best, ok := index.jawiWords[skeleton]; !ok || len(index.WordDocs[word]) > len(index.WordDocs[best]) {
+				index.jawiWords[skeleton] = word
+			}
+		}
+	})
+	return index.jawiWords
+}
+
+// transliterateJawi replaces the Jawi words of text with the corpus words
+// they most likely spell, so "زكاة" finds fatwas about "zakat". Text must
+// be case-folded but not yet Arabic-normalized, which would merge ta
+// marbuta with ha. It reports false if no word was replaced.
+func (index *searchIndex) transliterateJawi(text string) (string, bool) {
+	if !strings.ContainsFunc(text, isArabic) {
+		return text, false
+	}
+
+	lexicon := index.jawiLexicon()
+	words := tokenize(text)
+	replaced := false
+	for i, word := range words {
+		best := ""
+		for _, skeleton := range jawiSkeletons(word) {
+			if rumi, ok := lexicon[skeleton]; ok && (best == "" || len(index.WordDocs[rumi]) > len(index.WordDocs[best])) {
+				best = rumi
+			}
+		}
+		if best != "" {
+			words[i] = best
+			replaced = true
+		}
+	}
+
+	return strings.Join(words, " "), replaced
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// llmConfig holds the settings for a chat model with the OpenAI chat
+// completions API, which OpenAI and most self-hosted servers offer.
+type llmConfig struct {
+	APIKey   string
+	Model    string
+	Endpoint string
+}
+
+// loadLLMConfig reads the chat model settings from the environment. The
+// features using it are disabled unless LLM_API_KEY or LLM_ENDPOINT is set;
+// a local server may need no key.
+func loadLLMConfig() (*llmConfig, bool) {
+	cfg := &llmConfig{
+		APIKey:   os.Getenv("LLM_API_KEY"),
+		Model:    os.Getenv("LLM_MODEL"),
+		Endpoint: os.Getenv("LLM_ENDPOINT"),
+	}
+
+	if cfg.APIKey == "" && cfg.Endpoint == "" {
+		return nil, false
+	}
+	if cfg.Model == "" {
+		cfg.Model = "gpt-4o-mini"
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "https://api.openai.com/v1/chat/completions"
+	}
+
+	return cfg, true
+}
+
+// complete returns the model's reply to a message, following the
+// instructions in prompt.
+func (cfg *llmConfig) complete(prompt, message string) (string, error) {
+	body, err := json.Marshal(map[string]any{
+		"model": cfg.Model,
+		"messages": []map[string]string{
+			{"role": "system", "content": prompt},
+			{"role": "user", "content": message},
+		},
+		"temperature": 0,
+	})
+	if err != nil {
+		return "", fmt.Errorf("cannot encode completion request: %v", err)
+	}
+
+	req, err := http.NewRequest("POST", cfg.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("cannot create completion request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if cfg.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+cfg.APIKey)
+	}
+
+	client := &http.Client{Timeout: 60 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("cannot request completion: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("completion request failed: %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+
+	var result struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("cannot decode completion response: %v", err)
+	}
+	if len(result.Choices) == 0 {
+		return "", fmt.Errorf("completion response has no reply")
+	}
+	return strings.TrimSpace(result.Choices[0].Message.Content), nil
+}
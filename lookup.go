@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+var (
+	messageURLPattern  = regexp.MustCompile(`https?://\S+|(?:www\.)?muftiwp\.gov\.my/\S+`)
+	articleIDPattern   = regexp.MustCompile(`/(\d+)-`)
+	articleHitsPattern = regexp.MustCompile(`(\d+)`)
+)
+
+// findArticleURL returns the first muftiwp.gov.my link in a message, in
+// canonical form, or "" if there is none.
+func findArticleURL(text string) string {
+	for _, match := range messageURLPattern.FindAllString(text, -1) {
+		if !strings.HasPrefix(match, "http") {
+			match = "https://" + match
+		}
+		match = strings.TrimRight(match, ".,;:!?)>\"'")
+
+		u, err := url.Parse(match)
+		if err != nil {
+			continue
+		}
+		host := strings.ToLower(u.Hostname())
+		if host == "muftiwp.gov.my" || host == "www.muftiwp.gov.my" {
+			return canonicalURL(match)
+		}
+	}
+	return ""
+}
+
+// showFatwaByURL sends the fatwa an article link points to. Articles that
+// aren't in the dataset yet are scraped on the spot, in the background so
+// other updates aren't held up, and the status message is replaced when
+// the scrape finishes; they are added to the dataset by the next scheduled
+// scrape.
+func (fb *FatwaBot) showFatwaByURL(chatID int64, articleURL string) {
+	if fatwa, ok := fb.findFatwaByURL(articleURL); ok {
+		fb.sendFatwaDetails(chatID, fatwa)
+		return
+	}
+
+	statusID := fb.sendStatus(chatID, "lookup.fetch")
+
+	fb.goSafe(chatID, func() {
+		fatwa, err := scrapeArticle(articleURL)
+		if err != nil {
+			log.Printf("Error scraping %s: %v", articleURL, err)
+			fb.replaceStatus(chatID, statusID, fb.t(chatID, "lookup.failed"), nil)
+			return
+		}
+
+		fb.replaceStatusWithDetails(chatID, statusID, fatwa)
+	})
+}
+
+// findFatwaByURL finds a stored fatwa by its canonical URL, or by the
+// article ID in the URL.
+func (fb *FatwaBot) findFatwaByURL(articleURL string) (Fatwa, bool) {
+	fatwas := fb.dataset()
+	for _, fatwa := range fatwas {
+		if canonicalURL(fatwa.URL) == articleURL {
+			return fatwa, true
+		}
+	}
+
+	if matches := articleIDPattern.FindStringSubmatch(articleURL); len(matches) > 1 {
+		if id, err := strconv.Atoi(matches[1]); err == nil {
+			return fb.findFatwa(FatwaKey{Source: defaultSource, ID: id})
+		}
+	}
+	return Fatwa{}, false
+}
+
+// scrapeArticle scrapes a single article page. Its title, date, hits and
+// category come from the article's own markup rather than the listing.
+func scrapeArticle(articleURL string) (Fatwa, error) {
+	doc, err := fetchArticlePage(articleURL)
+	if err != nil {
+		return Fatwa{}, err
+	}
+
+	content, err := articleContent(doc)
+	if err != nil {
+		return Fatwa{}, err
+	}
+
+	fatwa := Fatwa{
+		URL:      articleURL,
+		Content:  content,
+		Source:   defaultSource,
+		Title:    firstText(doc, "h1[itemprop='headline']", ".page-header h1", ".page-header h2", "h1"),
+		Date:     firstText(doc, "time[itemprop='datePublished']", "dd.published", ".published"),
+		Category: firstText(doc, "dd.category-name a", ".category-name a", ".category-name"),
+	}
+	if fatwa.Title == "" {
+		fatwa.Title = strings.TrimSpace(doc.Find("title").Text())
+	}
+	if fatwa.Title == "" {
+		return Fatwa{}, fmt.Errorf("article title not found")
+	}
+
+	if matches := articleHitsPattern.FindStringSubmatch(firstText(doc, "dd.hits", ".hits")); len(matches) > 1 {
+		fatwa.Hits, _ = strconv.Atoi(matches[1])
+	}
+	if matches := articleIDPattern.FindStringSubmatch(articleURL); len(matches) > 1 {
+		fatwa.ID, _ = strconv.Atoi(matches[1])
+	}
+
+	return fatwa, nil
+}
+
+// firstText returns the trimmed text of the first selector that matches.
+func firstText(doc *goquery.Document, selectors ...string) string {
+	for _, selector := range selectors {
+		if text := strings.TrimSpace(doc.Find(selector).First().Text()); text != "" {
+			return strings.Join(strings.Fields(text), " ")
+		}
+	}
+	return ""
+}
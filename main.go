@@ -2,16 +2,21 @@ package main
 
 import (
 	"compress/gzip"
+	"crypto/sha256"
 	"encoding/csv"
+	"encoding/hex"
 	"fmt"
+	"html"
 	"io"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -29,13 +34,97 @@ type Fatwa struct {
 	Hits     int
 	Category string
 	Content  string
+	Source   string
 }
+
+// defaultSource is the source of fatwas scraped from the Jabatan Mufti
+// Wilayah Persekutuan website, and of records that predate the Source field.
+const defaultSource = "muftiwp"
+
+// FatwaKey identifies a fatwa across sources, whose numeric IDs may collide.
+type FatwaKey struct {
+	Source string
+	ID     int
+}
+
+func (f Fatwa) Key() FatwaKey {
+	return FatwaKey{Source: f.Source, ID: f.ID}
+}
+
+func (k FatwaKey) String() string {
+	return k.Source + ":" + strconv.Itoa(k.ID)
+}
+
+// parseFatwaKey parses "source:id", or a bare numeric ID for the default
+// source.
+func parseFatwaKey(value string) (FatwaKey, error) {
+	source, idStr, found := strings.Cut(strings.TrimSpace(value), ":")
+	if !found {
+		source, idStr = defaultSource, source
+	}
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || source == "" {
+		return FatwaKey{}, fmt.Errorf("invalid fatwa key %q", value)
+	}
+	return FatwaKey{Source: source, ID: id}, nil
+}
+
 type FatwaBot struct {
-	bot    *tgbotapi.BotAPI
-	fatwas []Fatwa
+	*fatwaDataset // shared by every bot of the process
+
+	bot      *tgbotapi.BotAPI
+	name     string // "" for the main bot
+	lang     string // for users who haven't picked a language
+	commands []string
+	admins   map[int64]bool
+	store    *UserStore
+
+	sessionsMu sync.Mutex // guards sessions
+	sessions   map[int64]searchSession
+
+	callbackTextsMu sync.Mutex // guards callbackTexts
+	callbackTexts   map[string]callbackText
+
+	broadcastsMu sync.Mutex // guards broadcasts
+	broadcasts   map[int64]string
+
+	languagesMu sync.Mutex // guards languages
+	languages   map[int64]cachedLanguage
+
+	bannedMu sync.RWMutex // guards banned
+	banned   map[int64]bool
+
+	limiter       *rateLimiter
+	inlineLimiter *rateLimiter // inline queries, sent as the user types
+	outbox        *outbox
+	inline        *inlineCache    // recent pages of inline results
+	reports       chan linkReport // reported fatwas waiting to be scraped again
+
+	panicMu         sync.Mutex // guards lastPanicNotice
+	lastPanicNotice time.Time
+
+	translating inFlight // translations being made
+	summarizing inFlight // summaries being made, by fatwa and language
+
+	tts        *ttsConfig       // nil when audio is disabled
+	stt        *sttConfig       // nil when voice search is disabled
+	ocr        *ocrConfig       // nil when image search is disabled
+	translator *translateConfig // nil when translation is disabled
+	llm        *llmConfig       // nil when summaries are disabled
+	support    *supportConfig   // nil when /sokong is disabled
 }
 
 func main() {
+	// Run a one-shot subcommand instead of the bot when one is given
+	if len(os.Args) > 1 {
+		godotenv.Load() // .env is optional for subcommands
+		if err := runSubcommand(os.Args[1], os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	// Create a new cron scheduler
 	c := cron.New()
 
@@ -51,6 +140,12 @@ func main() {
 		log.Fatal("Error scheduling cron job:", err)
 	}
 
+	// Clean up expired snapshots and cache files every night at 4:00 AM
+	_, err = c.AddFunc("0 4 * * *", runRetentionCleanup)
+	if err != nil {
+		log.Fatal("Error scheduling cleanup job:", err)
+	}
+
 	// Start the cron scheduler
 	c.Start()
 	defer c.Stop() // Ensure cron stops when main exits
@@ -61,35 +156,149 @@ func main() {
 		log.Fatalf("Error loading .env file")
 	}
 
-	// Get the token
-	botToken := os.Getenv("BOT_TOKEN")
-	if botToken == "" {
-		log.Fatal("BOT_TOKEN not set in environment")
+	// Read the bots to serve: BOT_TOKEN, and any more listed in BOTS
+	configs, err := loadBotConfigs()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Load fatwa data from CSV or the database, along with its search index
+	fatwas, index, err := loadStartupDataset()
+	if err != nil {
+		log.Fatalf("Error loading fatwa data: %v", err)
 	}
 
-	bot, err := tgbotapi.NewBotAPI(botToken)
+	// Load previous content versions, if any have been recorded
+	history, err := loadFatwaHistory("fatwa_history.csv")
 	if err != nil {
-		log.Panic(err)
+		log.Printf("Error loading fatwa history: %v", err)
+		history = make(map[FatwaKey][]FatwaVersion)
 	}
 
-	bot.Debug = true
-	log.Printf("Authorized on account %s", bot.Self.UserName)
+	data := &fatwaDataset{
+		fatwas:  fatwas,
+		index:   index,
+		history: history,
+		quiz:    quizQuestions(fatwas),
+		loaded:  time.Now(),
+	}
+	log.Printf("Loaded %d fatwas", len(fatwas))
 
-	// Load fatwa data from CSV
-	fatwas, err := loadFatwaData("fatwa.csv")
+	// Open the per-user state stores, encrypted if a key is configured
+	cipher, err := newFieldCipher(os.Getenv("USER_DATA_KEY"))
 	if err != nil {
-		log.Fatalf("Error loading fatwa data: %v", err)
+		log.Fatalf("Error loading user data key: %v", err)
 	}
 
-	fatwaBot := &FatwaBot{
-		bot:    bot,
-		fatwas: fatwas,
+	// The optional services are the same for every bot
+	tts, ok := loadTTSConfig()
+	if ok {
+		log.Printf("Text-to-speech enabled with voice %s", tts.Voice)
+	}
+	stt, ok := loadSTTConfig()
+	if ok {
+		log.Printf("Speech-to-text enabled with model %s", stt.Model)
+	}
+	ocr, ok := loadOCRConfig()
+	if ok {
+		log.Println("Text recognition enabled")
+	}
+	translator, ok := loadTranslateConfig()
+	if ok {
+		log.Printf("Translation enabled with %s", translator.Provider)
+	}
+	llm, ok := loadLLMConfig()
+	if ok {
+		log.Printf("Summaries enabled with model %s", llm.Model)
+	}
+	support, ok := loadSupportConfig()
+	if ok {
+		log.Printf("Donations enabled with %d Stars amounts and %d links", len(support.Stars), len(support.Links))
 	}
 
-	log.Printf("Loaded %d fatwas", len(fatwas))
+	for _, config := range configs {
+		bot, err := tgbotapi.NewBotAPI(config.Token)
+		if err != nil {
+			log.Panic(err)
+		}
+
+		bot.Debug = true
+		log.Printf("Authorized on account %s", bot.Self.UserName)
+
+		store, err := openUserStore(config.UserDB, cipher)
+		if err != nil {
+			log.Fatalf("Error opening user store: %v", err)
+		}
+		defer store.Close()
+
+		fatwaBot := &FatwaBot{
+			fatwaDataset: data,
+
+			bot:      bot,
+			name:     config.Name,
+			lang:     config.Language,
+			commands: config.Commands,
+			admins:   parseAdminIDs(os.Getenv("ADMIN_IDS")),
+			store:    store,
+
+			sessions:      make(map[int64]searchSession),
+			callbackTexts: make(map[string]callbackText),
+			broadcasts:    make(map[int64]string),
+			languages:     make(map[int64]cachedLanguage),
+			banned:        make(map[int64]bool),
+			limiter:       newRateLimiter(rateLimitPerMinute, rateLimitBurst),
+			inlineLimiter: newRateLimiter(inlineRateLimitPerMinute, inlineRateLimitBurst),
+			outbox:        newOutbox(),
+			inline:        newInlineCache(),
+			reports:       make(chan linkReport, reportQueueLength),
+
+			tts:        tts,
+			stt:        stt,
+			ocr:        ocr,
+			translator: translator,
+			llm:        llm,
+			support:    support,
+		}
+		data.bots = append(data.bots, fatwaBot)
+
+		if err := fatwaBot.loadBans(); err != nil {
+			log.Printf("Error loading bans: %v", err)
+		}
+
+		// Check every hour for digests due at that hour
+		if _, err := c.AddFunc("0 * * * *", fatwaBot.sendDigests); err != nil {
+			log.Fatal("Error scheduling digest job:", err)
+		}
+
+		// Send the fatwa of the day to the chats that opted in and the channel
+		if _, err := c.AddFunc(fmt.Sprintf("0 %d * * *", dailyFatwaHour()), fatwaBot.postDailyFatwa); err != nil {
+			log.Fatal("Error scheduling fatwa of the day job:", err)
+		}
+
+		// Drop old query logs and activity every night at 4:30 AM
+		if _, err := c.AddFunc("30 4 * * *", fatwaBot.pruneAnalytics); err != nil {
+			log.Fatal("Error scheduling analytics cleanup job:", err)
+		}
+
+		// Forget expired searches, languages and wizard steps
+		if _, err := c.AddFunc(fmt.Sprintf("@every %s", sweepInterval), fatwaBot.sweepState); err != nil {
+			log.Fatal("Error scheduling state sweep job:", err)
+		}
+
+		// Show the commands in Telegram's "/" menu
+		fatwaBot.registerCommands()
+	}
+
+	// Reload the dataset whenever the scraper replaces it
+	go data.bots[0].watchDataset()
+
+	for _, fatwaBot := range data.bots {
+		// Scrape reported fatwas again as they are reported
+		go fatwaBot.checkReports()
 
-	// Start bot in a goroutine
-	go fatwaBot.start()
+		// Start bot in a goroutine
+		go fatwaBot.start()
+	}
 
 	// Wait for interrupt signal to gracefully shutdown
 	quit := make(chan os.Signal, 1)
@@ -99,30 +308,94 @@ func main() {
 	log.Println("Shutting down server...")
 }
 
-func (fb *FatwaBot) start() {
-	u := tgbotapi.NewUpdate(0)
-	u.Timeout = 60
+func runSubcommand(name string, args []string) error {
+	switch name {
+	case "migrate":
+		return runMigrate(args)
+	case "export":
+		return runExport(args)
+	case "rollback":
+		return runRollback(args)
+	case "import":
+		return runImport(args)
+	default:
+		return fmt.Errorf("unknown command %q (available: migrate, export, rollback, import)", name)
+	}
+}
 
-	updates := fb.bot.GetUpdatesChan(u)
+// start receives updates and hands them to a pool of workers, after
+// dropping those of banned chats and users and of chats over the rate
+// limit.
+func (fb *FatwaBot) start() {
+	pool := newUpdatePool(updateWorkers(), fb.handleUpdate)
+	defer pool.close()
 
-	for update := range updates {
-		if update.Message != nil {
-			fb.handleMessage(update.Message)
-		} else if update.CallbackQuery != nil {
-			fb.handleCallbackQuery(update.CallbackQuery)
+	for update := range fb.updates() {
+		if fb.isBanned(update) || !fb.allowUpdate(update) {
+			continue
 		}
+		pool.dispatch(update)
+	}
+}
+
+func (fb *FatwaBot) handleUpdate(update tgbotapi.Update) {
+	defer fb.recoverUpdate(update)
+
+	if update.Message != nil {
+		fb.handleMessage(update.Message)
+	} else if update.CallbackQuery != nil {
+		fb.handleCallbackQuery(update.CallbackQuery)
+	} else if update.InlineQuery != nil {
+		fb.handleInlineQuery(update.InlineQuery)
+	} else if update.PreCheckoutQuery != nil {
+		fb.answerPreCheckout(update.PreCheckoutQuery)
 	}
 }
 
 func (fb *FatwaBot) handleMessage(message *tgbotapi.Message) {
 	chatID := message.Chat.ID
-	text := message.Text
+
+	// A donation went through; the message has no text
+	if message.SuccessfulPayment != nil {
+		fb.thankSupporter(message)
+		return
+	}
+
+	// Commands may carry the bot's name ("/search@ApaHukumBot"); in groups
+	// only commands are answered, so the bot stays quiet in conversations
+	text, ok := commandText(message.Text, fb.bot.Self.UserName)
+	if !ok || (isGroupChat(chatID) && !strings.HasPrefix(text, "/")) {
+		return
+	}
+
+	created, err := fb.store.TouchUser(chatID)
+	if err != nil {
+		log.Printf("Error recording user: %v", err)
+	}
+	if created {
+		fb.adoptTelegramLanguage(chatID, message.From)
+	}
+
+	// Questions may be spoken rather than typed, or sent as a photo or
+	// screenshot of the text
+	if message.Voice != nil {
+		fb.searchByVoice(chatID, message.Voice)
+		return
+	}
+	if fileID, ok := imageFileID(message); ok {
+		fb.searchByImage(chatID, fileID, message.Caption)
+		return
+	}
 
 	switch {
-	case text == "/start":
-		fb.sendWelcomeMessage(chatID)
+	case text == "/start" || strings.HasPrefix(text, "/start "):
+		fb.handleStart(chatID, strings.TrimPrefix(text, "/start"))
 	case text == "/help":
 		fb.sendHelpMessage(chatID)
+	case text == "/menu":
+		fb.sendMenu(chatID, "menu.shown")
+	case text == "/cari":
+		fb.startWizard(chatID)
 	case strings.HasPrefix(text, "/search "):
 		query := strings.TrimPrefix(text, "/search ")
 		fb.searchFatwas(chatID, query, "keyword")
@@ -132,222 +405,322 @@ func (fb *FatwaBot) handleMessage(message *tgbotapi.Message) {
 	case strings.HasPrefix(text, "/category "):
 		query := strings.TrimPrefix(text, "/category ")
 		fb.searchFatwas(chatID, query, "category")
+	case strings.HasPrefix(text, "/regex "):
+		if !fb.isAdmin(message.From) {
+			fb.reply(chatID, "admin_only")
+			return
+		}
+		fb.searchFatwas(chatID, strings.TrimPrefix(text, "/regex "), "regex")
+	case text == "/tanya" || strings.HasPrefix(text, "/tanya "):
+		fb.askQuestion(chatID, strings.TrimPrefix(text, "/tanya"))
 	case text == "/categories":
 		fb.showCategories(chatID)
+	case text == "/tahun" || strings.HasPrefix(text, "/tahun "):
+		fb.browse(chatID, strings.TrimPrefix(text, "/tahun"), "year")
+	case text == "/abjad" || strings.HasPrefix(text, "/abjad "):
+		fb.browse(chatID, strings.TrimPrefix(text, "/abjad"), "letter")
+	case text == "/random" || strings.HasPrefix(text, "/random "):
+		fb.handleRandom(chatID, strings.TrimPrefix(text, "/random"))
+	case text == "/new":
+		fb.showNewFatwas(chatID)
+	case text == "/trending":
+		fb.showTrending(chatID)
+	case text == "/kuiz" || strings.HasPrefix(text, "/kuiz "):
+		fb.handleQuiz(chatID, message.From, strings.TrimPrefix(text, "/kuiz"))
+	case text == "/id" || strings.HasPrefix(text, "/id "):
+		fb.showFatwaByID(chatID, strings.TrimPrefix(text, "/id"))
+	case text == "/pdf" || strings.HasPrefix(text, "/pdf "):
+		fb.sendFatwaPDF(chatID, strings.TrimPrefix(text, "/pdf"))
+	case text == "/subscribe" || strings.HasPrefix(text, "/subscribe "):
+		fb.subscribe(chatID, strings.TrimPrefix(text, "/subscribe"))
+	case text == "/unsubscribe" || strings.HasPrefix(text, "/unsubscribe "):
+		fb.unsubscribe(chatID, strings.TrimPrefix(text, "/unsubscribe"))
+	case text == "/fatwaharian" || strings.HasPrefix(text, "/fatwaharian "):
+		fb.handleDailyFatwa(chatID, strings.TrimPrefix(text, "/fatwaharian"))
+	case text == "/digest" || strings.HasPrefix(text, "/digest "):
+		fb.handleDigest(chatID, strings.TrimPrefix(text, "/digest"))
+	case text == "/save" || strings.HasPrefix(text, "/save "):
+		fb.saveBookmark(chatID, strings.TrimPrefix(text, "/save"))
+	case text == "/bookmarks":
+		fb.showBookmarks(chatID)
+	case text == "/alert" || strings.HasPrefix(text, "/alert "):
+		fb.handleAlert(chatID, strings.TrimPrefix(text, "/alert"))
+	case text == "/history":
+		fb.showSearchHistory(chatID)
+	case strings.HasPrefix(text, "/history "):
+		if !fb.isAdmin(message.From) {
+			fb.reply(chatID, "admin_only")
+			return
+		}
+		fb.showContentHistory(chatID, strings.TrimPrefix(text, "/history "))
+	case text == "/about":
+		fb.showAbout(chatID)
+	case text == "/sokong":
+		fb.showSupport(chatID)
+	case text == "/paysupport":
+		fb.showPaySupport(chatID, message.From)
+	case text == "/refund" || strings.HasPrefix(text, "/refund "):
+		if !fb.isAdmin(message.From) {
+			fb.reply(chatID, "admin_only")
+			return
+		}
+		fb.refundSupport(chatID, strings.TrimPrefix(text, "/refund"))
+	case text == "/stats":
+		fb.showStats(chatID)
+		if fb.isAdmin(message.From) {
+			fb.showAdminStats(chatID)
+		}
+	case text == "/admin" || text == "/reload" || text == "/users" || text == "/gaps" || text == "/activity" || text == "/feedback" || text == "/topics":
+		if !fb.isAdmin(message.From) {
+			fb.reply(chatID, "admin_only")
+			return
+		}
+		fb.handleAdmin(chatID, text)
+	case text == "/broadcastpreview" || strings.HasPrefix(text, "/broadcastpreview ") || strings.HasPrefix(text, "/broadcastpreview\n"):
+		if !fb.isAdmin(message.From) {
+			fb.reply(chatID, "admin_only")
+			return
+		}
+		fb.previewBroadcast(chatID, strings.TrimPrefix(text, "/broadcastpreview"))
+	case text == "/broadcast" || strings.HasPrefix(text, "/broadcast ") || strings.HasPrefix(text, "/broadcast\n"):
+		if !fb.isAdmin(message.From) {
+			fb.reply(chatID, "admin_only")
+			return
+		}
+		fb.prepareBroadcast(chatID, strings.TrimPrefix(text, "/broadcast"))
+	case text == "/supporttext" || strings.HasPrefix(text, "/supporttext ") || strings.HasPrefix(text, "/supporttext\n"):
+		if !fb.isAdmin(message.From) {
+			fb.reply(chatID, "admin_only")
+			return
+		}
+		fb.setSupportText(chatID, strings.TrimPrefix(text, "/supporttext"))
+	case text == "/lang" || strings.HasPrefix(text, "/lang "):
+		fb.handleLanguage(chatID, strings.TrimPrefix(text, "/lang"))
+	case text == "/settings":
+		fb.showSettings(chatID)
+	case text == "/ban" || strings.HasPrefix(text, "/ban ") || text == "/unban" || strings.HasPrefix(text, "/unban "):
+		if !fb.isAdmin(message.From) {
+			fb.reply(chatID, "admin_only")
+			return
+		}
+		command, id, _ := strings.Cut(text, " ")
+		fb.handleBan(chatID, message.From.ID, command, id)
+	case text == "/rollback":
+		if !fb.isAdmin(message.From) {
+			fb.reply(chatID, "admin_only")
+			return
+		}
+		fb.handleRollback(chatID)
 	default:
-		// Default search by keyword
-		fb.searchFatwas(chatID, text, "keyword")
-	}
-}
-
-func (fb *FatwaBot) handleCallbackQuery(callbackQuery *tgbotapi.CallbackQuery) {
-	chatID := callbackQuery.Message.Chat.ID
-	data := callbackQuery.Data
+		// Run the action of a main menu button
+		if action, ok := menuAction(text); ok {
+			fb.handleMenu(chatID, action)
+			return
+		}
 
-	// Parse callback data (format: "view_ID")
-	if strings.HasPrefix(data, "view_") {
-		idStr := strings.TrimPrefix(data, "view_")
-		id, err := strconv.Atoi(idStr)
-		if err != nil {
-			fb.sendMessage(chatID, "❌ Error parsing fatwa ID")
+		// Take the keywords the /cari wizard is waiting for
+		if fb.continueWizard(chatID, text) {
 			return
 		}
 
-		// Find and display the fatwa
-		for _, fatwa := range fb.fatwas {
-			if fatwa.ID == id {
-				fb.sendFatwaDetails(chatID, fatwa)
-				break
-			}
+		// Show the fatwa behind a pasted article link
+		if articleURL := findArticleURL(text); articleURL != "" {
+			fb.showFatwaByURL(chatID, articleURL)
+			return
 		}
-	}
 
-	// Answer callback query
-	callback := tgbotapi.NewCallback(callbackQuery.ID, "")
-	fb.bot.Request(callback)
+		// Default search by keyword
+		fb.searchFatwas(chatID, text, "keyword")
+	}
 }
 
 func (fb *FatwaBot) sendWelcomeMessage(chatID int64) {
-	message := `🕌 *Selamat Datang ke ApaHukumBot*
-
-Bot ini membantu anda mencari fatwa daripada Jabatan Mufti Wilayah Persekutuan.
-
-*Cara menggunakan:*
-• Taip sebarang kata kunci untuk carian umum
-• /search [kata kunci] - Cari dalam tajuk dan kandungan
-• /title [kata kunci] - Cari berdasarkan tajuk sahaja  
-• /category [kategori] - Cari berdasarkan kategori
-• /categories - Lihat senarai kategori
-• /help - Panduan lengkap
-
-*Contoh:*
-• "haiwan peliharaan"
-• /title solat
-• /category irsyad
-
-Mulakan pencarian anda sekarang! 🔍
-
-Created by @mnajmuddean
-💬 Sebarang cadangan atau isu, sila hubungi: @mnajmuddean`
-
-	msg := tgbotapi.NewMessage(chatID, message)
-	msg.ParseMode = "Markdown"
-	fb.bot.Send(msg)
+	fb.sendMenu(chatID, "welcome")
 }
 
 func (fb *FatwaBot) sendHelpMessage(chatID int64) {
-	message := "📚 *Panduan Penggunaan Bot Fatwa*\n\n" +
-		"*Perintah Yang Tersedia:*\n\n" +
-		"🔍 *Pencarian Umum*\n" +
-		"• Taip sahaja kata kunci anda\n" +
-		"• Contoh: \"zakat fitrah\"\n\n" +
-		"🔍 *Pencarian Khusus*\n" +
-		"• `/search [kata kunci]` - Cari dalam tajuk dan kandungan\n" +
-		"• `/title [kata kunci]` - Cari berdasarkan tajuk sahaja\n" +
-		"• `/category [kategori]` - Cari berdasarkan kategori\n\n" +
-		"📂 *Kategori*\n" +
-		"• `/categories` - Lihat semua kategori yang ada\n\n" +
-		"ℹ️ *Maklumat Lain*\n" +
-		"• `/help` - Papar panduan ini\n" +
-		"• `/start` - Mula semula\n\n" +
-		"*Tips Pencarian:*\n" +
-		"• Gunakan kata kunci yang ringkas dan tepat\n" +
-		"• Boleh guna Bahasa Malaysia atau Arab\n" +
-		"• Cari menggunakan sebahagian tajuk untuk hasil yang lebih baik\n\n" +
-		"Selamat mencari fatwa! 🤲"
-
-	msg := tgbotapi.NewMessage(chatID, message)
-	msg.ParseMode = "Markdown"
-	fb.bot.Send(msg)
+	fb.reply(chatID, "help")
 }
 
 func (fb *FatwaBot) searchFatwas(chatID int64, query string, searchType string) {
+	fb.searchFatwasIn(chatID, query, searchType, "")
+}
+
+// searchFatwasIn searches like searchFatwas, keeping only the results in a
+// category unless category is empty.
+func (fb *FatwaBot) searchFatwasIn(chatID int64, query, searchType, category string) {
 	if strings.TrimSpace(query) == "" {
-		fb.sendMessage(chatID, "❌ Sila masukkan kata kunci untuk carian")
+		fb.reply(chatID, "search.no_query")
+		return
+	}
+
+	if searchType == "regex" {
+		if _, err := compileSearchPattern(query); err != nil {
+			fb.reply(chatID, "search.bad_regex", err)
+			return
+		}
+	}
+
+	if err := fb.store.AddSearch(chatID, query, searchType); err != nil {
+		log.Printf("Error saving search history: %v", err)
+	}
+
+	fatwas, index := fb.searchData()
+	if (searchType == "keyword" || searchType == "title") && parseQuery(query, index).excludesOnly() {
+		fb.reply(chatID, "search.excludes_only")
 		return
 	}
 
-	fb.sendMessage(chatID, "🔍 Mencari fatwa...")
+	fb.sendTyping(chatID)
 
-	var results []Fatwa
-	query = strings.ToLower(query)
+	results := runSearch(fatwas, index, query, searchType)
 
-	for _, fatwa := range fb.fatwas {
-		var match bool
+	// A misspelled query that found nothing but near matches is corrected
+	// and run again, keeping the original results if that finds nothing
+	typed := ""
+	if (searchType == "keyword" || searchType == "title") && (len(results) == 0 || results[0].Fuzzy) {
+		if corrected, ok := index.correctQuery(query); ok {
+			if correctedResults := runSearch(fatwas, index, corrected, searchType); len(correctedResults) > 0 {
+				typed, query, results = query, corrected, correctedResults
+			}
+		}
+	}
 
-		switch searchType {
-		case "title":
-			match = strings.Contains(strings.ToLower(fatwa.Title), query)
-		case "category":
-			match = strings.Contains(strings.ToLower(fatwa.Category), query)
-		case "keyword":
-			match = strings.Contains(strings.ToLower(fatwa.Title), query) ||
-				strings.Contains(strings.ToLower(fatwa.Content), query)
+	// Log the query as typed, with the results it found after correcting
+	if searchType != "regex" {
+		logged := query
+		if typed != "" {
+			logged = typed
 		}
+		fb.logQuery(chatID, logged, searchType, len(results))
+	}
 
-		if match {
-			results = append(results, fatwa)
+	if category != "" {
+		results = inCategory(results, category)
+		if len(results) == 0 {
+			fb.reply(chatID, "search.none_in_category", query, category)
+			return
 		}
 	}
 
 	if len(results) == 0 {
-		fb.sendMessage(chatID, fmt.Sprintf("❌ Tiada fatwa dijumpai untuk: *%s*", query))
+		fb.sendNoResults(chatID, query, searchType, index)
 		return
 	}
 
-	// Limit results to avoid message being too long
-	maxResults := 10
-	if len(results) > maxResults {
-		results = results[:maxResults]
-	}
-
-	fb.sendSearchResults(chatID, results, query, len(results) < len(fb.fatwas))
-}
-
-func (fb *FatwaBot) sendSearchResults(chatID int64, results []Fatwa, query string, isLimited bool) {
-	message := fmt.Sprintf("🔍 *Hasil carian untuk: %s*\n\n", query)
+	// Remember the search so the page buttons can run it again
+	session := fb.startSearchSession(chatID, query, typed, searchType, category)
 
-	if isLimited && len(results) >= 10 {
-		message += "📝 *Paparan 10 hasil pertama*\n\n"
-	}
+	message, keyboard := searchResultsPage(results, session, 0, newHighlighter(query, searchType, index), fb.language(chatID))
 
-	// Create inline keyboard
-	var keyboard [][]tgbotapi.InlineKeyboardButton
+	msg := tgbotapi.NewMessage(chatID, message)
+	msg.ParseMode = tgbotapi.ModeHTML
+	msg.ReplyMarkup = keyboard
+	fb.send(msg)
+}
 
-	for i, fatwa := range results {
-		// Add result text
-		message += fmt.Sprintf("*%d. %s*\n", i+1, fatwa.Title)
-		message += fmt.Sprintf("📅 %s | 👁 %d views\n", fatwa.Date, fatwa.Hits)
+func (fb *FatwaBot) sendFatwaDetails(chatID int64, fatwa Fatwa) {
+	fb.replaceStatusWithDetails(chatID, 0, fatwa)
+}
 
-		// Show preview of content (first 100 characters)
-		preview := fatwa.Content
-		if len(preview) > 100 {
-			preview = preview[:100] + "..."
-		}
-		message += fmt.Sprintf("📄 %s\n\n", preview)
+// replaceStatusWithDetails sends the details of a fatwa, replacing a
+// status message (see replaceStatus). Long fatwas start at their first
+// part, with buttons that page through the rest in the same message.
+func (fb *FatwaBot) replaceStatusWithDetails(chatID int64, statusID int, fatwa Fatwa) {
+	text, keyboard := fb.detailsPart(chatID, fatwa, 1, "")
+	fb.replaceStatus(chatID, statusID, text, &keyboard)
+}
 
-		// Add inline button for this fatwa
-		button := tgbotapi.NewInlineKeyboardButtonData(
-			fmt.Sprintf("📖 Baca Fatwa %d", i+1),
-			fmt.Sprintf("view_%d", fatwa.ID),
-		)
-		keyboard = append(keyboard, []tgbotapi.InlineKeyboardButton{button})
+// detailsPart formats part number part of a fatwa's details. Fatwas too
+// long for one message are split into parts of readingPartLength; the
+// first part carries the header, the last the link and related fatwas,
+// and every part has the buttons to its neighbours. Parts out of range
+// are clamped to the first or last. search is the hash of the search the
+// fatwa was opened from, if any, which votes on it are recorded against.
+func (fb *FatwaBot) detailsPart(chatID int64, fatwa Fatwa, part int, search string) (string, tgbotapi.InlineKeyboardMarkup) {
+	lang := fb.language(chatID)
+
+	header := fmt.Sprintf("📖 <b>%s</b>\n\n", html.EscapeString(fatwa.Title))
+	header += tr(lang, "details.id", fatwa.Key()) + "\n"
+	header += tr(lang, "details.date", fatwa.Date) + "\n"
+	header += tr(lang, "details.views", fatwa.Hits) + "\n"
+	header += tr(lang, "details.category", fatwa.Category) + "\n"
+	if updated, ok := fb.lastUpdated(fatwa.Key()); ok {
+		header += tr(lang, "details.updated", updated.Format("02/01/2006")) + "\n"
 	}
+	header += "\n"
 
-	msg := tgbotapi.NewMessage(chatID, message)
-	msg.ParseMode = "Markdown"
+	content := fatwa.Content
 
-	if len(keyboard) > 0 {
-		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(keyboard...)
+	// Groups get an excerpt in one message rather than the whole fatwa
+	if isGroupChat(chatID) {
+		content = plainExcerpt(fatwa.Content, groupExcerptLength)
 	}
 
-	fb.bot.Send(msg)
-}
+	footer := "\n\n" + tr(lang, "details.link", fatwa.URL)
 
-func (fb *FatwaBot) sendFatwaDetails(chatID int64, fatwa Fatwa) {
-	// Split content into chunks if it's too long
-	const maxMessageLength = 4096
+	// Suggest where to continue reading
+	related := fb.relatedFatwas(fatwa.Key())
+	if len(related) > 0 {
+		footer += "\n\n" + tr(lang, "details.related")
+	}
 
-	header := fmt.Sprintf("📖 *%s*\n\n", fatwa.Title)
-	header += fmt.Sprintf("🆔 ID: %d\n", fatwa.ID)
-	header += fmt.Sprintf("📅 Tarikh: %s\n", fatwa.Date)
-	header += fmt.Sprintf("👁 Paparan: %d\n", fatwa.Hits)
-	header += fmt.Sprintf("📂 Kategori: %s\n\n", fatwa.Category)
+	if fullMessage := header + html.EscapeString(content) + footer; len(fullMessage) <= maxMessageLength {
+		return fullMessage, fb.detailsButtons(fatwa.Key(), related, search, lang)
+	}
 
-	content := fatwa.Content
-	footer := fmt.Sprintf("\n\n🔗 [Baca penuh di laman web](%s)", fatwa.URL)
+	// Split content into parts, escaping each so no entity is cut in two
+	parts := fb.splitText(content, readingPartLength)
+	part = min(max(part, 1), len(parts))
 
-	// Check if we need to split the message
-	fullMessage := header + content + footer
+	text := fmt.Sprintf("📖 <b>%s</b>\n\n", html.EscapeString(fatwa.Title))
+	if part == 1 {
+		text = header
+	}
+	text += tr(lang, "details.part", part, len(parts)) + "\n\n" + html.EscapeString(parts[part-1])
 
-	if len(fullMessage) <= maxMessageLength {
-		// Send as single message
-		msg := tgbotapi.NewMessage(chatID, fullMessage)
-		msg.ParseMode = "Markdown"
-		msg.DisableWebPagePreview = true
-		fb.bot.Send(msg)
+	if part == len(parts) {
+		text += footer
 	} else {
-		// Send header first
-		msg := tgbotapi.NewMessage(chatID, header)
-		msg.ParseMode = "Markdown"
-		fb.bot.Send(msg)
-
-		// Split content into chunks
-		contentChunks := fb.splitText(content, maxMessageLength-200) // Leave space for formatting
+		related = nil
+	}
 
-		for i, chunk := range contentChunks {
-			chunkMsg := fmt.Sprintf("📄 *Bahagian %d/%d*\n\n%s", i+1, len(contentChunks), chunk)
-			msg := tgbotapi.NewMessage(chatID, chunkMsg)
-			msg.ParseMode = "Markdown"
-			fb.bot.Send(msg)
-		}
+	// Fatwas in many parts can also be read in one go as a file
+	rows := [][]tgbotapi.InlineKeyboardButton{partButtons(fatwa.Key(), part, len(parts), search, lang)}
+	if len(parts) >= fileMinParts {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(fileButton(fatwa.Key(), lang)))
+	}
+	keyboard := fb.detailsButtons(fatwa.Key(), related, search, lang)
+	keyboard.InlineKeyboard = append(rows, keyboard.InlineKeyboard...)
+	return text, keyboard
+}
 
-		// Send footer with link
-		msg = tgbotapi.NewMessage(chatID, footer)
-		msg.ParseMode = "Markdown"
-		msg.DisableWebPagePreview = true
-		fb.bot.Send(msg)
+// detailsButtons are the buttons under fatwa details, after the last part
+// of the content, followed by the related fatwas. Listening, translation
+// and summaries are offered only when their services are set up. 👍/👎
+// collect whether the fatwa helped the search it was opened from.
+func (fb *FatwaBot) detailsButtons(key FatwaKey, related []Fatwa, search, lang string) tgbotapi.InlineKeyboardMarkup {
+	rows := [][]tgbotapi.InlineKeyboardButton{tgbotapi.NewInlineKeyboardRow(
+		saveButton(key, lang),
+		shareButton(key, lang),
+		pdfButton(key, lang),
+	)}
+	var services []tgbotapi.InlineKeyboardButton
+	if fb.tts != nil {
+		services = append(services, listenButton(key, lang))
+	}
+	if fb.translator != nil {
+		services = append(services, translateButton(key, lang))
+	}
+	if fb.llm != nil {
+		services = append(services, summaryButton(key, lang))
 	}
+	if len(services) > 0 {
+		rows = append(rows, services)
+	}
+	feedback := append(tgbotapi.NewInlineKeyboardRow(similarButton(key, lang), reportButton(key, lang)), feedbackButtons(key, search)...)
+	rows = append(rows, feedback)
+	return tgbotapi.NewInlineKeyboardMarkup(append(rows, relatedButtons(related)...)...)
 }
 
 func (fb *FatwaBot) splitText(text string, maxLength int) []string {
@@ -380,56 +753,207 @@ func (fb *FatwaBot) splitText(text string, maxLength int) []string {
 	return chunks
 }
 
+// categoriesPerPage is the number of category buttons shown per message.
+const categoriesPerPage = 10
+
+// showCategories offers a button per category that searches it, so users
+// don't have to type category names. Categories of a series are grouped
+// under it, one level down.
 func (fb *FatwaBot) showCategories(chatID int64) {
-	categories := make(map[string]int)
+	message, keyboard := fb.categoriesPage(0, fb.language(chatID))
 
-	for _, fatwa := range fb.fatwas {
-		categories[fatwa.Category]++
+	msg := tgbotapi.NewMessage(chatID, message)
+	msg.ParseMode = tgbotapi.ModeHTML
+	if len(keyboard.InlineKeyboard) > 0 {
+		msg.ReplyMarkup = keyboard
 	}
+	fb.send(msg)
+}
 
-	message := "📂 *Kategori Fatwa Yang Tersedia:*\n\n"
+// showCategoriesPage replaces a categories message with another page of
+// categories. offsetStr is the offset from the page button.
+func (fb *FatwaBot) showCategoriesPage(message *tgbotapi.Message, offsetStr string) {
+	offset, err := strconv.Atoi(offsetStr)
+	if err != nil || offset < 0 {
+		fb.reply(message.Chat.ID, "error.page")
+		return
+	}
 
-	for category, count := range categories {
-		message += fmt.Sprintf("• %s (%d)\n", category, count)
+	text, keyboard := fb.categoriesPage(offset, fb.language(message.Chat.ID))
+	edit := tgbotapi.NewEditMessageTextAndMarkup(message.Chat.ID, message.MessageID, text, keyboard)
+	edit.ParseMode = tgbotapi.ModeHTML
+	fb.send(edit)
+}
+
+// categoriesPage formats the page of the top level of the category tree
+// starting at offset, with ⬅️/➡️ buttons to the neighbouring pages.
+// Offsets past the end, from buttons sent before the categories changed,
+// show the last page.
+func (fb *FatwaBot) categoriesPage(offset int, lang string) (string, tgbotapi.InlineKeyboardMarkup) {
+	nodes := categoryTree(fb.datasetCategories())
+	if offset >= len(nodes) {
+		offset = max(len(nodes)-1, 0) / categoriesPerPage * categoriesPerPage
 	}
+	end := min(offset+categoriesPerPage, len(nodes))
 
-	message += "\n💡 *Cara mencari berdasarkan kategori:*\n"
-	message += "`/category [nama kategori]`\n\n"
-	message += "*Contoh:* `/category irsyad`"
+	message := tr(lang, "categories.title") + "\n\n"
+	if len(nodes) > categoriesPerPage {
+		message += tr(lang, "categories.range", offset+1, end, len(nodes)) + "\n\n"
+	}
+	message += tr(lang, "categories.pick") + "\n\n"
+	message += tr(lang, "categories.hint")
 
-	msg := tgbotapi.NewMessage(chatID, message)
-	msg.ParseMode = "Markdown"
-	fb.bot.Send(msg)
+	keyboard := categoryNodeButtons(nodes[offset:end])
+
+	var navigation []tgbotapi.InlineKeyboardButton
+	if offset > 0 {
+		navigation = append(navigation, tgbotapi.NewInlineKeyboardButtonData(
+			tr(lang, "button.previous"),
+			fmt.Sprintf("catpage_%d", max(offset-categoriesPerPage, 0)),
+		))
+	}
+	if end < len(nodes) {
+		navigation = append(navigation, tgbotapi.NewInlineKeyboardButtonData(
+			tr(lang, "button.next"),
+			fmt.Sprintf("catpage_%d", end),
+		))
+	}
+	if len(navigation) > 0 {
+		keyboard = append(keyboard, navigation)
+	}
+
+	return message, tgbotapi.InlineKeyboardMarkup{InlineKeyboard: keyboard}
+}
+
+// showFatwaByID sends the fatwa with the given ID, as shown in its details
+// ("123" or "source:123").
+func (fb *FatwaBot) showFatwaByID(chatID int64, idStr string) {
+	idStr = strings.TrimSpace(idStr)
+	if idStr == "" {
+		fb.reply(chatID, "id.missing")
+		return
+	}
+
+	key, err := parseFatwaKey(idStr)
+	if err != nil {
+		fb.reply(chatID, "id.invalid")
+		return
+	}
+
+	fatwa, ok := fb.findFatwa(key)
+	if !ok {
+		fb.reply(chatID, "id.not_found", key)
+		return
+	}
+
+	fb.sendFatwaDetails(chatID, fatwa)
+}
+
+func (fb *FatwaBot) findFatwa(key FatwaKey) (Fatwa, bool) {
+	for _, fatwa := range fb.dataset() {
+		if fatwa.Key() == key {
+			return fatwa, true
+		}
+	}
+	return Fatwa{}, false
 }
 
 func (fb *FatwaBot) sendMessage(chatID int64, text string) {
 	msg := tgbotapi.NewMessage(chatID, text)
-	msg.ParseMode = "Markdown"
-	fb.bot.Send(msg)
+	msg.ParseMode = tgbotapi.ModeHTML
+	fb.send(msg)
 }
 
-func loadFatwaData(filename string) ([]Fatwa, error) {
-	file, err := os.Open(filename)
+// sendStatus sends a message saying that a slow action is under way, to be
+// edited into its outcome with replaceStatus rather than left in the chat.
+// It returns the message's ID, or 0 if it couldn't be sent.
+func (fb *FatwaBot) sendStatus(chatID int64, id string, args ...any) int {
+	msg := tgbotapi.NewMessage(chatID, fb.t(chatID, id, args...))
+	msg.ParseMode = tgbotapi.ModeHTML
+	sent, err := fb.trySend(msg)
 	if err != nil {
-		return nil, fmt.Errorf("cannot open CSV file: %v", err)
+		log.Printf("Error sending status: %v", err)
+		return 0
+	}
+	return sent.MessageID
+}
+
+// replaceStatus edits a status message into text, with buttons unless
+// keyboard is nil. Without a status message, or if it can't be edited any
+// more, text is sent as a new message.
+func (fb *FatwaBot) replaceStatus(chatID int64, statusID int, text string, keyboard *tgbotapi.InlineKeyboardMarkup) {
+	if statusID != 0 {
+		edit := tgbotapi.NewEditMessageText(chatID, statusID, text)
+		edit.ParseMode = tgbotapi.ModeHTML
+		edit.DisableWebPagePreview = true
+		edit.ReplyMarkup = keyboard
+		if _, err := fb.trySend(edit); err == nil {
+			return
+		}
+	}
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = tgbotapi.ModeHTML
+	msg.DisableWebPagePreview = true
+	if keyboard != nil {
+		msg.ReplyMarkup = *keyboard
+	}
+	fb.send(msg)
+}
+
+// sendTyping shows the bot as typing in a chat until its next message, or
+// for five seconds, instead of a status message that lingers in the chat.
+func (fb *FatwaBot) sendTyping(chatID int64) {
+	fb.bot.Request(tgbotapi.NewChatAction(chatID, tgbotapi.ChatTyping))
+}
+
+func (fb *FatwaBot) isAdmin(user *tgbotapi.User) bool {
+	return user != nil && fb.admins[user.ID]
+}
+
+// parseAdminIDs parses a comma-separated list of Telegram user IDs.
+func parseAdminIDs(value string) map[int64]bool {
+	admins := make(map[int64]bool)
+	for _, part := range strings.Split(value, ",") {
+		id, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+		if err == nil {
+			admins[id] = true
+		}
 	}
-	defer file.Close()
+	return admins
+}
 
-	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
+func loadFatwaData(filename string) ([]Fatwa, error) {
+	input, err := openDataset(filename)
 	if err != nil {
-		return nil, fmt.Errorf("cannot read CSV file: %v", err)
+		return nil, fmt.Errorf("cannot open CSV file: %v", err)
 	}
+	defer input.Close()
 
-	if len(records) < 2 {
-		return nil, fmt.Errorf("CSV file must have at least header and one data row")
+	// Stream records one at a time instead of holding the whole file as
+	// [][]string alongside the parsed structs
+	reader := csv.NewReader(input)
+	reader.ReuseRecord = true
+
+	if _, err := reader.Read(); err != nil {
+		if err == io.EOF {
+			return nil, fmt.Errorf("CSV file must have at least header and one data row")
+		}
+		return nil, fmt.Errorf("cannot read CSV file: %v", err)
 	}
 
-	var fatwas []Fatwa
+	fatwas := make([]Fatwa, 0, estimateRows(filename))
+	rows := 0
 
-	// Skip header row
-	for i := 1; i < len(records); i++ {
-		record := records[i]
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot read CSV file: %v", err)
+		}
+		rows++
 		if len(record) < 7 {
 			continue // Skip invalid records
 		}
@@ -445,14 +969,115 @@ func loadFatwaData(filename string) ([]Fatwa, error) {
 			Hits:     hits,
 			Category: record[5],
 			Content:  record[6],
+			Source:   defaultSource,
+		}
+
+		// Datasets written before multi-source support have no Source column
+		if len(record) > 7 && record[7] != "" {
+			fatwa.Source = record[7]
 		}
 
 		fatwas = append(fatwas, fatwa)
 	}
 
+	if rows == 0 {
+		return nil, fmt.Errorf("CSV file must have at least header and one data row")
+	}
+
 	return fatwas, nil
 }
 
+// estimateRows guesses the number of records in a dataset from its size so
+// the fatwa slice can be allocated once. Rows with content average a few
+// kilobytes; compressed files are assumed to shrink about fourfold.
+func estimateRows(filename string) int {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return 0
+	}
+
+	size := info.Size()
+	if isGzipFile(filename) {
+		size *= 4
+	}
+	return int(size/4096) + 16
+}
+
+// datasetPath returns the fatwa dataset location, which may be a plain
+// .csv file or a gzip-compressed .csv.gz file.
+func datasetPath() string {
+	if path := os.Getenv("DATASET_PATH"); path != "" {
+		return path
+	}
+	return "fatwa.csv"
+}
+
+func isGzipFile(filename string) bool {
+	return strings.HasSuffix(strings.ToLower(filename), ".gz")
+}
+
+// gzipFile closes both the decompressor and the underlying file.
+type gzipFile struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (g *gzipFile) Close() error {
+	g.Reader.Close()
+	return g.file.Close()
+}
+
+// openDataset opens a CSV dataset for reading, transparently decompressing
+// .csv.gz files.
+func openDataset(filename string) (io.ReadCloser, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isGzipFile(filename) {
+		return file, nil
+	}
+
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("invalid gzip data: %v", err)
+	}
+	return &gzipFile{Reader: gzipReader, file: file}, nil
+}
+
+// loadDataset reads the fatwas from the SQLite database when DATABASE_PATH
+// is set, otherwise from the CSV dataset.
+func loadDataset() ([]Fatwa, error) {
+	if os.Getenv("DATABASE_PATH") != "" {
+		db, err := openDatabase(databasePath(), datasetMigrations)
+		if err != nil {
+			return nil, err
+		}
+		defer db.Close()
+		return loadFatwasFromDB(db)
+	}
+	return loadFatwaData(datasetPath())
+}
+
+// saveDataset stores freshly scraped fatwas in the active backend.
+func saveDataset(fatwas []Fatwa) error {
+	if os.Getenv("DATABASE_PATH") != "" {
+		db, err := openDatabase(databasePath(), datasetMigrations)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		if err := saveFatwasToDB(db, fatwas); err != nil {
+			return err
+		}
+		fmt.Printf("Database '%s' updated successfully with %d records\n", databasePath(), len(fatwas))
+		return nil
+	}
+	return exportToCSV(fatwas, datasetPath())
+}
+
 func isLastDayOfMonth() bool {
 	now := time.Now()
 	tomorrow := now.AddDate(0, 0, 1)
@@ -461,6 +1086,11 @@ func isLastDayOfMonth() bool {
 
 // Option 1: Single page scraping with content extraction
 func singlePageScraping() {
+	scrapeMu.Lock()
+	defer scrapeMu.Unlock()
+	scrapeStarted.Store(time.Now().Unix())
+	defer scrapeStarted.Store(0)
+
 	// Get the token
 	muftiwpURL := os.Getenv("MUFTIWP_URL")
 	if muftiwpURL == "" {
@@ -479,6 +1109,13 @@ func singlePageScraping() {
 		return
 	}
 
+	// The listing can link the same fatwa through different URLs
+	scraped := len(articles)
+	articles = dedupeByURL(articles)
+	if removed := scraped - len(articles); removed > 0 {
+		fmt.Printf("Removed %d duplicate articles\n", removed)
+	}
+
 	// Extract content for each article
 	fmt.Println("Extracting content from each article...")
 	for i := range articles {
@@ -495,12 +1132,47 @@ func singlePageScraping() {
 		time.Sleep(1 * time.Second)
 	}
 
-	err = exportToCSV(articles, "fatwa.csv")
+	// Merge the scraped fatwas into the dataset by key, as imports are, so
+	// imported fatwas and other sources are kept. Archive the previous
+	// content of any fatwa that changed on the site, and note the fatwas
+	// that are new for /new
+	dataset := articles
+	previous, err := loadDataset()
+	if err == nil {
+		merged, added, updated, _ := mergeFatwas(previous, articles)
+		fmt.Printf("Merged %d new and %d updated articles into %d\n", added, updated, len(previous))
+		dataset = merged
+
+		if err := saveNewFatwas(previous, dataset, newFatwasFile); err != nil {
+			log.Printf("Error recording new fatwas: %v", err)
+		}
+
+		changed, err := recordContentChanges(previous, dataset, "fatwa_history.csv")
+		if err != nil {
+			log.Printf("Error recording content history: %v", err)
+		} else if changed > 0 {
+			fmt.Printf("Archived previous content of %d changed articles\n", changed)
+		}
+	}
+
+	err = saveDataset(dataset)
 	if err != nil {
-		log.Fatalf("Error exporting to CSV: %v", err)
+		log.Fatalf("Error saving dataset: %v", err)
 	}
 
-	fmt.Printf("Successfully scraped %d articles with content and exported to fatwa.csv\n", len(articles))
+	fmt.Printf("Successfully scraped %d articles with content\n", len(articles))
+
+	// Keep a timestamped snapshot so a bad scrape can be rolled back
+	if path, err := saveSnapshot(dataset); err != nil {
+		log.Printf("Error saving dataset snapshot: %v", err)
+	} else {
+		fmt.Printf("Saved dataset snapshot %s\n", path)
+	}
+
+	// Upload a backup of the fresh dataset if a bucket is configured
+	if err := backupDataset(); err != nil {
+		log.Printf("Error backing up dataset: %v", err)
+	}
 }
 
 func scrapeArticles(url string) ([]Fatwa, error) {
@@ -591,12 +1263,8 @@ func scrapeArticles(url string) ([]Fatwa, error) {
 				article.Title = strings.TrimSpace(titleElement.Text())
 				href, exists := titleElement.Attr("href")
 				if exists {
-					// Convert relative URL to absolute URL
-					if strings.HasPrefix(href, "/") {
-						article.URL = "https://www.muftiwp.gov.my" + href
-					} else {
-						article.URL = href
-					}
+					// Convert relative URL to absolute, canonical URL
+					article.URL = canonicalURL(absoluteURL(url, href))
 				}
 			}
 
@@ -654,8 +1322,9 @@ func scrapeArticles(url string) ([]Fatwa, error) {
 				}
 			}
 
-			// Set category
+			// Set category and source
 			article.Category = "Irsyad Hukum - Umum"
+			article.Source = defaultSource
 
 			// Only add if we have essential data
 			if article.Title != "" && article.URL != "" {
@@ -680,6 +1349,15 @@ func scrapeArticles(url string) ([]Fatwa, error) {
 
 // New function to extract article content from individual article pages
 func extractArticleContent(url string) (string, error) {
+	doc, err := fetchArticlePage(url)
+	if err != nil {
+		return "", err
+	}
+	return articleContent(doc)
+}
+
+// fetchArticlePage downloads and parses an article page.
+func fetchArticlePage(url string) (*goquery.Document, error) {
 	// Create HTTP client with timeout
 	client := &http.Client{
 		Timeout: 30 * time.Second,
@@ -688,7 +1366,7 @@ func extractArticleContent(url string) (string, error) {
 	// Make HTTP request
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return "", fmt.Errorf("error creating request: %v", err)
+		return nil, fmt.Errorf("error creating request: %v", err)
 	}
 
 	// Set headers to mimic a real browser
@@ -699,12 +1377,12 @@ func extractArticleContent(url string) (string, error) {
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("error making request: %v", err)
+		return nil, fmt.Errorf("error making request: %v", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("status code error: %d %s", resp.StatusCode, resp.Status)
+		return nil, fmt.Errorf("status code error: %d %s", resp.StatusCode, resp.Status)
 	}
 
 	// Handle gzip compression
@@ -712,7 +1390,7 @@ func extractArticleContent(url string) (string, error) {
 	if resp.Header.Get("Content-Encoding") == "gzip" {
 		gzipReader, err := gzip.NewReader(resp.Body)
 		if err != nil {
-			return "", fmt.Errorf("error creating gzip reader: %v", err)
+			return nil, fmt.Errorf("error creating gzip reader: %v", err)
 		}
 		defer gzipReader.Close()
 		reader = gzipReader
@@ -721,9 +1399,14 @@ func extractArticleContent(url string) (string, error) {
 	// Parse HTML document
 	doc, err := goquery.NewDocumentFromReader(reader)
 	if err != nil {
-		return "", fmt.Errorf("error parsing HTML: %v", err)
+		return nil, fmt.Errorf("error parsing HTML: %v", err)
 	}
 
+	return doc, nil
+}
+
+// articleContent extracts the cleaned-up body text of an article page.
+func articleContent(doc *goquery.Document) (string, error) {
 	// Extract content from div with itemprop="articleBody"
 	articleBody := doc.Find("div[itemprop='articleBody']")
 	if articleBody.Length() == 0 {
@@ -763,18 +1446,37 @@ func extractArticleContent(url string) (string, error) {
 	return content, nil
 }
 
-func exportToCSV(articles []Fatwa, filename string) error {
-	file, err := os.Create(filename)
+func exportToCSV(articles []Fatwa, filename string) (err error) {
+	// Write to a temp file in the same directory and rename it over the
+	// target, so a crash or full disk never leaves a truncated dataset
+	file, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".*.tmp")
 	if err != nil {
-		return fmt.Errorf("cannot create CSV file: %v", err)
+		return fmt.Errorf("cannot create temp CSV file: %v", err)
+	}
+	tmpName := file.Name()
+	defer func() {
+		if err != nil {
+			file.Close()
+			os.Remove(tmpName)
+		}
+	}()
+
+	// Hash the bytes as written so a checksum manifest can be stored
+	// alongside the dataset and verified when it is loaded
+	hasher := sha256.New()
+	var output io.Writer = io.MultiWriter(file, hasher)
+
+	// Compress the output when the target is a .csv.gz file
+	var gzipWriter *gzip.Writer
+	if isGzipFile(filename) {
+		gzipWriter = gzip.NewWriter(output)
+		output = gzipWriter
 	}
-	defer file.Close()
 
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
+	writer := csv.NewWriter(output)
 
 	// Write CSV header - now includes Content column
-	header := []string{"ID", "Title", "URL", "Date", "Hits", "Category", "Content"}
+	header := []string{"ID", "Title", "URL", "Date", "Hits", "Category", "Content", "Source"}
 	if err := writer.Write(header); err != nil {
 		return fmt.Errorf("error writing CSV header: %v", err)
 	}
@@ -789,12 +1491,53 @@ func exportToCSV(articles []Fatwa, filename string) error {
 			strconv.Itoa(article.Hits),
 			article.Category,
 			article.Content, // New content field
+			article.Source,
 		}
 		if err := writer.Write(record); err != nil {
 			return fmt.Errorf("error writing CSV record: %v", err)
 		}
 	}
 
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("error flushing CSV file: %v", err)
+	}
+
+	if gzipWriter != nil {
+		if err := gzipWriter.Close(); err != nil {
+			return fmt.Errorf("error compressing CSV file: %v", err)
+		}
+	}
+
+	// CreateTemp makes the file private; give the dataset the usual
+	// permissions before it replaces the old one
+	if err := file.Chmod(0644); err != nil {
+		return fmt.Errorf("error setting CSV file permissions: %v", err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("error syncing CSV file: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("error closing CSV file: %v", err)
+	}
+
+	// Write the manifest first, so the new dataset is never in place
+	// without it; an interrupted export then fails its check instead of
+	// being accepted unverified
+	if err := writeChecksumManifest(filename, hex.EncodeToString(hasher.Sum(nil))); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpName, filename); err != nil {
+		return fmt.Errorf("error replacing CSV file: %v", err)
+	}
+
+	// Persist the rename itself; not all platforms support syncing directories
+	if dir, err := os.Open(filepath.Dir(filename)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+
 	fmt.Printf("CSV file '%s' created successfully with %d records\n", filename, len(articles))
 	return nil
 }
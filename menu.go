@@ -0,0 +1,73 @@
+package main
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// menuButtons lays out the main menu under the message box, by message ID
+// of each button's label.
+var menuButtons = [][]string{
+	{"menu.search", "menu.categories"},
+	{"menu.popular", "menu.bookmarks", "menu.help"},
+}
+
+// mainMenu is the reply keyboard with the bot's primary actions, so users
+// don't need to remember commands. It stays open until replaced.
+func mainMenu(lang string) tgbotapi.ReplyKeyboardMarkup {
+	var rows [][]tgbotapi.KeyboardButton
+	for _, row := range menuButtons {
+		var buttons []tgbotapi.KeyboardButton
+		for _, id := range row {
+			buttons = append(buttons, tgbotapi.NewKeyboardButton(tr(lang, id)))
+		}
+		rows = append(rows, buttons)
+	}
+
+	keyboard := tgbotapi.NewReplyKeyboard(rows...)
+	keyboard.InputFieldPlaceholder = tr(lang, "menu.placeholder")
+	return keyboard
+}
+
+// menuAction returns the message ID of the menu button a text was sent by.
+// Labels of every language are recognized, as a keyboard sent before a
+// language change keeps its old labels until replaced.
+func menuAction(text string) (string, bool) {
+	for _, lang := range languageCodes() {
+		for _, row := range menuButtons {
+			for _, id := range row {
+				if text == tr(lang, id) {
+					return id, true
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+// sendMenu sends a message in a chat's language with the main menu
+// attached. Groups get the message alone, as the keyboard would open for
+// every member and its buttons aren't commands the bot receives there.
+func (fb *FatwaBot) sendMenu(chatID int64, id string) {
+	msg := tgbotapi.NewMessage(chatID, fb.t(chatID, id))
+	msg.ParseMode = tgbotapi.ModeHTML
+	if !isGroupChat(chatID) {
+		msg.ReplyMarkup = mainMenu(fb.language(chatID))
+	}
+	fb.send(msg)
+}
+
+// handleMenu runs the action of a main menu button.
+func (fb *FatwaBot) handleMenu(chatID int64, action string) {
+	switch action {
+	case "menu.search":
+		fb.reply(chatID, "menu.search_prompt")
+	case "menu.categories":
+		fb.showCategories(chatID)
+	case "menu.popular":
+		fb.browse(chatID, "", "popular")
+	case "menu.bookmarks":
+		fb.showBookmarks(chatID)
+	case "menu.help":
+		fb.sendHelpMessage(chatID)
+	}
+}
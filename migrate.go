@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// malformedRow describes a CSV row that could not be imported.
+type malformedRow struct {
+	Line   int
+	Reason string
+}
+
+// runMigrate imports an existing CSV dataset into the SQLite database,
+// reporting malformed rows and verifying the stored row count afterwards.
+func runMigrate(args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	csvPath := flags.String("csv", datasetPath(), "CSV dataset to import")
+	dbPath := flags.String("db", databasePath(), "SQLite database to import into")
+	flags.Parse(args)
+
+	fatwas, malformed, err := readFatwaCSV(*csvPath)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Read %d valid rows from %s\n", len(fatwas), *csvPath)
+	if len(malformed) > 0 {
+		fmt.Printf("Skipped %d malformed rows:\n", len(malformed))
+		for _, row := range malformed {
+			fmt.Printf("  line %d: %s\n", row.Line, row.Reason)
+		}
+	}
+
+	if len(fatwas) == 0 {
+		return fmt.Errorf("no valid rows to import")
+	}
+
+	db, err := openDatabase(*dbPath, datasetMigrations)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := saveFatwasToDB(db, fatwas); err != nil {
+		return err
+	}
+
+	// Verify that every valid row made it into the database
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM fatwas").Scan(&count); err != nil {
+		return fmt.Errorf("cannot count imported fatwas: %v", err)
+	}
+	if count != len(fatwas) {
+		return fmt.Errorf("imported %d fatwas but database contains %d", len(fatwas), count)
+	}
+
+	fmt.Printf("Successfully migrated %d fatwas into %s\n", count, *dbPath)
+	return nil
+}
+
+// readFatwaCSV reads a dataset strictly, returning the valid fatwas along
+// with a description of every row that was rejected.
+func readFatwaCSV(filename string) ([]Fatwa, []malformedRow, error) {
+	input, err := openDataset(filename)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot open CSV file: %v", err)
+	}
+	defer input.Close()
+
+	reader := csv.NewReader(input)
+	reader.FieldsPerRecord = -1
+
+	var fatwas []Fatwa
+	var malformed []malformedRow
+	seen := make(map[FatwaKey]int)
+	seenURLs := make(map[string]int)
+
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				malformed = append(malformed, malformedRow{Line: parseErr.Line, Reason: parseErr.Err.Error()})
+				continue
+			}
+			return nil, nil, fmt.Errorf("cannot read CSV file: %v", err)
+		}
+
+		// Skip header row
+		if first {
+			continue
+		}
+
+		line, _ := reader.FieldPos(0)
+
+		if len(record) < 7 {
+			malformed = append(malformed, malformedRow{Line: line, Reason: fmt.Sprintf("expected 7 fields, got %d", len(record))})
+			continue
+		}
+
+		id, err := strconv.Atoi(record[0])
+		if err != nil || id <= 0 {
+			malformed = append(malformed, malformedRow{Line: line, Reason: fmt.Sprintf("invalid ID %q", record[0])})
+			continue
+		}
+
+		key := FatwaKey{Source: defaultSource, ID: id}
+		if len(record) > 7 && record[7] != "" {
+			key.Source = record[7]
+		}
+
+		if firstLine, exists := seen[key]; exists {
+			malformed = append(malformed, malformedRow{Line: line, Reason: fmt.Sprintf("duplicate ID %s (first seen on line %d)", key, firstLine)})
+			continue
+		}
+
+		hits := 0
+		if record[4] != "" {
+			hits, err = strconv.Atoi(record[4])
+			if err != nil {
+				malformed = append(malformed, malformedRow{Line: line, Reason: fmt.Sprintf("invalid hits %q", record[4])})
+				continue
+			}
+		}
+
+		if strings.TrimSpace(record[1]) == "" || strings.TrimSpace(record[2]) == "" {
+			malformed = append(malformed, malformedRow{Line: line, Reason: "missing title or URL"})
+			continue
+		}
+
+		url := canonicalURL(record[2])
+		if firstLine, exists := seenURLs[url]; exists {
+			malformed = append(malformed, malformedRow{Line: line, Reason: fmt.Sprintf("duplicate URL %s (first seen on line %d)", url, firstLine)})
+			continue
+		}
+
+		seen[key] = line
+		seenURLs[url] = line
+		fatwas = append(fatwas, Fatwa{
+			ID:       id,
+			Title:    record[1],
+			URL:      url,
+			Date:     record[3],
+			Hits:     hits,
+			Category: record[5],
+			Content:  record[6],
+			Source:   key.Source,
+		})
+	}
+
+	return fatwas, malformed, nil
+}
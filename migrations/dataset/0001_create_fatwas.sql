@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS fatwas (
+	id       INTEGER PRIMARY KEY,
+	title    TEXT NOT NULL,
+	url      TEXT NOT NULL,
+	date     TEXT NOT NULL DEFAULT '',
+	hits     INTEGER NOT NULL DEFAULT 0,
+	category TEXT NOT NULL DEFAULT '',
+	content  TEXT NOT NULL DEFAULT ''
+);
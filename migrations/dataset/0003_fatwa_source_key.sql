@@ -0,0 +1,18 @@
+-- Key fatwas by (source, id) so numeric IDs from different sources can coexist
+CREATE TABLE fatwas_new (
+	source   TEXT NOT NULL DEFAULT 'muftiwp',
+	id       INTEGER NOT NULL,
+	title    TEXT NOT NULL,
+	url      TEXT NOT NULL,
+	date     TEXT NOT NULL DEFAULT '',
+	hits     INTEGER NOT NULL DEFAULT 0,
+	category TEXT NOT NULL DEFAULT '',
+	content  TEXT NOT NULL DEFAULT '',
+	PRIMARY KEY (source, id)
+);
+
+INSERT INTO fatwas_new (source, id, title, url, date, hits, category, content)
+	SELECT 'muftiwp', id, title, url, date, hits, category, content FROM fatwas ORDER BY rowid;
+
+DROP TABLE fatwas;
+ALTER TABLE fatwas_new RENAME TO fatwas;
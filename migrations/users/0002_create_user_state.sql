@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS users (
+	chat_id    INTEGER PRIMARY KEY,
+	language   TEXT NOT NULL DEFAULT '',
+	last_query TEXT NOT NULL DEFAULT '',
+	created_at TEXT NOT NULL,
+	updated_at TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS bookmarks (
+	chat_id    INTEGER NOT NULL,
+	fatwa_id   INTEGER NOT NULL,
+	created_at TEXT NOT NULL,
+	PRIMARY KEY (chat_id, fatwa_id)
+);
+
+CREATE TABLE IF NOT EXISTS subscriptions (
+	chat_id    INTEGER NOT NULL,
+	category   TEXT NOT NULL,
+	created_at TEXT NOT NULL,
+	PRIMARY KEY (chat_id, category)
+);
@@ -0,0 +1,2 @@
+-- Bookmarks now store the "source:id" key of the fatwa
+UPDATE bookmarks SET fatwa_id = 'muftiwp:' || fatwa_id WHERE typeof(fatwa_id) = 'integer';
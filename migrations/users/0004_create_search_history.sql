@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS search_history (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	chat_id     INTEGER NOT NULL,
+	query       TEXT NOT NULL,
+	search_type TEXT NOT NULL,
+	searched_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS search_history_chat ON search_history (chat_id, id);
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS alerts (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	chat_id    INTEGER NOT NULL,
+	query      TEXT NOT NULL,
+	created_at TEXT NOT NULL,
+	UNIQUE (chat_id, query)
+);
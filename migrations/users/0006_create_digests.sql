@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS digests (
+	chat_id    INTEGER PRIMARY KEY,
+	frequency  TEXT NOT NULL,
+	hour       INTEGER NOT NULL,
+	last_sent  TEXT NOT NULL DEFAULT '',
+	created_at TEXT NOT NULL
+);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS query_log (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	query       TEXT NOT NULL,
+	search_type TEXT NOT NULL,
+	results     INTEGER NOT NULL,
+	logged_at   TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS query_log_time ON query_log (logged_at);
+
+CREATE TABLE IF NOT EXISTS activity (
+	day     TEXT NOT NULL,
+	chat_id INTEGER NOT NULL,
+	PRIMARY KEY (day, chat_id)
+);
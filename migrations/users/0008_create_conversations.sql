@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS conversations (
+	chat_id     INTEGER PRIMARY KEY,
+	state       TEXT NOT NULL,
+	search_type TEXT NOT NULL DEFAULT '',
+	category    TEXT NOT NULL DEFAULT '',
+	updated_at  TEXT NOT NULL
+);
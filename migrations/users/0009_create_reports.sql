@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS reports (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	chat_id     INTEGER NOT NULL,
+	source      TEXT NOT NULL,
+	fatwa_id    INTEGER NOT NULL,
+	outcome     TEXT NOT NULL DEFAULT '',
+	reported_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS reports_fatwa ON reports (source, fatwa_id, reported_at);
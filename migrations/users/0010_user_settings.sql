@@ -0,0 +1,3 @@
+-- Display preferences chosen with /settings; zero values mean the defaults
+ALTER TABLE users ADD COLUMN page_size INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN preview TEXT NOT NULL DEFAULT '';
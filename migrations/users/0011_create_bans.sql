@@ -0,0 +1,6 @@
+-- Chats and users banned by an admin; their updates are dropped
+CREATE TABLE IF NOT EXISTS bans (
+	chat_id    INTEGER PRIMARY KEY,
+	banned_by  INTEGER NOT NULL,
+	created_at TEXT NOT NULL
+);
@@ -0,0 +1,9 @@
+-- Each user's first answer to each /kuiz question, for their score
+CREATE TABLE IF NOT EXISTS quiz_answers (
+	user_id     INTEGER NOT NULL,
+	source      TEXT NOT NULL,
+	fatwa_id    INTEGER NOT NULL,
+	correct     INTEGER NOT NULL,
+	answered_at TEXT NOT NULL,
+	PRIMARY KEY (user_id, source, fatwa_id)
+);
@@ -0,0 +1,12 @@
+-- Chats that receive the fatwa of the day
+CREATE TABLE IF NOT EXISTS daily_fatwa_chats (
+	chat_id    INTEGER PRIMARY KEY,
+	created_at TEXT NOT NULL
+);
+
+-- The fatwa picked on each day, so picks aren't repeated
+CREATE TABLE IF NOT EXISTS daily_fatwas (
+	day      TEXT PRIMARY KEY,
+	source   TEXT NOT NULL,
+	fatwa_id INTEGER NOT NULL
+);
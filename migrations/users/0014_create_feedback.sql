@@ -0,0 +1,13 @@
+-- 👍/👎 votes on fatwas, with the search that led to them, so ranking and
+-- synonyms can be tuned for searches whose results didn't help
+CREATE TABLE IF NOT EXISTS feedback (
+	chat_id  INTEGER NOT NULL,
+	source   TEXT NOT NULL,
+	fatwa_id INTEGER NOT NULL,
+	query    TEXT NOT NULL,
+	helpful  INTEGER NOT NULL,
+	voted_at TEXT NOT NULL,
+	PRIMARY KEY (chat_id, source, fatwa_id, query)
+);
+
+CREATE INDEX IF NOT EXISTS feedback_time ON feedback (voted_at);
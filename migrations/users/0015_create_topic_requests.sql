@@ -0,0 +1,10 @@
+-- Searches that found nothing and that users asked to be covered, for the
+-- admin report of content gaps
+CREATE TABLE IF NOT EXISTS topic_requests (
+	chat_id      INTEGER NOT NULL,
+	query        TEXT NOT NULL,
+	requested_at TEXT NOT NULL,
+	PRIMARY KEY (chat_id, query)
+);
+
+CREATE INDEX IF NOT EXISTS topic_requests_time ON topic_requests (requested_at);
@@ -0,0 +1,11 @@
+-- Chat model summaries of fatwas, per interface language, kept until the
+-- fatwa's content changes so each is only paid for once
+CREATE TABLE IF NOT EXISTS summaries (
+	source     TEXT NOT NULL,
+	fatwa_id   INTEGER NOT NULL,
+	language   TEXT NOT NULL,
+	checksum   TEXT NOT NULL,
+	summary    TEXT NOT NULL,
+	created_at TEXT NOT NULL,
+	PRIMARY KEY (source, fatwa_id, language)
+);
@@ -0,0 +1,7 @@
+-- Texts admins replace from the chat, such as the /sokong message, over the
+-- bot's defaults
+CREATE TABLE IF NOT EXISTS bot_texts (
+	name       TEXT PRIMARY KEY,
+	text       TEXT NOT NULL,
+	updated_at TEXT NOT NULL
+);
@@ -0,0 +1,8 @@
+-- Fatwas added by a scrape that users have already been told about, so
+-- reloading, rolling back or importing never announces them again
+CREATE TABLE IF NOT EXISTS notified_fatwas (
+	source      TEXT NOT NULL,
+	fatwa_id    INTEGER NOT NULL,
+	notified_at TEXT NOT NULL,
+	PRIMARY KEY (source, fatwa_id)
+);
@@ -0,0 +1,15 @@
+-- Daily activity identifies chats by an HMAC of their ID instead of the ID,
+-- so it can't be tied back to users. Rows recorded before are hashed when
+-- the store is opened, as SQL can't compute the HMAC.
+CREATE TABLE IF NOT EXISTS store_keys (
+	name TEXT PRIMARY KEY,
+	key  BLOB NOT NULL
+);
+
+ALTER TABLE activity RENAME TO activity_legacy;
+
+CREATE TABLE activity (
+	day       TEXT NOT NULL,
+	chat_hash TEXT NOT NULL,
+	PRIMARY KEY (day, chat_hash)
+);
@@ -0,0 +1,3 @@
+-- The chat behind each logged query, as a keyed hash like daily activity,
+-- so /trending only lists queries searched by several chats
+ALTER TABLE query_log ADD COLUMN chat_hash TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,12 @@
+-- Machine translations of fatwas into English, kept until the fatwa's
+-- content changes so each is only paid for once, like summaries
+CREATE TABLE IF NOT EXISTS translations (
+	source     TEXT NOT NULL,
+	fatwa_id   INTEGER NOT NULL,
+	checksum   TEXT NOT NULL,
+	title      TEXT NOT NULL,
+	content    TEXT NOT NULL,
+	truncated  INTEGER NOT NULL,
+	created_at TEXT NOT NULL,
+	PRIMARY KEY (source, fatwa_id)
+);
@@ -0,0 +1,11 @@
+-- Telegram file IDs of the voice notes reading fatwas aloud, one per part,
+-- kept until the text read changes so each is only synthesized once
+CREATE TABLE IF NOT EXISTS voice_notes (
+	source     TEXT NOT NULL,
+	fatwa_id   INTEGER NOT NULL,
+	part       INTEGER NOT NULL,
+	checksum   TEXT NOT NULL,
+	file_id    TEXT NOT NULL,
+	created_at TEXT NOT NULL,
+	PRIMARY KEY (source, fatwa_id, part)
+);
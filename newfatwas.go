@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+)
+
+// newFatwasFile records the fatwas added by the latest scrape, for /new.
+const newFatwasFile = "new_fatwas.json"
+
+// scrapeAdditions are the fatwas a scrape added to the dataset.
+type scrapeAdditions struct {
+	ScrapedAt time.Time  `json:"scraped_at"`
+	Fatwas    []FatwaKey `json:"fatwas"`
+}
+
+// saveNewFatwas records which of the scraped fatwas weren't in the
+// previous dataset. It replaces the file in one rename, so /new never
+// reads it half written.
+func saveNewFatwas(previous, current []Fatwa, filename string) error {
+	additions := scrapeAdditions{ScrapedAt: time.Now(), Fatwas: []FatwaKey{}}
+	for key := range addedFatwas(previous, current) {
+		additions.Fatwas = append(additions.Fatwas, key)
+	}
+	sort.Slice(additions.Fatwas, func(a, b int) bool {
+		if additions.Fatwas[a].Source != additions.Fatwas[b].Source {
+			return additions.Fatwas[a].Source < additions.Fatwas[b].Source
+		}
+		return additions.Fatwas[a].ID < additions.Fatwas[b].ID
+	})
+
+	data, err := json.MarshalIndent(additions, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot encode new fatwas: %v", err)
+	}
+	if err := os.WriteFile(filename+".tmp", data, 0644); err != nil {
+		return fmt.Errorf("cannot write new fatwas: %v", err)
+	}
+	if err := os.Rename(filename+".tmp", filename); err != nil {
+		return fmt.Errorf("cannot write new fatwas: %v", err)
+	}
+	return nil
+}
+
+// loadNewFatwas reads the fatwas added by the latest scrape.
+func loadNewFatwas(filename string) (scrapeAdditions, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return scrapeAdditions{}, err
+	}
+	var additions scrapeAdditions
+	if err := json.Unmarshal(data, &additions); err != nil {
+		return scrapeAdditions{}, fmt.Errorf("cannot read new fatwas: %v", err)
+	}
+	return additions, nil
+}
+
+// showNewFatwas lists the fatwas added by the latest scrape, paged like
+// search results, so returning users see what changed since their last
+// visit.
+func (fb *FatwaBot) showNewFatwas(chatID int64) {
+	additions, err := loadNewFatwas(newFatwasFile)
+	if os.IsNotExist(err) {
+		fb.reply(chatID, "new.not_scraped")
+		return
+	}
+	if err != nil {
+		log.Printf("Error loading new fatwas: %v", err)
+		fb.reply(chatID, "new.error")
+		return
+	}
+
+	scraped := additions.ScrapedAt.Format("02/01/2006")
+	if len(additions.Fatwas) == 0 {
+		fb.reply(chatID, "new.none", scraped)
+		return
+	}
+	fb.browse(chatID, scraped, "new")
+}
+
+// scrapedSince returns the fatwas added by the latest scrape if it
+// finished after since, so users only hear about fatwas that came from the
+// website: rollbacks, imports and reloads of an older scrape don't count.
+func scrapedSince(since time.Time) (scrapeAdditions, bool) {
+	additions, err := loadNewFatwas(newFatwasFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Error loading new fatwas: %v", err)
+		}
+		return scrapeAdditions{}, false
+	}
+	if !additions.ScrapedAt.After(since) || len(additions.Fatwas) == 0 {
+		return scrapeAdditions{}, false
+	}
+	return additions, true
+}
+
+// notifyScraped tells the bot's users and the new fatwas channel about the
+// fatwas a scrape added, skipping any already announced, as recorded in
+// the store. A channel post can't be taken back, so it goes by the same
+// record.
+func (fb *FatwaBot) notifyScraped(keys []FatwaKey) {
+	claimed, err := fb.store.ClaimNotifications(keys)
+	if err != nil {
+		log.Printf("Error recording notified fatwas: %v", err)
+		return
+	}
+	if len(claimed) == 0 {
+		return
+	}
+
+	added := make(map[FatwaKey]bool, len(claimed))
+	for _, key := range claimed {
+		added[key] = true
+	}
+	fb.goSafe(0, func() { fb.notifyAlerts(added) })
+	fb.goSafe(0, func() { fb.notifySubscribers(added) })
+	fb.goSafe(0, func() { fb.postNewFatwas(added) })
+}
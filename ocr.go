@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// ocrMaxSize is the largest image downloaded for text recognition.
+	ocrMaxSize = 10 << 20
+
+	// ocrQueryTerms is the number of words of the recognized text that are
+	// searched for. Forwarded questions come with greetings and chatter,
+	// so only the most distinctive words are kept.
+	ocrQueryTerms = 8
+
+	// ocrShownLength is how much of the recognized text is shown back.
+	ocrShownLength = 200
+)
+
+// ocrConfig holds the settings for reading the text in images with the
+// Google Cloud Vision API.
+type ocrConfig struct {
+	APIKey   string
+	Endpoint string
+}
+
+// loadOCRConfig reads the text recognition settings from the environment.
+// Image search is disabled unless OCR_API_KEY is set.
+func loadOCRConfig() (*ocrConfig, bool) {
+	cfg := &ocrConfig{
+		APIKey:   os.Getenv("OCR_API_KEY"),
+		Endpoint: os.Getenv("OCR_ENDPOINT"),
+	}
+
+	if cfg.APIKey == "" {
+		return nil, false
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "https://vision.googleapis.com/v1/images:annotate"
+	}
+
+	return cfg, true
+}
+
+// recognize returns the text in an image, expected to be in Malay,
+// English or Arabic.
+func (cfg *ocrConfig) recognize(image []byte) (string, error) {
+	body, err := json.Marshal(map[string]any{
+		"requests": []map[string]any{{
+			"image":        map[string]string{"content": base64.StdEncoding.EncodeToString(image)},
+			"features":     []map[string]string{{"type": "TEXT_DETECTION"}},
+			"imageContext": map[string]any{"languageHints": []string{"ms", "en", "ar"}},
+		}},
+	})
+	if err != nil {
+		return "", fmt.Errorf("cannot encode text recognition request: %v", err)
+	}
+
+	req, err := http.NewRequest("POST", cfg.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("cannot create text recognition request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Goog-Api-Key", cfg.APIKey)
+
+	client := &http.Client{Timeout: 60 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("cannot request text recognition: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("text recognition request failed: %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+
+	var result struct {
+		Responses []struct {
+			FullTextAnnotation struct {
+				Text string `json:"text"`
+			} `json:"fullTextAnnotation"`
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		} `json:"responses"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("cannot decode text recognition response: %v", err)
+	}
+	if len(result.Responses) == 0 {
+		return "", nil
+	}
+	if message := result.Responses[0].Error.Message; message != "" {
+		return "", fmt.Errorf("text recognition failed: %s", message)
+	}
+	return strings.TrimSpace(result.Responses[0].FullTextAnnotation.Text), nil
+}
+
+// ocrQuery turns the text of an image into a search for its most
+// distinctive words that fatwas contain, rarest first, joined with OR so
+// that fatwas matching the most of them rank first. Words no fatwa
+// contains, such as names and misread words, are dropped.
+func ocrQuery(text string, index *searchIndex) string {
+	stemmer := index.malayStemmer()
+	total := float64(len(index.ContentLengths))
+
+	type term struct {
+		Word string
+		IDF  float64
+	}
+	var terms []term
+	seen := make(map[string]bool)
+	for _, word := range removeStopwords(tokenize(normalizeSearchText(text))) {
+		root := stemmer.stem(word)
+		docs := float64(len(index.ContentRoots[root]))
+		if seen[root] || docs == 0 || utf8.RuneCountInString(word) < 3 {
+			continue
+		}
+		seen[root] = true
+		terms = append(terms, term{Word: word, IDF: math.Log(1 + (total-docs+0.5)/(docs+0.5))})
+	}
+	sort.SliceStable(terms, func(a, b int) bool { return terms[a].IDF > terms[b].IDF })
+
+	var words []string
+	for _, t := range terms[:min(len(terms), ocrQueryTerms)] {
+		words = append(words, t.Word)
+	}
+	return strings.Join(words, " OR ")
+}
+
+// searchByImage reads the text in a photo or screenshot, such as a
+// forwarded question, and searches for it. The text found is shown
+// first, so users can see what was searched for.
+func (fb *FatwaBot) searchByImage(chatID int64, fileID, caption string) {
+	if fb.ocr == nil {
+		fb.reply(chatID, "ocr.disabled")
+		return
+	}
+
+	fb.sendTyping(chatID)
+
+	image, err := fb.downloadFile(fileID, ocrMaxSize)
+	if err != nil {
+		log.Printf("Error downloading image: %v", err)
+		fb.reply(chatID, "ocr.error")
+		return
+	}
+
+	text, err := fb.ocr.recognize(image)
+	if err != nil {
+		log.Printf("Error recognizing text: %v", err)
+		fb.reply(chatID, "ocr.error")
+		return
+	}
+
+	// A caption is usually the user's own question about the image
+	text = strings.TrimSpace(caption + "\n" + text)
+	_, index := fb.searchData()
+	query := ocrQuery(text, index)
+	if query == "" {
+		fb.reply(chatID, "ocr.empty")
+		return
+	}
+
+	shown := strings.Join(strings.Fields(text), " ")
+	if utf8.RuneCountInString(shown) > ocrShownLength {
+		shown = string([]rune(shown)[:ocrShownLength]) + "..."
+	}
+	fb.reply(chatID, "ocr.read", shown)
+	fb.searchFatwas(chatID, query, "keyword")
+}
+
+// imageFileID returns the file of a photo, at its largest size, or of an
+// image sent as a file, if a message has one.
+func imageFileID(message *tgbotapi.Message) (string, bool) {
+	if len(message.Photo) > 0 {
+		for i := len(message.Photo) - 1; i >= 0; i-- {
+			if message.Photo[i].FileSize <= ocrMaxSize {
+				return message.Photo[i].FileID, true
+			}
+		}
+		return "", false
+	}
+	if message.Document != nil && strings.HasPrefix(message.Document.MimeType, "image/") {
+		return message.Document.FileID, true
+	}
+	return "", false
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// outboxInterval spaces out every message a bot sends, to stay under
+	// Telegram's limit of about 30 messages per second across all chats.
+	outboxInterval = time.Second / 30
+
+	// privateBulkInterval and groupBulkInterval space out bulk messages to
+	// the same chat, under Telegram's limits of about a message a second in
+	// a private chat and 20 a minute in a group or channel.
+	privateBulkInterval = time.Second
+	groupBulkInterval   = 3 * time.Second
+)
+
+// outbox paces everything a bot sends through trySend, so broadcasts,
+// digests and notifications can't run into Telegram's flood limits.
+// Interactive replies go before bulk messages waiting for their turn, and
+// only bulk messages are paced per chat, as replies come in short bursts
+// that Telegram allows. When Telegram asks the bot to slow down, every
+// message waits as long as it says.
+type outbox struct {
+	mu          sync.Mutex
+	next        time.Time            // when the next message may be sent
+	pausedUntil time.Time            // when Telegram allows sending again
+	chats       map[string]time.Time // when the next bulk message may go to each chat
+	interactive int                  // interactive messages waiting for their turn
+}
+
+func newOutbox() *outbox {
+	return &outbox{chats: make(map[string]time.Time)}
+}
+
+// wait blocks until a message to chat may be sent, and takes its turn.
+func (o *outbox) wait(chat string, bulk bool) {
+	if !bulk {
+		o.mu.Lock()
+		o.interactive++
+		o.mu.Unlock()
+		defer func() {
+			o.mu.Lock()
+			o.interactive--
+			o.mu.Unlock()
+		}()
+	}
+
+	for {
+		o.mu.Lock()
+		now := time.Now()
+		at := o.next
+		if o.pausedUntil.After(at) {
+			at = o.pausedUntil
+		}
+		if bulk && o.chats[chat].After(at) {
+			at = o.chats[chat]
+		}
+
+		if !at.After(now) && (!bulk || o.interactive == 0) {
+			o.next = now.Add(outboxInterval)
+			if bulk {
+				o.chats[chat] = now.Add(bulkInterval(chat))
+			}
+			o.mu.Unlock()
+			return
+		}
+		o.mu.Unlock()
+
+		delay := at.Sub(now)
+		if delay <= 0 {
+			delay = outboxInterval
+		}
+		time.Sleep(delay)
+	}
+}
+
+// pause holds every message back for as long as Telegram asked.
+func (o *outbox) pause(d time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if until := time.Now().Add(d); until.After(o.pausedUntil) {
+		o.pausedUntil = until
+	}
+}
+
+// sweep forgets the chats whose bulk messages are no longer held back.
+func (o *outbox) sweep(now time.Time) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for chat, next := range o.chats {
+		if !next.After(now) {
+			delete(o.chats, chat)
+		}
+	}
+}
+
+// bulkInterval is the time between bulk messages to a chat, given as a
+// numeric ID, negative for groups and channels, or a channel's @username.
+func bulkInterval(chat string) time.Duration {
+	if strings.HasPrefix(chat, "-") || strings.HasPrefix(chat, "@") {
+		return groupBulkInterval
+	}
+	return privateBulkInterval
+}
+
+// outboxChat returns the chat a message, edit or file goes to, or "" if it
+// isn't one the outbox knows.
+func outboxChat(c tgbotapi.Chattable) string {
+	var base tgbotapi.BaseChat
+	switch c := c.(type) {
+	case tgbotapi.MessageConfig:
+		base = c.BaseChat
+	case tgbotapi.EditMessageTextConfig:
+		base = tgbotapi.BaseChat{ChatID: c.ChatID, ChannelUsername: c.ChannelUsername}
+	case tgbotapi.DocumentConfig:
+		base = c.BaseChat
+	case tgbotapi.VoiceConfig:
+		base = c.BaseChat
+	case tgbotapi.PhotoConfig:
+		base = c.BaseChat
+	case tgbotapi.InvoiceConfig:
+		base = c.BaseChat
+	default:
+		return ""
+	}
+
+	if base.ChannelUsername != "" {
+		return base.ChannelUsername
+	}
+	return strconv.FormatInt(base.ChatID, 10)
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"html"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// resultsPerPage is the number of search results shown per message.
+const resultsPerPage = 10
+
+// searchSession is the latest search of a chat, kept so its results can be
+// paged through. Callback data is limited to 64 bytes, too short for the
+// query itself, so buttons carry a hash of the search instead. Buttons of
+// other searches no longer match and are reported as expired. Typed is the query
+// as the user typed it, when its spelling was corrected into Query. Results
+// are limited to Category when it is set. Compact sessions, those of group
+// chats, show fewer results per page and no snippets. Settings are the
+// chat's display preferences when the search started. Used is when the
+// search was started or its buttons last tapped; it expires after
+// sessionTTL.
+type searchSession struct {
+	Query    string
+	Typed    string
+	Type     string
+	Category string
+	Sort     string
+	Compact  bool
+	Settings Settings
+	Used     time.Time
+}
+
+// pageSize is the number of results shown per message of a session.
+func (s searchSession) pageSize() int {
+	if s.Compact {
+		return groupResultsPerPage
+	}
+	if s.Settings.PageSize > 0 {
+		return s.Settings.PageSize
+	}
+	return resultsPerPage
+}
+
+func (fb *FatwaBot) startSearchSession(chatID int64, query, typed, searchType, category string) searchSession {
+	settings := fb.settings(chatID)
+
+	fb.sessionsMu.Lock()
+	defer fb.sessionsMu.Unlock()
+
+	session := searchSession{
+		Query:    query,
+		Typed:    typed,
+		Type:     searchType,
+		Category: category,
+		Sort:     sortRelevance,
+		Compact:  isGroupChat(chatID),
+		Settings: settings,
+		Used:     time.Now(),
+	}
+	fb.sessions[chatID] = session
+	return session
+}
+
+// hash identifies the search of a session in callback data: its query,
+// type and category, but not its order, which buttons can change.
+func (s searchSession) hash() string {
+	h := fnv.New32a()
+	h.Write([]byte(s.Type + "\x00" + s.Category + "\x00" + s.Query))
+	return fmt.Sprintf("%08x", h.Sum32())
+}
+
+// setSessionSort changes the order of a chat's search, if it is still the
+// latest one.
+func (fb *FatwaBot) setSessionSort(chatID int64, hash, mode string) (searchSession, bool) {
+	fb.sessionsMu.Lock()
+	defer fb.sessionsMu.Unlock()
+
+	session, ok := fb.liveSession(chatID)
+	if !ok || session.hash() != hash {
+		return searchSession{}, false
+	}
+	session.Sort = mode
+	fb.sessions[chatID] = session
+	return session, true
+}
+
+// sessionResults runs a session's search again, in the session's order.
+func (fb *FatwaBot) sessionResults(session searchSession) []searchResult {
+	fatwas, index := fb.searchData()
+	results := runSearch(fatwas, index, session.Query, session.Type)
+	if session.Category != "" {
+		results = inCategory(results, session.Category)
+	}
+	sortResults(results, session.Sort)
+	return results
+}
+
+func (fb *FatwaBot) searchSession(chatID int64) (searchSession, bool) {
+	fb.sessionsMu.Lock()
+	defer fb.sessionsMu.Unlock()
+
+	return fb.liveSession(chatID)
+}
+
+// liveSession returns a chat's search unless it has expired, marking it
+// used. The caller must hold sessionsMu.
+func (fb *FatwaBot) liveSession(chatID int64) (searchSession, bool) {
+	session, ok := fb.sessions[chatID]
+	if !ok {
+		return searchSession{}, false
+	}
+	if time.Since(session.Used) > sessionTTL {
+		delete(fb.sessions, chatID)
+		return searchSession{}, false
+	}
+	session.Used = time.Now()
+	fb.sessions[chatID] = session
+	return session, true
+}
+
+// showResultsPage replaces a results message with the page starting at
+// offset of the same search, given by its hash from the page button.
+func (fb *FatwaBot) showResultsPage(message *tgbotapi.Message, hash string, offset int) {
+	chatID := message.Chat.ID
+
+	session, ok := fb.searchSession(chatID)
+	if !ok || session.hash() != hash {
+		fb.reply(chatID, "search.expired")
+		return
+	}
+
+	results := fb.sessionResults(session)
+	if offset >= len(results) {
+		fb.reply(chatID, "search.expired")
+		return
+	}
+
+	fb.editResultsMessage(message, results, session, offset)
+}
+
+// sortResultsPage re-orders a results message, given the hash of its search
+// and the order from the sort button, and returns it to the first page.
+func (fb *FatwaBot) sortResultsPage(message *tgbotapi.Message, hash, mode string) {
+	chatID := message.Chat.ID
+
+	if !isSortMode(mode) {
+		fb.reply(chatID, "error.sort")
+		return
+	}
+
+	session, ok := fb.setSessionSort(chatID, hash, mode)
+	if !ok {
+		fb.reply(chatID, "search.expired")
+		return
+	}
+
+	results := fb.sessionResults(session)
+	if len(results) == 0 {
+		fb.reply(chatID, "search.expired")
+		return
+	}
+
+	fb.editResultsMessage(message, results, session, 0)
+}
+
+func (fb *FatwaBot) editResultsMessage(message *tgbotapi.Message, results []searchResult, session searchSession, offset int) {
+	_, index := fb.searchData()
+	text, keyboard := searchResultsPage(results, session, offset, newHighlighter(session.Query, session.Type, index), fb.language(message.Chat.ID))
+
+	edit := tgbotapi.NewEditMessageTextAndMarkup(message.Chat.ID, message.MessageID, text, keyboard)
+	edit.ParseMode = tgbotapi.ModeHTML
+	fb.send(edit)
+}
+
+// searchResultsPage formats the page of results starting at offset, with a
+// button to read each fatwa, ⬅️/➡️ buttons to the neighbouring pages, a
+// row to change the order and, when there is more than a page, a button to
+// download the whole list, in the chat's language.
+func searchResultsPage(results []searchResult, session searchSession, offset int, highlight *highlighter, lang string) (string, tgbotapi.InlineKeyboardMarkup) {
+	end := min(offset+session.pageSize(), len(results))
+	page := results[offset:end]
+
+	message := tr(lang, "results.title", session.Query) + "\n\n"
+	if isBrowseType(session.Type) {
+		message = tr(lang, "results.browse", browseTitle(session.Query, session.Type, lang)) + "\n\n"
+	}
+	if session.Category != "" {
+		message += tr(lang, "results.in_category", session.Category) + "\n\n"
+	}
+	if session.Typed != "" {
+		message += tr(lang, "results.corrected", session.Typed) + "\n\n"
+	}
+	if highlight.reading != "" {
+		message += tr(lang, "results.reading", highlight.reading) + "\n\n"
+	}
+
+	if len(results) > session.pageSize() {
+		message += tr(lang, "results.range", offset+1, end, len(results)) + "\n\n"
+	}
+	if len(results) > 1 {
+		message += tr(lang, "results.sorted", tr(lang, sortName(session.Sort))) + "\n\n"
+	}
+
+	for _, result := range page {
+		if result.Fuzzy {
+			message += tr(lang, "results.fuzzy") + "\n\n"
+			break
+		}
+	}
+
+	// Show the content around the first match, with matches in bold
+	listing := func(radius int) string {
+		var list string
+		for i, result := range page {
+			fatwa := result.Fatwa
+			list += fmt.Sprintf("<b>%d. %s</b>\n", offset+i+1, html.EscapeString(fatwa.Title))
+			if result.Fuzzy {
+				list += tr(lang, "results.close") + "\n"
+			}
+			list += tr(lang, "results.stats", fatwa.Date, fatwa.Hits) + "\n"
+			if radius > 0 {
+				list += fmt.Sprintf("📄 %s\n", highlight.snippet(fatwa.Content, radius))
+			}
+			list += "\n"
+		}
+		return list
+	}
+
+	// Long previews on a big page may not fit in one message, so they are
+	// shortened until it does, or left out
+	radius := previewRadius(session.Settings.Preview)
+	if session.Compact {
+		radius = 0
+	}
+	list := listing(radius)
+	for radius > 0 && len(message+list) > maxMessageLength {
+		radius /= 2
+		if radius < snippetRadius/4 {
+			radius = 0
+		}
+		list = listing(radius)
+	}
+	message += list
+
+	// Create inline keyboard
+	var keyboard [][]tgbotapi.InlineKeyboardButton
+
+	for i, result := range page {
+		number := offset + i + 1
+		button := tgbotapi.NewInlineKeyboardButtonData(
+			tr(lang, "button.read_fatwa", number),
+			callbackData{Action: "view", Key: result.Fatwa.Key(), Hash: session.hash()}.String(),
+		)
+		keyboard = append(keyboard, []tgbotapi.InlineKeyboardButton{button})
+	}
+
+	var navigation []tgbotapi.InlineKeyboardButton
+	if offset > 0 {
+		navigation = append(navigation, tgbotapi.NewInlineKeyboardButtonData(
+			tr(lang, "button.previous"),
+			callbackData{Action: "page", Page: max(offset-session.pageSize(), 0), Hash: session.hash()}.String(),
+		))
+	}
+	if end < len(results) {
+		navigation = append(navigation, tgbotapi.NewInlineKeyboardButtonData(
+			tr(lang, "button.next"),
+			callbackData{Action: "page", Page: end, Hash: session.hash()}.String(),
+		))
+	}
+	if len(navigation) > 0 {
+		keyboard = append(keyboard, navigation)
+	}
+
+	if len(results) > 1 {
+		keyboard = append(keyboard, sortButtons(session.hash(), session.Sort, lang))
+	}
+	if len(results) > session.pageSize() {
+		keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(resultsListButton(session, lang)))
+	}
+
+	return message, tgbotapi.NewInlineKeyboardMarkup(keyboard...)
+}
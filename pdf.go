@@ -0,0 +1,353 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"html"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/go-pdf/fpdf"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// pdfParagraphLength is about how long a paragraph of the PDF grows
+	// before it breaks at the next sentence, as scraped content has no line
+	// breaks.
+	pdfParagraphLength = 700
+
+	// pdfInlineArabicWords is how many words an Arabic passage may have to
+	// stay within its paragraph. Longer ones, such as verses, are set right
+	// to left in paragraphs of their own, so they read in order when they
+	// wrap.
+	pdfInlineArabicWords = 3
+
+	// pdfFont names the embedded font in the PDF.
+	pdfFont = "DejaVu"
+)
+
+// The PDF is set in DejaVu Sans Condensed, which has the Arabic letters
+// as well as the Latin ones; see fonts/README.md for its license.
+var (
+	//go:embed fonts/DejaVuSansCondensed.ttf
+	pdfFontRegular []byte
+
+	//go:embed fonts/DejaVuSansCondensed-Bold.ttf
+	pdfFontBold []byte
+)
+
+// pdfHeading matches the section labels fatwas are written with, which
+// start a paragraph of their own in the PDF.
+var pdfHeading = regexp.MustCompile(`(?i)\b(soalan|jawapan|mukadimah|pendahuluan|kesimpulan|penutup)\s*:`)
+
+// arabicRun matches a passage of Arabic words, with the spaces, digits and
+// punctuation between them.
+var arabicRun = regexp.MustCompile(`[\p{Arabic}\x{064B}-\x{065F}\x{0670}]+(?:[\s\p{P}\d]+[\p{Arabic}\x{064B}-\x{065F}\x{0670}]+)*`)
+
+// pdfButton is the "📄 PDF" button under fatwa details.
+func pdfButton(key FatwaKey, lang string) tgbotapi.InlineKeyboardButton {
+	return tgbotapi.NewInlineKeyboardButtonData(tr(lang, "button.pdf"), fatwaCallback("pdf", key))
+}
+
+// sendFatwaPDF sends a fatwa as a printable PDF document, given its ID as
+// shown in its details ("123" or "source:123").
+func (fb *FatwaBot) sendFatwaPDF(chatID int64, idStr string) {
+	idStr = strings.TrimSpace(idStr)
+	if idStr == "" {
+		fb.reply(chatID, "pdf.missing")
+		return
+	}
+
+	key, err := parseFatwaKey(idStr)
+	if err != nil {
+		fb.reply(chatID, "id.invalid")
+		return
+	}
+
+	fatwa, ok := fb.findFatwa(key)
+	if !ok {
+		fb.reply(chatID, "id.not_found", key)
+		return
+	}
+
+	lang := fb.language(chatID)
+	data, err := fatwaPDF(fatwa, lang)
+	if err != nil {
+		log.Printf("Error creating PDF: %v", err)
+		fb.reply(chatID, "pdf.error")
+		return
+	}
+
+	fb.bot.Request(tgbotapi.NewChatAction(chatID, tgbotapi.ChatUploadDocument))
+
+	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{
+		Name:  fmt.Sprintf("fatwa-%s-%d.pdf", fatwa.Source, fatwa.ID),
+		Bytes: data,
+	})
+	doc.Caption = fmt.Sprintf("📄 <b>%s</b>", html.EscapeString(fatwa.Title))
+	doc.ParseMode = tgbotapi.ModeHTML
+	if _, err := fb.trySend(doc); err != nil {
+		log.Printf("Error sending PDF: %v", err)
+		fb.reply(chatID, "pdf.error")
+	}
+}
+
+// fatwaPDF renders a fatwa on A4 pages: the title, its metadata, the
+// content in paragraphs and a link to the source. Labels are in the
+// reader's language.
+//
+// PDF viewers don't join Arabic letters or lay them out right to left, so
+// Arabic passages are written in their joined forms and in visual order,
+// see shapeArabic.
+func fatwaPDF(fatwa Fatwa, lang string) ([]byte, error) {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.AddUTF8FontFromBytes(pdfFont, "", pdfFontRegular)
+	pdf.AddUTF8FontFromBytes(pdfFont, "B", pdfFontBold)
+	pdf.SetMargins(20, 20, 20)
+	pdf.SetAutoPageBreak(true, 20)
+	pdf.SetTitle(fatwa.Title, true)
+	pdf.SetCreator("Fatwa Bot", true)
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-15)
+		pdf.SetFont(pdfFont, "", 8)
+		pdf.SetTextColor(128, 128, 128)
+		pdf.CellFormat(0, 10, pdfText(tr(lang, "pdf.page", pdf.PageNo())), "", 0, "C", false, 0, "")
+	})
+	pdf.AddPage()
+
+	pdf.SetFont(pdfFont, "B", 16)
+	pdf.MultiCell(0, 8, pdfText(fatwa.Title), "", "L", false)
+	pdf.Ln(2)
+
+	pdf.SetFont(pdfFont, "", 10)
+	pdf.SetTextColor(96, 96, 96)
+	meta := []string{
+		tr(lang, "pdf.id") + ": " + fatwa.Key().String(),
+		tr(lang, "pdf.date") + ": " + fatwa.Date,
+		tr(lang, "pdf.category") + ": " + fatwa.Category,
+		tr(lang, "pdf.views") + ": " + fmt.Sprint(fatwa.Hits),
+	}
+	for _, line := range meta {
+		pdf.CellFormat(0, 5, pdfText(line), "", 1, "L", false, 0, "")
+	}
+	pdf.Ln(3)
+	pdf.SetDrawColor(192, 192, 192)
+	pdf.Line(20, pdf.GetY(), 190, pdf.GetY())
+	pdf.Ln(5)
+
+	pdf.SetTextColor(0, 0, 0)
+	for _, paragraph := range pdfParagraphs(fatwa.Content) {
+		if heading := pdfHeading.FindStringIndex(paragraph); heading != nil && heading[0] == 0 {
+			pdf.SetFont(pdfFont, "B", 11)
+			pdf.MultiCell(0, 6, pdfText(paragraph[:heading[1]]), "", "L", false)
+			paragraph = strings.TrimSpace(paragraph[heading[1]:])
+		}
+		if paragraph != "" {
+			writePDFParagraph(pdf, paragraph)
+		}
+		pdf.Ln(3)
+	}
+
+	if fatwa.URL != "" {
+		pdf.Ln(2)
+		pdf.SetFont(pdfFont, "B", 10)
+		pdf.Write(5, pdfText(tr(lang, "pdf.source")+": "))
+		pdf.SetFont(pdfFont, "U", 10)
+		pdf.SetTextColor(0, 0, 192)
+		pdf.WriteLinkString(5, pdfText(fatwa.URL), fatwa.URL)
+	}
+
+	if err := pdf.Error(); err != nil {
+		return nil, fmt.Errorf("cannot render PDF: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, fmt.Errorf("cannot render PDF: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// pdfParagraphs splits content into paragraphs: at its line breaks, before
+// section labels such as "Jawapan:", and at the first sentence end after
+// pdfParagraphLength characters.
+func pdfParagraphs(content string) []string {
+	content = pdfHeading.ReplaceAllString(content, "\n$0")
+
+	var paragraphs []string
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		for len(line) > pdfParagraphLength {
+			end := strings.Index(line[pdfParagraphLength:], ". ")
+			if end < 0 {
+				break
+			}
+			end += pdfParagraphLength + 1
+			paragraphs = append(paragraphs, line[:end])
+			line = strings.TrimSpace(line[end:])
+		}
+		if line != "" {
+			paragraphs = append(paragraphs, line)
+		}
+	}
+	return paragraphs
+}
+
+// writePDFParagraph writes a paragraph of content. Arabic passages longer
+// than pdfInlineArabicWords are set apart, right to left.
+func writePDFParagraph(pdf *fpdf.Fpdf, paragraph string) {
+	start := 0
+	for _, run := range arabicRun.FindAllStringIndex(paragraph, -1) {
+		passage := paragraph[run[0]:run[1]]
+		if len(strings.Fields(passage)) <= pdfInlineArabicWords {
+			continue
+		}
+		if latin := strings.TrimSpace(paragraph[start:run[0]]); latin != "" {
+			pdf.SetFont(pdfFont, "", 11)
+			pdf.MultiCell(0, 6, pdfText(latin), "", "J", false)
+		}
+		// In right to left mode each line is reversed once it is wrapped
+		pdf.SetFont(pdfFont, "", 13)
+		pdf.RTL()
+		pdf.MultiCell(0, 8, shapeArabic(removeTashkeel(passage)), "", "R", false)
+		pdf.LTR()
+		start = run[1]
+	}
+	if latin := strings.TrimSpace(paragraph[start:]); latin != "" {
+		pdf.SetFont(pdfFont, "", 11)
+		pdf.MultiCell(0, 6, pdfText(latin), "", "J", false)
+	}
+}
+
+// pdfText prepares text for the PDF. Short Arabic passages within it are
+// joined and put in visual order, and characters outside the Basic
+// Multilingual Plane, such as most emoji, which the font lacks, are
+// dropped.
+func pdfText(text string) string {
+	text = arabicRun.ReplaceAllStringFunc(text, func(passage string) string {
+		runes := []rune(shapeArabic(removeTashkeel(passage)))
+		slices.Reverse(runes)
+		return string(runes)
+	})
+	return strings.Map(func(r rune) rune {
+		if r > 0xFFFF {
+			return -1
+		}
+		return r
+	}, text)
+}
+
+// removeTashkeel drops the vowel marks of Arabic text, which the PDF can't
+// place over their letters.
+func removeTashkeel(text string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= '\u064B' && r <= '\u065F') || r == '\u0670' {
+			return -1
+		}
+		return r
+	}, text)
+}
+
+// arabicForms are the presentation forms of the Arabic letters: isolated,
+// final, initial and medial. Letters with two forms only join the letter
+// before them.
+var arabicForms = map[rune][]rune{
+	'\u0621': {'\uFE80'},
+	'\u0622': {'\uFE81', '\uFE82'},
+	'\u0623': {'\uFE83', '\uFE84'},
+	'\u0624': {'\uFE85', '\uFE86'},
+	'\u0625': {'\uFE87', '\uFE88'},
+	'\u0626': {'\uFE89', '\uFE8A', '\uFE8B', '\uFE8C'},
+	'\u0627': {'\uFE8D', '\uFE8E'},
+	'\u0628': {'\uFE8F', '\uFE90', '\uFE91', '\uFE92'},
+	'\u0629': {'\uFE93', '\uFE94'},
+	'\u062A': {'\uFE95', '\uFE96', '\uFE97', '\uFE98'},
+	'\u062B': {'\uFE99', '\uFE9A', '\uFE9B', '\uFE9C'},
+	'\u062C': {'\uFE9D', '\uFE9E', '\uFE9F', '\uFEA0'},
+	'\u062D': {'\uFEA1', '\uFEA2', '\uFEA3', '\uFEA4'},
+	'\u062E': {'\uFEA5', '\uFEA6', '\uFEA7', '\uFEA8'},
+	'\u062F': {'\uFEA9', '\uFEAA'},
+	'\u0630': {'\uFEAB', '\uFEAC'},
+	'\u0631': {'\uFEAD', '\uFEAE'},
+	'\u0632': {'\uFEAF', '\uFEB0'},
+	'\u0633': {'\uFEB1', '\uFEB2', '\uFEB3', '\uFEB4'},
+	'\u0634': {'\uFEB5', '\uFEB6', '\uFEB7', '\uFEB8'},
+	'\u0635': {'\uFEB9', '\uFEBA', '\uFEBB', '\uFEBC'},
+	'\u0636': {'\uFEBD', '\uFEBE', '\uFEBF', '\uFEC0'},
+	'\u0637': {'\uFEC1', '\uFEC2', '\uFEC3', '\uFEC4'},
+	'\u0638': {'\uFEC5', '\uFEC6', '\uFEC7', '\uFEC8'},
+	'\u0639': {'\uFEC9', '\uFECA', '\uFECB', '\uFECC'},
+	'\u063A': {'\uFECD', '\uFECE', '\uFECF', '\uFED0'},
+	'\u0640': {'\u0640', '\u0640', '\u0640', '\u0640'},
+	'\u0641': {'\uFED1', '\uFED2', '\uFED3', '\uFED4'},
+	'\u0642': {'\uFED5', '\uFED6', '\uFED7', '\uFED8'},
+	'\u0643': {'\uFED9', '\uFEDA', '\uFEDB', '\uFEDC'},
+	'\u0644': {'\uFEDD', '\uFEDE', '\uFEDF', '\uFEE0'},
+	'\u0645': {'\uFEE1', '\uFEE2', '\uFEE3', '\uFEE4'},
+	'\u0646': {'\uFEE5', '\uFEE6', '\uFEE7', '\uFEE8'},
+	'\u0647': {'\uFEE9', '\uFEEA', '\uFEEB', '\uFEEC'},
+	'\u0648': {'\uFEED', '\uFEEE'},
+	'\u0649': {'\uFEEF', '\uFEF0'},
+	'\u064A': {'\uFEF1', '\uFEF2', '\uFEF3', '\uFEF4'},
+}
+
+// lamAlef are the isolated forms of lam followed by each alef, which are
+// written as one letter. Their final forms follow them.
+var lamAlef = map[rune]rune{
+	'\u0622': '\uFEF5',
+	'\u0623': '\uFEF7',
+	'\u0625': '\uFEF9',
+	'\u0627': '\uFEFB',
+}
+
+// shapeArabic replaces the Arabic letters of text with their forms joined
+// to the letters around them, keeping the text in logical order. Letters
+// without presentation forms, such as those only used in Jawi, are left as
+// they are.
+func shapeArabic(text string) string {
+	runes := []rune(text)
+	letterAt := func(i int) ([]rune, bool) {
+		if i < 0 || i >= len(runes) {
+			return nil, false
+		}
+		forms, ok := arabicForms[runes[i]]
+		return forms, ok
+	}
+
+	shaped := make([]rune, 0, len(runes))
+	for i := 0; i < len(runes); i++ {
+		forms, ok := letterAt(i)
+		if !ok {
+			shaped = append(shaped, runes[i])
+			continue
+		}
+		// Only letters with all four forms join the letter after them
+		before, _ := letterAt(i - 1)
+		joined := len(before) == 4
+		_, nextLetter := letterAt(i + 1)
+
+		if runes[i] == '\u0644' && nextLetter {
+			if ligature, ok := lamAlef[runes[i+1]]; ok {
+				if joined {
+					ligature++
+				}
+				shaped = append(shaped, ligature)
+				i++
+				continue
+			}
+		}
+
+		form := 0
+		if joined && len(forms) > 1 {
+			form = 1
+		}
+		if nextLetter && len(forms) == 4 {
+			form += 2
+		}
+		shaped = append(shaped, forms[form])
+	}
+	return string(shaped)
+}
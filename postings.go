@@ -0,0 +1,198 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// posting records that a word root occurs Count times in document Doc.
+// Posting lists are sorted by document.
+type posting struct {
+	Doc   int
+	Count int
+}
+
+// addPosting counts an occurrence of key in doc. Documents are indexed in
+// order, so only the last posting of a list can be for the same document.
+func addPosting(postings map[string][]posting, key string, doc int) {
+	list := postings[key]
+	if n := len(list); n > 0 && list[n-1].Doc == doc {
+		list[n-1].Count++
+		return
+	}
+	postings[key] = append(list, posting{Doc: doc, Count: 1})
+}
+
+func addDoc(docs map[string][]int, key string, doc int) {
+	list := docs[key]
+	if n := len(list); n > 0 && list[n-1] == doc {
+		return
+	}
+	docs[key] = append(list, doc)
+}
+
+func findPosting(list []posting, doc int) (posting, bool) {
+	i := sort.Search(len(list), func(i int) bool { return list[i].Doc >= doc })
+	if i < len(list) && list[i].Doc == doc {
+		return list[i], true
+	}
+	return posting{}, false
+}
+
+func postingDocs(list []posting) []int {
+	docs := make([]int, len(list))
+	for i, p := range list {
+		docs[i] = p.Doc
+	}
+	return docs
+}
+
+// rootFrequency returns how often a root occurs in a document's content.
+func (index *searchIndex) rootFrequency(doc int, root string) int {
+	p, _ := findPosting(index.ContentRoots[root], doc)
+	return p.Count
+}
+
+// hasRoot reports whether a root occurs in a document's title, or in its
+// content too when inContent is set.
+func (index *searchIndex) hasRoot(doc int, root string, inContent bool) bool {
+	if _, ok := findPosting(index.TitleRoots[root], doc); ok {
+		return true
+	}
+	if !inContent {
+		return false
+	}
+	_, ok := findPosting(index.ContentRoots[root], doc)
+	return ok
+}
+
+// rootDocs lists the documents whose title, or content when inContent is
+// set, contains a root.
+func (index *searchIndex) rootDocs(root string, inContent bool) []int {
+	docs := postingDocs(index.TitleRoots[root])
+	if inContent {
+		docs = unionDocs(docs, postingDocs(index.ContentRoots[root]))
+	}
+	return docs
+}
+
+// candidates returns the documents that may match a query, in dataset
+// order, for the matchers in searchIndexed to confirm. A term's candidates
+// are the documents containing all its roots, plus those containing a word
+// the term's longest word is part of, which covers substring matches
+// within words. Substrings spanning several words are not covered.
+func (index *searchIndex) candidates(parsed searchQuery, searchType string) []int {
+	inContent := searchType == "keyword"
+
+	var termDocs func(term queryTerm) []int
+	termDocs = func(term queryTerm) []int {
+		words := tokenize(term.Text)
+
+		var docs []int
+		if term.Phrase {
+			for i, word := range words {
+				if i == 0 {
+					docs = index.WordDocs[word]
+				} else {
+					docs = intersectDocs(docs, index.WordDocs[word])
+				}
+			}
+			return docs
+		}
+
+		for i, root := range term.Roots {
+			if i == 0 {
+				docs = index.rootDocs(root, inContent)
+			} else {
+				docs = intersectDocs(docs, index.rootDocs(root, inContent))
+			}
+		}
+
+		longest := ""
+		for _, word := range words {
+			if len(word) > len(longest) {
+				longest = word
+			}
+		}
+		if longest != "" {
+			for _, word := range index.Vocabulary {
+				if strings.Contains(word, longest) {
+					docs = unionDocs(docs, index.WordDocs[word])
+				}
+			}
+		}
+
+		for _, synonym := range term.Synonyms {
+			docs = unionDocs(docs, termDocs(synonym))
+		}
+		return docs
+	}
+
+	var docs []int
+	for _, group := range parsed.Groups {
+		var groupDocs []int
+		for i, term := range group {
+			if i == 0 {
+				groupDocs = termDocs(term)
+			} else {
+				groupDocs = intersectDocs(groupDocs, termDocs(term))
+			}
+		}
+		docs = unionDocs(docs, groupDocs)
+	}
+	return docs
+}
+
+// wordDocs returns the documents containing any of the given words.
+func (index *searchIndex) wordDocs(words []string) []int {
+	var docs []int
+	for _, word := range words {
+		docs = unionDocs(docs, index.WordDocs[word])
+	}
+	return docs
+}
+
+func intersectDocs(a, b []int) []int {
+	var docs []int
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			docs = append(docs, a[i])
+			i++
+			j++
+		}
+	}
+	return docs
+}
+
+func unionDocs(a, b []int) []int {
+	if len(a) == 0 {
+		return b
+	}
+	if len(b) == 0 {
+		return a
+	}
+
+	docs := make([]int, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] < b[j]:
+			docs = append(docs, a[i])
+			i++
+		case a[i] > b[j]:
+			docs = append(docs, b[j])
+			j++
+		default:
+			docs = append(docs, a[i])
+			i++
+			j++
+		}
+	}
+	docs = append(docs, a[i:]...)
+	return append(docs, b[j:]...)
+}
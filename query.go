@@ -0,0 +1,263 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// queryOperators are the boolean operators understood in search queries,
+// in English and Malay. They must be typed in capitals, so ordinary words
+// such as "dan" or "bukan" in a question are still searched for.
+var queryOperators = map[string]string{
+	"AND":   "and",
+	"DAN":   "and",
+	"OR":    "or",
+	"ATAU":  "or",
+	"NOT":   "not",
+	"BUKAN": "not",
+}
+
+// queryTerm is a piece of a query matched like a plain query: as a
+// substring of the normalized fields, or by finding the roots of all its
+// words, which needn't be next to each other. Phrases were quoted by the
+// user and only match their exact words. Synonyms are variants of the term
+// with colloquial words replaced, any of which may match instead.
+type queryTerm struct {
+	Text     string
+	Roots    []string
+	Phrase   bool
+	Synonyms []queryTerm
+}
+
+// searchQuery is a query in disjunctive form: a fatwa matches when all
+// terms of any one group match, and none of the excluded terms do.
+type searchQuery struct {
+	Groups   [][]queryTerm
+	Excluded []queryTerm
+}
+
+// parseQuery splits a query on AND/OR/NOT (DAN/ATAU/BUKAN). Words between
+// operators form a single term, and quoted text forms a phrase term of its
+// own. A query without operators or quotes is one term.
+func parseQuery(query string, index *searchIndex) searchQuery {
+	var parsed searchQuery
+	var group []queryTerm
+	var words []string
+	negate := false
+
+	add := func(term queryTerm) {
+		if term.Text == "" {
+			return
+		}
+		if negate {
+			parsed.Excluded = append(parsed.Excluded, term)
+		} else {
+			group = append(group, term)
+		}
+		negate = false
+	}
+	flush := func() {
+		if len(words) > 0 {
+			add(newQueryTerm(strings.Join(words, " "), index))
+			words = nil
+		}
+	}
+
+	for _, word := range splitQuery(query) {
+		if word.Quoted {
+			flush()
+			add(queryTerm{Text: normalizeSearchText(strings.TrimSpace(word.Text)), Phrase: true})
+			continue
+		}
+
+		switch queryOperators[word.Text] {
+		case "and":
+			flush()
+		case "or":
+			flush()
+			if len(group) > 0 {
+				parsed.Groups = append(parsed.Groups, group)
+			}
+			group = nil
+		case "not":
+			flush()
+			negate = true
+		default:
+			words = append(words, word.Text)
+		}
+	}
+	flush()
+	if len(group) > 0 {
+		parsed.Groups = append(parsed.Groups, group)
+	}
+
+	return parsed
+}
+
+// newQueryTerm builds a term from the words of a query. Jawi words are
+// also looked for as the Rumi words they spell, as a variant of the term.
+func newQueryTerm(text string, index *searchIndex) queryTerm {
+	stemmer := index.malayStemmer()
+	term := stemmedTerm(queryText(text), stemmer)
+
+	variants := expandSynonyms(term.Text)
+	if rumi, ok := index.transliterateJawi(foldText(strings.TrimSpace(text))); ok {
+		rumi = queryText(rumi)
+		variants = append(variants, rumi)
+		variants = append(variants, expandSynonyms(rumi)...)
+	}
+	for _, variant := range variants {
+		term.Synonyms = append(term.Synonyms, stemmedTerm(variant, stemmer))
+	}
+	return term
+}
+
+// queryText normalizes the text of a term and drops its stopwords, so
+// question-style queries match on the words that carry meaning.
+func queryText(text string) string {
+	text = normalizeSearchText(strings.TrimSpace(text))
+	if words := tokenize(text); len(words) > 1 {
+		if kept := removeStopwords(words); len(kept) < len(words) {
+			text = strings.Join(kept, " ")
+		}
+	}
+	return text
+}
+
+func stemmedTerm(text string, stemmer *malayStemmer) queryTerm {
+	return queryTerm{Text: text, Roots: strings.Fields(stemmer.stemText(text))}
+}
+
+// queryWord is a word of a query, or the text of a quoted phrase.
+type queryWord struct {
+	Text   string
+	Quoted bool
+}
+
+// splitQuery splits a query into words, keeping text between double quotes
+// together. Curly quotes, as inserted by phone keyboards, count as well.
+// An unterminated quote runs to the end of the query.
+func splitQuery(query string) []queryWord {
+	var words []queryWord
+	var current strings.Builder
+	quoted := false
+
+	flush := func() {
+		if current.Len() > 0 || quoted {
+			words = append(words, queryWord{Text: current.String(), Quoted: quoted})
+		}
+		current.Reset()
+	}
+
+	for _, r := range query {
+		switch {
+		case r == '"' || r == '“' || r == '”':
+			flush()
+			quoted = !quoted
+		case unicode.IsSpace(r) && !quoted:
+			flush()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	flush()
+
+	return words
+}
+
+// isPlain reports whether the query is a single unquoted term, the only
+// kind of query typo tolerance applies to.
+func (q searchQuery) isPlain() bool {
+	return len(q.Groups) == 1 && len(q.Groups[0]) == 1 && len(q.Excluded) == 0 && !q.Groups[0][0].Phrase
+}
+
+// excludesOnly reports whether the query only says what to leave out, as
+// in "NOT fitrah", and so has nothing to look for.
+func (q searchQuery) excludesOnly() bool {
+	return len(q.Groups) == 0 && len(q.Excluded) > 0
+}
+
+// matches reports whether a fatwa matches the query, given a function that
+// matches a single term against it. A term also matches through any of its
+// synonyms.
+func (q searchQuery) matches(matchText func(queryTerm) bool) bool {
+	matchTerm := func(term queryTerm) bool {
+		if matchText(term) {
+			return true
+		}
+		for _, synonym := range term.Synonyms {
+			if matchText(synonym) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, term := range q.Excluded {
+		if matchTerm(term) {
+			return false
+		}
+	}
+
+	for _, group := range q.Groups {
+		all := true
+		for _, term := range group {
+			if !matchTerm(term) {
+				all = false
+				break
+			}
+		}
+		if all {
+			return true
+		}
+	}
+	return false
+}
+
+// stemmedTerms joins the words of all terms the query looks for, for
+// relevance ranking. Phrases are ranked on their words as typed.
+func (q searchQuery) stemmedTerms() string {
+	var roots []string
+	for _, group := range q.Groups {
+		for _, term := range group {
+			if term.Phrase {
+				roots = append(roots, tokenize(term.Text)...)
+				continue
+			}
+			roots = append(roots, term.Roots...)
+			for _, synonym := range term.Synonyms {
+				roots = append(roots, synonym.Roots...)
+			}
+		}
+	}
+	return strings.Join(roots, " ")
+}
+
+// containsPhrase reports whether text contains phrase as whole words, so
+// "solat" doesn't match inside "solatnya".
+func containsPhrase(text, phrase string) bool {
+	if phrase == "" {
+		return false
+	}
+
+	for offset := 0; ; {
+		i := strings.Index(text[offset:], phrase)
+		if i < 0 {
+			return false
+		}
+		start := offset + i
+		end := start + len(phrase)
+
+		before, _ := utf8.DecodeLastRuneInString(text[:start])
+		after, _ := utf8.DecodeRuneInString(text[end:])
+		if !isWordRune(before) && !isWordRune(after) {
+			return true
+		}
+		offset = start + 1
+	}
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
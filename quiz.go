@@ -0,0 +1,226 @@
+package main
+
+import (
+	"log"
+	"math/rand/v2"
+	"regexp"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// quizChoices is the number of answers offered for each quiz question.
+const quizChoices = 4
+
+// quizRulings are the rulings quiz answers are picked from, as fatwas
+// word them.
+var quizRulings = []string{"wajib", "sunat", "harus", "makruh", "haram"}
+
+var (
+	// quizTitle matches the titles quiz questions are made from, such as
+	// "Hukum Berkhatan Bagi Wanita", capturing what the ruling is about.
+	quizTitle = regexp.MustCompile(`(?i)^hukum\s+(.+?)[\s?.]*$`)
+
+	// quizSection matches the label of a fatwa's conclusion section.
+	// Questions are only made from fatwas that have one, so the answer is
+	// the fatwa's own conclusion rather than a ruling quoted on the way.
+	quizSection = regexp.MustCompile(`(?i)\b(?:kesimpulan|penutup)\s*:`)
+
+	// quizConclusion matches a ruling stated outright and unqualified, such
+	// as "hukumnya adalah harus." The ruling must end its clause: "harus
+	// dilakukan" is "must be done", and "haram jika ..." is conditional.
+	quizConclusion = regexp.MustCompile(`(?i)\b(?:hukumnya|adalah|ialah)\s+(?:adalah\s+|ialah\s+)?(wajib|sunat|harus|makruh|haram)\s*(?:[.;,)]|$)`)
+
+	// quizNegation matches a negation just before a stated ruling, as in
+	// "tidaklah hukumnya haram".
+	quizNegation = regexp.MustCompile(`(?i)\b(?:tidak|tidaklah|bukan|bukanlah)\s+(?:\S+\s+){0,2}$`)
+)
+
+// quizQuestion is a question made from a fatwa whose title asks for a
+// ruling and whose conclusion states one.
+type quizQuestion struct {
+	Fatwa  Fatwa
+	Topic  string
+	Answer string
+}
+
+// quizQuestions makes the quiz questions of a dataset. They are made when
+// the dataset is loaded, as finding them scans every fatwa.
+func quizQuestions(fatwas []Fatwa) []quizQuestion {
+	var questions []quizQuestion
+	for _, fatwa := range fatwas {
+		if question, ok := newQuizQuestion(fatwa); ok {
+			questions = append(questions, question)
+		}
+	}
+	return questions
+}
+
+// newQuizQuestion makes a question from a fatwa, if it has the title of
+// one and its conclusion section states a single ruling without negating
+// or qualifying it. Fatwas whose conclusion is anything less clear are
+// left out, as the quiz states its answers as fact.
+func newQuizQuestion(fatwa Fatwa) (quizQuestion, bool) {
+	title := quizTitle.FindStringSubmatch(strings.TrimSpace(fatwa.Title))
+	if title == nil {
+		return quizQuestion{}, false
+	}
+	sections := quizSection.FindAllStringIndex(fatwa.Content, -1)
+	if len(sections) == 0 {
+		return quizQuestion{}, false
+	}
+	conclusion := fatwa.Content[sections[len(sections)-1][1]:]
+
+	answer := ""
+	for _, match := range quizConclusion.FindAllStringSubmatchIndex(conclusion, -1) {
+		if quizNegation.MatchString(conclusion[:match[0]]) {
+			return quizQuestion{}, false
+		}
+		ruling := strings.ToLower(conclusion[match[2]:match[3]])
+		if answer != "" && ruling != answer {
+			return quizQuestion{}, false
+		}
+		answer = ruling
+	}
+	if answer == "" {
+		return quizQuestion{}, false
+	}
+	return quizQuestion{Fatwa: fatwa, Topic: title[1], Answer: answer}, true
+}
+
+// quizQuestion returns the question made from a fatwa, if there is one.
+func (fb *FatwaBot) quizQuestion(key FatwaKey) (quizQuestion, bool) {
+	for _, question := range fb.quizData() {
+		if question.Fatwa.Key() == key {
+			return question, true
+		}
+	}
+	return quizQuestion{}, false
+}
+
+// choices returns the question's answer and other rulings, shuffled.
+func (q quizQuestion) choices() []string {
+	var wrong []string
+	for _, ruling := range quizRulings {
+		if ruling != q.Answer {
+			wrong = append(wrong, ruling)
+		}
+	}
+	rand.Shuffle(len(wrong), func(i, j int) { wrong[i], wrong[j] = wrong[j], wrong[i] })
+
+	choices := append(wrong[:quizChoices-1], q.Answer)
+	rand.Shuffle(len(choices), func(i, j int) { choices[i], choices[j] = choices[j], choices[i] })
+	return choices
+}
+
+// handleQuiz runs /kuiz: a new question, or the user's score with
+// "/kuiz skor".
+func (fb *FatwaBot) handleQuiz(chatID int64, user *tgbotapi.User, argument string) {
+	switch strings.ToLower(strings.TrimSpace(argument)) {
+	case "":
+		fb.sendQuizQuestion(chatID)
+	case "skor", "score":
+		fb.showQuizScore(chatID, user)
+	default:
+		fb.reply(chatID, "quiz.usage")
+	}
+}
+
+// sendQuizQuestion asks one of the dataset's questions picked at random,
+// with a button for each choice. Answers come back as callbacks, so
+// everyone in a group can answer the same question.
+func (fb *FatwaBot) sendQuizQuestion(chatID int64) {
+	questions := fb.quizData()
+	if len(questions) == 0 {
+		fb.reply(chatID, "quiz.none")
+		return
+	}
+	question := questions[rand.IntN(len(questions))]
+
+	lang := fb.language(chatID)
+	var keyboard [][]tgbotapi.InlineKeyboardButton
+	for _, choice := range question.choices() {
+		keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(
+			tr(lang, "quiz.ruling_"+choice),
+			callbackData{Action: "kuiz", Key: question.Fatwa.Key(), Arg: choice}.String(),
+		)))
+	}
+	keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(tr(lang, "button.quiz_next"), callbackData{Action: "kuiz", Arg: "next"}.String()),
+	))
+
+	msg := tgbotapi.NewMessage(chatID, tr(lang, "quiz.question", question.Topic))
+	msg.ParseMode = tgbotapi.ModeHTML
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(keyboard...)
+	fb.send(msg)
+}
+
+// answerQuiz marks an answer to the quiz question on key, the ruling picked
+// being choice, or asks another question for "next". The outcome is shown to whoever
+// answered as an alert rather than a message, so a study group's chat
+// isn't filled with everyone's answers. It answers the callback itself.
+func (fb *FatwaBot) answerQuiz(callbackQuery *tgbotapi.CallbackQuery, key FatwaKey, choice string) {
+	chatID := callbackQuery.Message.Chat.ID
+	userID := callbackQuery.From.ID
+	lang := fb.language(chatID)
+
+	alert := func(text string) {
+		callback := tgbotapi.NewCallbackWithAlert(callbackQuery.ID, htmlToText(text))
+		if _, err := fb.bot.Request(callback); err != nil {
+			log.Printf("Error answering quiz: %v", err)
+		}
+	}
+
+	if choice == "next" {
+		fb.bot.Request(tgbotapi.NewCallback(callbackQuery.ID, ""))
+		fb.sendQuizQuestion(chatID)
+		return
+	}
+
+	question, ok := fb.quizQuestion(key)
+	if !ok {
+		alert(tr(lang, "quiz.expired"))
+		return
+	}
+
+	answer := tr(lang, "quiz.ruling_"+question.Answer)
+	correct := choice == question.Answer
+	first, err := fb.store.AddQuizAnswer(userID, key, correct)
+	if err != nil {
+		log.Printf("Error saving quiz answer: %v", err)
+		alert(tr(lang, "quiz.error"))
+		return
+	}
+	if !first {
+		alert(tr(lang, "quiz.answered", answer))
+		return
+	}
+
+	score, answered, err := fb.store.QuizScore(userID)
+	if err != nil {
+		log.Printf("Error reading quiz score: %v", err)
+	}
+	if correct {
+		alert(tr(lang, "quiz.correct", answer, score, answered))
+	} else {
+		alert(tr(lang, "quiz.wrong", answer, score, answered))
+	}
+}
+
+// showQuizScore tells a user how many quiz questions they got right.
+func (fb *FatwaBot) showQuizScore(chatID int64, user *tgbotapi.User) {
+	if user == nil {
+		return
+	}
+	score, answered, err := fb.store.QuizScore(user.ID)
+	if err != nil {
+		log.Printf("Error reading quiz score: %v", err)
+		fb.reply(chatID, "quiz.error")
+		return
+	}
+	if answered == 0 {
+		fb.reply(chatID, "quiz.no_score")
+		return
+	}
+	fb.reply(chatID, "quiz.score", user.FirstName, score, answered)
+}
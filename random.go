@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// handleRandom shows a random fatwa from a category typed by the user, for
+// teachers pulling discussion material from a topic. Without a category
+// it offers a button per category, and one for any category.
+func (fb *FatwaBot) handleRandom(chatID int64, name string) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		fb.showRandomCategories(chatID)
+		return
+	}
+
+	category, ok := fb.findCategory(name)
+	if !ok {
+		fb.reply(chatID, "random.not_found", name)
+		return
+	}
+	fb.sendRandomFatwa(chatID, category)
+}
+
+// showRandomCategories offers the categories to pick a random fatwa from,
+// a page at a time.
+func (fb *FatwaBot) showRandomCategories(chatID int64) {
+	text, keyboard := fb.randomCategoriesPage(0, fb.language(chatID))
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = tgbotapi.ModeHTML
+	msg.ReplyMarkup = keyboard
+	fb.send(msg)
+}
+
+// showRandomCategoriesPage replaces a random categories message with
+// another page of categories. offsetStr is the offset from the page button.
+func (fb *FatwaBot) showRandomCategoriesPage(message *tgbotapi.Message, offsetStr string) {
+	offset, err := strconv.Atoi(offsetStr)
+	if err != nil || offset < 0 {
+		fb.reply(message.Chat.ID, "error.page")
+		return
+	}
+
+	text, keyboard := fb.randomCategoriesPage(offset, fb.language(message.Chat.ID))
+	edit := tgbotapi.NewEditMessageTextAndMarkup(message.Chat.ID, message.MessageID, text, keyboard)
+	edit.ParseMode = tgbotapi.ModeHTML
+	fb.send(edit)
+}
+
+// randomCategoriesPage formats the page of categories to pick a random
+// fatwa from starting at offset, like categoriesPage, under a button for
+// any category.
+func (fb *FatwaBot) randomCategoriesPage(offset int, lang string) (string, tgbotapi.InlineKeyboardMarkup) {
+	categories, counts := fb.datasetCategories()
+	if offset >= len(categories) {
+		offset = max(len(categories)-1, 0) / categoriesPerPage * categoriesPerPage
+	}
+	end := min(offset+categoriesPerPage, len(categories))
+
+	message := tr(lang, "random.pick")
+	if len(categories) > categoriesPerPage {
+		message += "\n\n" + tr(lang, "categories.range", offset+1, end, len(categories))
+	}
+
+	keyboard := [][]tgbotapi.InlineKeyboardButton{tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(tr(lang, "button.random_any"), callbackData{Action: "rand"}.String()),
+	)}
+	keyboard = append(keyboard, categoryButtons(categories[offset:end], counts, "🎲", callbackData{Action: "rand"})...)
+
+	var navigation []tgbotapi.InlineKeyboardButton
+	if offset > 0 {
+		navigation = append(navigation, tgbotapi.NewInlineKeyboardButtonData(
+			tr(lang, "button.previous"),
+			fmt.Sprintf("randpage_%d", max(offset-categoriesPerPage, 0)),
+		))
+	}
+	if end < len(categories) {
+		navigation = append(navigation, tgbotapi.NewInlineKeyboardButtonData(
+			tr(lang, "button.next"),
+			fmt.Sprintf("randpage_%d", end),
+		))
+	}
+	if len(navigation) > 0 {
+		keyboard = append(keyboard, navigation)
+	}
+
+	return message, tgbotapi.NewInlineKeyboardMarkup(keyboard...)
+}
+
+// sendRandomFatwa shows a fatwa picked at random from a category, or from
+// the whole dataset when category is empty.
+func (fb *FatwaBot) sendRandomFatwa(chatID int64, category string) {
+	var candidates []Fatwa
+	for _, fatwa := range fb.dataset() {
+		if category == "" || fatwa.Category == category {
+			candidates = append(candidates, fatwa)
+		}
+	}
+	if len(candidates) == 0 {
+		fb.reply(chatID, "random.not_found", category)
+		return
+	}
+
+	fb.sendFatwaDetails(chatID, candidates[rand.IntN(len(candidates))])
+}
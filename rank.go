@@ -0,0 +1,122 @@
+package main
+
+import (
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// BM25 parameters: bm25K1 controls how quickly repeated terms stop adding
+// to the score, bm25B how strongly long documents are penalized.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// fieldWeights scale how much a query term counts towards the score in
+// each field. Titles and categories are short and name the topic, so a
+// match there says more than one somewhere in the content.
+type fieldWeights struct {
+	Title    float64
+	Content  float64
+	Category float64
+}
+
+var defaultFieldWeights = fieldWeights{Title: 2, Content: 1, Category: 3}
+
+// searchWeights returns the field weights, overridden by SEARCH_WEIGHTS as
+// comma-separated "field=weight" pairs (e.g. "title=2,content=1,category=3").
+var searchWeights = sync.OnceValue(func() fieldWeights {
+	weights := defaultFieldWeights
+
+	for _, part := range strings.Split(os.Getenv("SEARCH_WEIGHTS"), ",") {
+		field, valueStr, found := strings.Cut(strings.TrimSpace(part), "=")
+		if !found {
+			continue
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(valueStr), 64)
+		if err != nil || value < 0 {
+			log.Printf("Ignoring invalid search weight %q", part)
+			continue
+		}
+
+		switch strings.ToLower(strings.TrimSpace(field)) {
+		case "title":
+			weights.Title = value
+		case "content":
+			weights.Content = value
+		case "category":
+			weights.Category = value
+		default:
+			log.Printf("Ignoring unknown search weight field %q", field)
+		}
+	}
+
+	return weights
+})
+
+// rankResults orders results by the relevance of the query's word roots:
+// BM25 over the content, plus the term's weight for every title or
+// category it appears in. Fuzzy matches stay after exact ones, and ties
+// keep dataset order.
+func rankResults(results []searchResult, index *searchIndex, stemmedQuery string) {
+	terms := uniqueWords(strings.Fields(stemmedQuery))
+	if len(terms) == 0 || len(results) < 2 {
+		return
+	}
+
+	weights := searchWeights()
+	total := len(index.ContentLengths)
+	var totalLength int
+	for _, length := range index.ContentLengths {
+		totalLength += length
+	}
+	averageLength := float64(totalLength) / float64(max(total, 1))
+
+	idf := make(map[string]float64, len(terms))
+	for _, term := range terms {
+		docs := len(index.rootDocs(term, true))
+		idf[term] = math.Log(1 + (float64(total)-float64(docs)+0.5)/(float64(docs)+0.5))
+	}
+
+	for r := range results {
+		i := results[r].Doc
+		lengthNorm := 1 - bm25B + bm25B*float64(index.ContentLengths[i])/math.Max(averageLength, 1)
+
+		var score float64
+		for _, term := range terms {
+			tf := float64(index.rootFrequency(i, term))
+			score += weights.Content * idf[term] * tf * (bm25K1 + 1) / (tf + bm25K1*lengthNorm)
+			if _, ok := findPosting(index.TitleRoots[term], i); ok {
+				score += weights.Title * idf[term]
+			}
+			if _, ok := findPosting(index.CategoryRoots[term], i); ok {
+				score += weights.Category * idf[term]
+			}
+		}
+		results[r].Score = score
+	}
+
+	sort.SliceStable(results, func(a, b int) bool {
+		if results[a].Fuzzy != results[b].Fuzzy {
+			return !results[a].Fuzzy
+		}
+		return results[a].Score > results[b].Score
+	})
+}
+
+func uniqueWords(words []string) []string {
+	seen := make(map[string]bool, len(words))
+	unique := words[:0]
+	for _, word := range words {
+		if !seen[word] {
+			seen[word] = true
+			unique = append(unique, word)
+		}
+	}
+	return unique
+}
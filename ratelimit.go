@@ -0,0 +1,133 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// rateLimitPerMinute is how many updates a chat may send per minute on
+	// average, leaving room for the several replies some of them take under
+	// Telegram's limit of about a message a second per chat.
+	rateLimitPerMinute = 20
+
+	// rateLimitBurst is how many updates a chat may send at once, e.g. when
+	// paging quickly through results.
+	rateLimitBurst = 10
+
+	// inlineRateLimitPerMinute and inlineRateLimitBurst limit inline
+	// queries on their own, as Telegram sends one for nearly every
+	// keystroke while the user types.
+	inlineRateLimitPerMinute = 120
+	inlineRateLimitBurst     = 30
+)
+
+// rateLimiter gives each chat a token bucket that refills steadily, so a
+// chat spamming searches can't monopolize the bot.
+type rateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[int64]*tokenBucket
+	rate      float64 // tokens per second
+	burst     float64
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+	warned bool // told it is over the limit since its last allowed update
+}
+
+func newRateLimiter(perMinute, burst int) *rateLimiter {
+	return &rateLimiter{
+		buckets:   make(map[int64]*tokenBucket),
+		rate:      float64(perMinute) / 60,
+		burst:     float64(burst),
+		lastSweep: time.Now(),
+	}
+}
+
+// allow takes a token from a chat's bucket. When the bucket is empty, warn
+// is true only the first time, so a flood gets a single reply.
+func (l *rateLimiter) allow(chatID int64) (allowed, warn bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	bucket, ok := l.buckets[chatID]
+	if !ok {
+		bucket = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[chatID] = bucket
+	}
+
+	bucket.tokens += now.Sub(bucket.last).Seconds() * l.rate
+	if bucket.tokens > l.burst {
+		bucket.tokens = l.burst
+	}
+	bucket.last = now
+
+	if bucket.tokens < 1 {
+		warn = !bucket.warned
+		bucket.warned = true
+		return false, warn
+	}
+	bucket.tokens--
+	bucket.warned = false
+	return true, false
+}
+
+// sweep forgets buckets that have refilled completely, as they behave the
+// same as new ones. It runs at most once a minute.
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+
+	full := time.Duration(l.burst / l.rate * float64(time.Second))
+	for chatID, bucket := range l.buckets {
+		if now.Sub(bucket.last) > full {
+			delete(l.buckets, chatID)
+		}
+	}
+}
+
+// allowUpdate checks an update against its chat's rate limit. Over the
+// limit, the user is asked once to try again shortly and the update is
+// dropped; button presses are still answered so their spinner stops.
+// Inline queries count against a separate, more generous limit, and are
+// dropped silently. Admins are never limited.
+func (fb *FatwaBot) allowUpdate(update tgbotapi.Update) bool {
+	chatID, user, ok := updateChat(update)
+	if !ok {
+		return true
+	}
+	if fb.isAdmin(user) {
+		return true
+	}
+
+	limiter := fb.limiter
+	if update.InlineQuery != nil {
+		limiter = fb.inlineLimiter
+	}
+
+	allowed, warn := limiter.allow(chatID)
+	if allowed {
+		return true
+	}
+
+	if update.CallbackQuery != nil {
+		text := ""
+		if warn {
+			text = fb.t(chatID, "rate_limited")
+		}
+		fb.bot.Request(tgbotapi.NewCallback(update.CallbackQuery.ID, text))
+	} else if update.Message != nil && warn {
+		fb.reply(chatID, "rate_limited")
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+package main
+
+import tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+// readingPartLength is how much of a long fatwa's content is shown per
+// part, leaving room in the message for the header or the footer.
+const readingPartLength = 3000
+
+// partButtons are the ⏮/▶️ buttons that page a long fatwa's message to
+// the previous or next part, keeping the search it was opened from.
+func partButtons(key FatwaKey, part, parts int, search, lang string) []tgbotapi.InlineKeyboardButton {
+	var row []tgbotapi.InlineKeyboardButton
+	if part > 1 {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(
+			tr(lang, "button.part_previous"),
+			callbackData{Action: "part", Key: key, Page: part - 1, Hash: search}.String(),
+		))
+	}
+	if part < parts {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(
+			tr(lang, "button.part_next"),
+			callbackData{Action: "part", Key: key, Page: part + 1, Hash: search}.String(),
+		))
+	}
+	return row
+}
+
+// showDetailsPart replaces a long fatwa's message with another part of it,
+// as given by the part button, with search the hash of the search it was
+// opened from.
+func (fb *FatwaBot) showDetailsPart(message *tgbotapi.Message, key FatwaKey, part int, search string) {
+	chatID := message.Chat.ID
+
+	fatwa, ok := fb.findFatwa(key)
+	if !ok {
+		fb.reply(chatID, "id.not_found", key)
+		return
+	}
+
+	text, keyboard := fb.detailsPart(chatID, fatwa, part, search)
+	edit := tgbotapi.NewEditMessageTextAndMarkup(chatID, message.MessageID, text, keyboard)
+	edit.ParseMode = tgbotapi.ModeHTML
+	edit.DisableWebPagePreview = true
+	fb.send(edit)
+}
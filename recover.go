@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"runtime/debug"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// panicNoticeInterval is how often admins are told about panics at
+	// most, so an update that keeps failing doesn't flood their chats.
+	panicNoticeInterval = 10 * time.Minute
+
+	// panicStackShown is how much of the stack trace admins are sent; the
+	// log has all of it.
+	panicStackShown = 3000
+)
+
+// recoverUpdate keeps a panic in a handler from taking the whole bot down.
+// Deferred around each update, it logs the panic with its stack, tells
+// the user something went wrong and the admins what, and lets the worker
+// carry on with the next update.
+func (fb *FatwaBot) recoverUpdate(update tgbotapi.Update) {
+	value := recover()
+	if value == nil {
+		return
+	}
+
+	stack := debug.Stack()
+	chatID, _, ok := updateChat(update)
+	log.Printf("Panic handling update %d from chat %d: %v\n%s", update.UpdateID, chatID, value, stack)
+
+	if update.CallbackQuery != nil {
+		fb.bot.Request(tgbotapi.NewCallback(update.CallbackQuery.ID, ""))
+	}
+	if ok && update.InlineQuery == nil {
+		fb.reply(chatID, "error.internal")
+	}
+	fb.notifyAdminsOfPanic(chatID, value, stack)
+}
+
+// goSafe runs task in a goroutine of its own, for the work handlers leave
+// running after they return, such as translations, answers and
+// broadcasts, which recoverUpdate no longer covers. A panic in task is
+// recovered the same way, apologizing in chatID unless it is 0.
+func (fb *FatwaBot) goSafe(chatID int64, task func()) {
+	go func() {
+		defer fb.recoverTask(chatID)
+		task()
+	}()
+}
+
+// recoverTask is recoverUpdate for the tasks of goSafe.
+func (fb *FatwaBot) recoverTask(chatID int64) {
+	value := recover()
+	if value == nil {
+		return
+	}
+
+	stack := debug.Stack()
+	log.Printf("Panic in background task for chat %d: %v\n%s", chatID, value, stack)
+	if chatID != 0 {
+		fb.reply(chatID, "error.internal")
+	}
+	fb.notifyAdminsOfPanic(chatID, value, stack)
+}
+
+// notifyAdminsOfPanic sends every admin the panic and the start of its
+// stack, unless they were told about one in the last panicNoticeInterval.
+func (fb *FatwaBot) notifyAdminsOfPanic(chatID int64, value any, stack []byte) {
+	fb.panicMu.Lock()
+	if time.Since(fb.lastPanicNotice) < panicNoticeInterval {
+		fb.panicMu.Unlock()
+		return
+	}
+	fb.lastPanicNotice = time.Now()
+	fb.panicMu.Unlock()
+
+	trace := string(stack)
+	if len(trace) > panicStackShown {
+		trace = trace[:panicStackShown] + "\n..."
+	}
+	for adminID := range fb.admins {
+		message := tr(fb.language(adminID), "admin.panic", chatID, fmt.Sprint(value)) +
+			"\n\n<pre>" + html.EscapeString(trace) + "</pre>"
+		msg := tgbotapi.NewMessage(adminID, message)
+		msg.ParseMode = tgbotapi.ModeHTML
+		if _, err := fb.trySend(msg); err != nil {
+			log.Printf("Error sending panic to admin %d: %v", adminID, err)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// maxPatternLength bounds /regex patterns. Go regexps run in linear time,
+// so the length is the main cost factor.
+const maxPatternLength = 200
+
+// compileSearchPattern compiles a /regex pattern, case-insensitively.
+func compileSearchPattern(pattern string) (*regexp.Regexp, error) {
+	if len(pattern) > maxPatternLength {
+		return nil, fmt.Errorf("pattern longer than %d characters", maxPatternLength)
+	}
+	return regexp.Compile("(?i)" + pattern)
+}
+
+// searchRegex returns the fatwas whose title or content matches re, in
+// dataset order. Unlike other searches it runs on the original text, so
+// patterns can rely on punctuation and diacritics.
+func searchRegex(fatwas []Fatwa, re *regexp.Regexp) []searchResult {
+	var results []searchResult
+	for i, fatwa := range fatwas {
+		if re.MatchString(fatwa.Title) || re.MatchString(fatwa.Content) {
+			results = append(results, searchResult{Fatwa: fatwa, Doc: i})
+		}
+	}
+	return results
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"sort"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// relatedShown is the number of related fatwas offered under a fatwa.
+	relatedShown = 3
+
+	// relatedTitleLength is how much of a related fatwa's title fits on
+	// its button, in characters.
+	relatedTitleLength = 50
+)
+
+// relatedFatwas returns the fatwas most related to one: those whose titles
+// share the most word roots with its title, then other fatwas in its
+// category. Among equals, the same category and then the most viewed come
+// first.
+func (fb *FatwaBot) relatedFatwas(key FatwaKey) []Fatwa {
+	fatwas, index := fb.searchData()
+
+	doc := -1
+	for i, fatwa := range fatwas {
+		if fatwa.Key() == key {
+			doc = i
+			break
+		}
+	}
+	if doc < 0 {
+		return nil
+	}
+
+	scores := make(map[int]int)
+	stemmer := index.malayStemmer()
+	seen := make(map[string]bool)
+	for _, word := range removeStopwords(tokenize(index.Titles[doc])) {
+		root := stemmer.stem(word)
+		if seen[root] {
+			continue
+		}
+		seen[root] = true
+		for _, p := range index.TitleRoots[root] {
+			scores[p.Doc] += 2
+		}
+	}
+
+	category := fatwas[doc].Category
+	for i, fatwa := range fatwas {
+		if category != "" && fatwa.Category == category {
+			scores[i]++
+		}
+	}
+	delete(scores, doc)
+
+	candidates := make([]int, 0, len(scores))
+	for i := range scores {
+		candidates = append(candidates, i)
+	}
+	sort.Slice(candidates, func(a, b int) bool {
+		i, j := candidates[a], candidates[b]
+		if scores[i] != scores[j] {
+			return scores[i] > scores[j]
+		}
+		if fatwas[i].Hits != fatwas[j].Hits {
+			return fatwas[i].Hits > fatwas[j].Hits
+		}
+		return i < j
+	})
+
+	var related []Fatwa
+	for _, i := range candidates[:min(relatedShown, len(candidates))] {
+		related = append(related, fatwas[i])
+	}
+	return related
+}
+
+// relatedButtons are the buttons that open related fatwas, one per row.
+func relatedButtons(related []Fatwa) [][]tgbotapi.InlineKeyboardButton {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, fatwa := range related {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(
+			"🔗 "+plainExcerpt(fatwa.Title, relatedTitleLength),
+			fatwaCallback("view", fatwa.Key()),
+		)))
+	}
+	return rows
+}
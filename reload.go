@@ -0,0 +1,170 @@
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// fatwaDataset is the fatwas being served, with their search index and
+// content history. It is shared by every bot of the process, so a reload
+// updates them all at once.
+type fatwaDataset struct {
+	mu      sync.RWMutex // guards fatwas, index, history, quiz and loaded during reloads
+	fatwas  []Fatwa
+	index   *searchIndex
+	history map[FatwaKey][]FatwaVersion
+	quiz    []quizQuestion
+	loaded  time.Time
+
+	bots []*FatwaBot // serving the dataset, set at startup
+}
+
+// dataset returns the fatwas currently being served. Reloads swap in a new
+// slice rather than modifying the old one, so callers can keep using the
+// returned slice without holding the lock.
+func (fb *FatwaBot) dataset() []Fatwa {
+	fb.mu.RLock()
+	defer fb.mu.RUnlock()
+	return fb.fatwas
+}
+
+// searchData returns the current fatwas together with their search index.
+func (fb *FatwaBot) searchData() ([]Fatwa, *searchIndex) {
+	fb.mu.RLock()
+	defer fb.mu.RUnlock()
+	return fb.fatwas, fb.index
+}
+
+// quizData returns the quiz questions made from the current fatwas.
+func (fb *FatwaBot) quizData() []quizQuestion {
+	fb.mu.RLock()
+	defer fb.mu.RUnlock()
+	return fb.quiz
+}
+
+func (fb *FatwaBot) contentVersions(key FatwaKey) []FatwaVersion {
+	fb.mu.RLock()
+	defer fb.mu.RUnlock()
+	return fb.history[key]
+}
+
+// reloadDataset loads the dataset and content history from disk and swaps
+// them in. The current data is kept if the new dataset cannot be loaded.
+// Saved searches and category subscriptions of every bot are then checked
+// against the fatwas a scrape added, which are also posted to the new
+// fatwas channel.
+func (fb *FatwaBot) reloadDataset() error {
+	fatwas, index, err := loadIndexedDataset()
+	if err != nil {
+		return err
+	}
+
+	history, err := loadFatwaHistory("fatwa_history.csv")
+	if err != nil {
+		log.Printf("Error loading fatwa history: %v", err)
+		history = make(map[FatwaKey][]FatwaVersion)
+	}
+
+	quiz := quizQuestions(fatwas)
+
+	fb.mu.Lock()
+	previousLoad := fb.loaded
+	fb.fatwas = fatwas
+	fb.index = index
+	fb.history = history
+	fb.quiz = quiz
+	fb.loaded = time.Now()
+	fb.mu.Unlock()
+
+	log.Printf("Reloaded %d fatwas", len(fatwas))
+
+	// Tell users of every bot with saved searches or subscriptions, and the
+	// new fatwas channel, about the fatwas a scrape added since the dataset
+	// was last loaded
+	if additions, ok := scrapedSince(previousLoad); ok {
+		for _, bot := range fb.bots {
+			go bot.notifyScraped(additions.Fatwas)
+		}
+	}
+
+	return nil
+}
+
+// addedFatwas returns the keys of fatwas in current that aren't in
+// previous.
+func addedFatwas(previous, current []Fatwa) map[FatwaKey]bool {
+	known := make(map[FatwaKey]bool, len(previous))
+	for _, fatwa := range previous {
+		known[fatwa.Key()] = true
+	}
+
+	added := make(map[FatwaKey]bool)
+	for _, fatwa := range current {
+		if !known[fatwa.Key()] {
+			added[fatwa.Key()] = true
+		}
+	}
+	return added
+}
+
+// watchDataset reloads the dataset whenever its file changes on disk. The
+// directory is watched rather than the file itself because exports replace
+// the file with an atomic rename.
+func (fb *FatwaBot) watchDataset() {
+	path := datasetPath()
+	if os.Getenv("DATABASE_PATH") != "" {
+		path = databasePath()
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Printf("Error creating dataset watcher: %v", err)
+		return
+	}
+	defer watcher.Close()
+
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		log.Printf("Error watching dataset directory: %v", err)
+		return
+	}
+
+	// SQLite in WAL mode commits to the -wal file first
+	names := map[string]bool{
+		filepath.Base(path):          true,
+		filepath.Base(path) + "-wal": true,
+	}
+
+	// Wait for writes to settle before reloading
+	const settleDelay = 2 * time.Second
+	timer := time.NewTimer(settleDelay)
+	timer.Stop()
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if !names[filepath.Base(event.Name)] {
+				continue
+			}
+			if event.Has(fsnotify.Create) || event.Has(fsnotify.Write) || event.Has(fsnotify.Rename) {
+				timer.Reset(settleDelay)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("Dataset watcher error: %v", err)
+		case <-timer.C:
+			if err := fb.reloadDataset(); err != nil {
+				log.Printf("Error reloading dataset: %v", err)
+			}
+		}
+	}
+}
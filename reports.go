@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// reportCooldown is how long after a fatwa is reported further reports
+	// of it are acknowledged without checking it again.
+	reportCooldown = 24 * time.Hour
+
+	// reportQueueLength is how many reported fatwas may wait to be checked.
+	reportQueueLength = 100
+)
+
+// linkReport is a reported fatwa waiting to be checked.
+type linkReport struct {
+	ID  int64
+	Key FatwaKey
+}
+
+// reportButton is the "⚠️ Report" button under fatwa details, for links
+// that no longer work or content that is out of date.
+func reportButton(key FatwaKey, lang string) tgbotapi.InlineKeyboardButton {
+	return tgbotapi.NewInlineKeyboardButtonData(tr(lang, "button.report"), fatwaCallback("report", key))
+}
+
+// reportFatwa flags a fatwa reported by a user and queues it to be scraped
+// again. Fatwas reported in the last reportCooldown are only acknowledged,
+// so a popular broken link isn't fetched again for every user.
+func (fb *FatwaBot) reportFatwa(chatID int64, idStr string) {
+	key, err := parseFatwaKey(idStr)
+	if err != nil {
+		fb.reply(chatID, "id.invalid")
+		return
+	}
+	if _, ok := fb.findFatwa(key); !ok {
+		fb.reply(chatID, "id.not_found", key)
+		return
+	}
+
+	reported, err := fb.store.ReportedSince(key, time.Now().Add(-reportCooldown))
+	if err != nil {
+		log.Printf("Error reading reports: %v", err)
+		fb.reply(chatID, "report.error")
+		return
+	}
+	if reported {
+		fb.reply(chatID, "report.already")
+		return
+	}
+
+	id, err := fb.store.AddReport(chatID, key)
+	if err != nil {
+		log.Printf("Error saving report: %v", err)
+		fb.reply(chatID, "report.error")
+		return
+	}
+
+	select {
+	case fb.reports <- linkReport{ID: id, Key: key}:
+	default:
+		log.Printf("Report queue full, not checking %s", key)
+	}
+	fb.reply(chatID, "report.thanks")
+}
+
+// checkReports scrapes reported fatwas again one at a time, updates the
+// dataset when their content has changed and tells the admins what was
+// found.
+func (fb *FatwaBot) checkReports() {
+	for report := range fb.reports {
+		fatwa, ok := fb.findFatwa(report.Key)
+		if !ok {
+			continue
+		}
+
+		outcome, detail := fb.checkReportedFatwa(fatwa)
+		if err := fb.store.SetReportOutcome(report.ID, outcome); err != nil {
+			log.Printf("Error saving report outcome: %v", err)
+		}
+		fb.notifyAdminsOfReport(fatwa, outcome, detail)
+
+		// Be respectful to the server, as the scraper is
+		time.Sleep(time.Second)
+	}
+}
+
+// checkReportedFatwa scrapes a fatwa's page again and returns the outcome:
+// "broken" when the page can't be scraped, "updated" when its content
+// changed and the dataset was updated, "not_saved" when it changed but the
+// dataset couldn't be, "ok" when nothing changed, or "unchecked" for
+// sources that can't be scraped one article at a time. detail explains
+// failures.
+func (fb *FatwaBot) checkReportedFatwa(fatwa Fatwa) (outcome, detail string) {
+	if fatwa.Source != defaultSource {
+		return "unchecked", ""
+	}
+
+	fresh, err := scrapeArticle(canonicalURL(fatwa.URL))
+	if err != nil {
+		log.Printf("Error scraping reported fatwa %s: %v", fatwa.Key(), err)
+		return "broken", err.Error()
+	}
+	if fresh.Content == fatwa.Content && fresh.Title == fatwa.Title {
+		return "ok", ""
+	}
+
+	// The article page shows dates and views differently from the listing
+	// the scraper reads them from, so only the text is taken from it
+	updated := fatwa
+	updated.Title, updated.Content = fresh.Title, fresh.Content
+
+	// A running scrape will save the whole dataset over this change, and
+	// pick up the new content itself. Holding the lock keeps one from
+	// starting until the change is saved.
+	if !scrapeMu.TryLock() {
+		return "not_saved", "scrape in progress"
+	}
+	defer scrapeMu.Unlock()
+
+	if err := updateDatasetFatwa(updated); err != nil {
+		log.Printf("Error updating reported fatwa %s: %v", fatwa.Key(), err)
+		return "not_saved", err.Error()
+	}
+	return "updated", ""
+}
+
+// updateDatasetFatwa saves a fatwa scraped again into the dataset,
+// archiving its previous content as the scraper does. The dataset watcher
+// then reloads it.
+func updateDatasetFatwa(fatwa Fatwa) error {
+	existing, err := loadDataset()
+	if err != nil {
+		return fmt.Errorf("cannot load dataset: %v", err)
+	}
+
+	merged, _, _, _ := mergeFatwas(existing, []Fatwa{fatwa})
+	if _, err := recordContentChanges(existing, merged, "fatwa_history.csv"); err != nil {
+		return err
+	}
+	return saveDataset(merged)
+}
+
+// notifyAdminsOfReport tells every admin about a reported fatwa and what
+// checking it found.
+func (fb *FatwaBot) notifyAdminsOfReport(fatwa Fatwa, outcome, detail string) {
+	for adminID := range fb.admins {
+		lang := fb.language(adminID)
+		message := tr(lang, "report.admin", fatwa.Title, fatwa.Key(), fatwa.URL) + "\n\n"
+		if detail != "" {
+			message += tr(lang, "report.outcome_"+outcome, detail)
+		} else {
+			message += tr(lang, "report.outcome_"+outcome)
+		}
+
+		msg := tgbotapi.NewMessage(adminID, message)
+		msg.ParseMode = tgbotapi.ModeHTML
+		msg.DisableWebPagePreview = true
+		if _, err := fb.trySend(msg); err != nil {
+			log.Printf("Error sending report to admin %d: %v", adminID, err)
+		}
+	}
+}
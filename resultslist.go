@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"unicode"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// resultsListButton is the "📎 Download list" button under results that
+// don't fit on one page.
+func resultsListButton(session searchSession, lang string) tgbotapi.InlineKeyboardButton {
+	return tgbotapi.NewInlineKeyboardButtonData(tr(lang, "button.download_list"), callbackData{Action: "list", Hash: session.hash()}.String())
+}
+
+// sendResultsList sends every result of a chat's search as a CSV file, in
+// the order they are shown, for researchers who want the whole list rather
+// than paging through it. hash identifies the search, from the button.
+func (fb *FatwaBot) sendResultsList(chatID int64, hash string) {
+	session, ok := fb.searchSession(chatID)
+	if !ok || session.hash() != hash {
+		fb.reply(chatID, "search.expired")
+		return
+	}
+
+	results := fb.sessionResults(session)
+	if len(results) == 0 {
+		fb.reply(chatID, "search.expired")
+		return
+	}
+
+	data, err := resultsCSV(results)
+	if err != nil {
+		log.Printf("Error creating results list: %v", err)
+		fb.reply(chatID, "results.list_error")
+		return
+	}
+
+	fb.bot.Request(tgbotapi.NewChatAction(chatID, tgbotapi.ChatUploadDocument))
+
+	lang := fb.language(chatID)
+	title := tr(lang, "results.title", session.Query)
+	name := session.Query
+	if isBrowseType(session.Type) {
+		title = tr(lang, "results.browse", browseTitle(session.Query, session.Type, lang))
+		name = session.Type + " " + session.Query
+	}
+
+	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{
+		Name:  "fatwa-" + fileSlug(name) + ".csv",
+		Bytes: data,
+	})
+	doc.Caption = title + "\n" + tr(lang, "results.list_count", len(results))
+	doc.ParseMode = tgbotapi.ModeHTML
+	if _, err := fb.trySend(doc); err != nil {
+		log.Printf("Error sending results list: %v", err)
+		fb.reply(chatID, "results.list_error")
+	}
+}
+
+// resultsCSV lists results with their ID, title, date, category, views
+// and link. The file starts with a byte order mark so spreadsheets open it
+// as UTF-8.
+func resultsCSV(results []searchResult) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("\ufeff")
+
+	writer := csv.NewWriter(&buf)
+	if err := writer.Write([]string{"ID", "Title", "Date", "Category", "Hits", "URL"}); err != nil {
+		return nil, fmt.Errorf("cannot write CSV header: %v", err)
+	}
+	for _, result := range results {
+		fatwa := result.Fatwa
+		record := []string{
+			fatwa.Key().String(),
+			fatwa.Title,
+			fatwa.Date,
+			fatwa.Category,
+			strconv.Itoa(fatwa.Hits),
+			fatwa.URL,
+		}
+		if err := writer.Write(record); err != nil {
+			return nil, fmt.Errorf("cannot write CSV record: %v", err)
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, fmt.Errorf("cannot write CSV: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// fileSlug turns a query into part of a file name: lowercase letters and
+// digits separated by dashes, at most 40 characters.
+func fileSlug(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(normalizeSearchText(text)), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	slug := []rune(strings.Join(words, "-"))
+	if len(slug) > 40 {
+		slug = slug[:40]
+	}
+	if len(slug) == 0 {
+		return "list"
+	}
+	return strings.Trim(string(slug), "-")
+}
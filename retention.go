@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// retentionRule removes files matching Pattern that are older than MaxAge.
+type retentionRule struct {
+	Pattern string
+	MaxAge  time.Duration
+}
+
+// retentionRules returns the cleanup rules: snapshots rejected by a
+// rollback, plus any directories configured in RETENTION_RULES as
+// comma-separated "dir=days" pairs (e.g. "raw_html=90,http_cache=14").
+func retentionRules() []retentionRule {
+	rules := []retentionRule{
+		{Pattern: filepath.Join(snapshotDir(), "rejected-*"), MaxAge: 30 * 24 * time.Hour},
+	}
+
+	for _, part := range strings.Split(os.Getenv("RETENTION_RULES"), ",") {
+		dir, daysStr, found := strings.Cut(strings.TrimSpace(part), "=")
+		if !found {
+			continue
+		}
+		days, err := strconv.Atoi(strings.TrimSpace(daysStr))
+		if err != nil || days <= 0 {
+			log.Printf("Ignoring invalid retention rule %q", part)
+			continue
+		}
+		rules = append(rules, retentionRule{
+			Pattern: filepath.Join(strings.TrimSpace(dir), "*"),
+			MaxAge:  time.Duration(days) * 24 * time.Hour,
+		})
+	}
+
+	return rules
+}
+
+// cleanupExpiredFiles applies the retention rules and returns the number of
+// files removed.
+func cleanupExpiredFiles() (int, error) {
+	removed := 0
+
+	for _, rule := range retentionRules() {
+		matches, err := filepath.Glob(rule.Pattern)
+		if err != nil {
+			return removed, fmt.Errorf("invalid retention pattern %q: %v", rule.Pattern, err)
+		}
+
+		cutoff := time.Now().Add(-rule.MaxAge)
+		for _, path := range matches {
+			info, err := os.Stat(path)
+			if err != nil || info.IsDir() || info.ModTime().After(cutoff) {
+				continue
+			}
+			if err := os.Remove(path); err != nil {
+				return removed, fmt.Errorf("cannot remove %s: %v", path, err)
+			}
+			removed++
+		}
+	}
+
+	return removed, nil
+}
+
+func runRetentionCleanup() {
+	removed, err := cleanupExpiredFiles()
+	if err != nil {
+		log.Printf("Error cleaning up expired files: %v", err)
+		return
+	}
+	if removed > 0 {
+		log.Printf("Removed %d expired files", removed)
+	}
+}
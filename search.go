@@ -0,0 +1,273 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// searchResult is a fatwa matched by a search. Doc is its position in the
+// dataset and index. Fuzzy results only matched after allowing for typos
+// in the query, and are labelled as such. Score is the relevance the
+// results are ordered by.
+type searchResult struct {
+	Fatwa Fatwa
+	Doc   int
+	Fuzzy bool
+	Score float64
+}
+
+// minExactResults is the number of exact matches below which fuzzy
+// matching is tried as a fallback.
+const minExactResults = 3
+
+// runSearch runs a search of any type, including browsing by year, letter
+// or popularity, reusing the results of the same query if it was run
+// recently. Regex searches whose pattern doesn't compile find nothing;
+// searchFatwas reports the error first.
+func runSearch(fatwas []Fatwa, index *searchIndex, query string, searchType string) []searchResult {
+	return cachedSearch(fatwas, index, query, searchType, func() []searchResult {
+		switch searchType {
+		case "regex":
+			re, err := compileSearchPattern(query)
+			if err != nil {
+				return nil
+			}
+			return searchRegex(fatwas, re)
+		case "year", "letter", "popular", "new":
+			return browseFatwas(fatwas, query, searchType)
+		}
+		return searchIndexed(fatwas, index, query, searchType)
+	})
+}
+
+// inCategory keeps the results in a category, in their order.
+func inCategory(results []searchResult, category string) []searchResult {
+	var filtered []searchResult
+	for _, result := range results {
+		if result.Fatwa.Category == category {
+			filtered = append(filtered, result)
+		}
+	}
+	return filtered
+}
+
+// searchIndexed looks up candidate fatwas in the inverted index and
+// confirms them with a substring search over the indexed fields, falling
+// back to typo-tolerant matching when the exact search finds few results.
+// Titles and contents also match when the roots of all query words appear
+// in them, so "berwudhuk" finds fatwas about "wudhuk". Queries may combine
+// terms with AND/OR/NOT, and quoted phrases only match their exact words.
+// Category searches scan the short category names directly. Title and
+// keyword results are ordered by relevance.
+func searchIndexed(fatwas []Fatwa, index *searchIndex, query string, searchType string) []searchResult {
+	parsed := parseQuery(query, index)
+	stemmed := parsed.stemmedTerms()
+	inContent := searchType == "keyword"
+
+	var docs []int
+	if searchType == "category" {
+		docs = make([]int, len(fatwas))
+		for i := range docs {
+			docs[i] = i
+		}
+	} else {
+		docs = index.candidates(parsed, searchType)
+	}
+
+	var results []searchResult
+	matched := make(map[int]bool)
+
+	for _, i := range docs {
+		match := parsed.matches(func(term queryTerm) bool {
+			if searchType == "category" {
+				if term.Phrase {
+					return containsPhrase(index.Categories[i], term.Text)
+				}
+				return strings.Contains(index.Categories[i], term.Text)
+			}
+
+			if term.Phrase {
+				return containsPhrase(index.Titles[i], term.Text) ||
+					(inContent && containsPhrase(index.Contents[i], term.Text))
+			}
+
+			if strings.Contains(index.Titles[i], term.Text) ||
+				(inContent && strings.Contains(index.Contents[i], term.Text)) {
+				return true
+			}
+
+			// Every root of the term must appear in one of the fields
+			for _, root := range term.Roots {
+				if !index.hasRoot(i, root, inContent) {
+					return false
+				}
+			}
+			return len(term.Roots) > 0
+		})
+
+		if match {
+			results = append(results, searchResult{Fatwa: fatwas[i], Doc: i})
+			matched[i] = true
+		}
+	}
+
+	if searchType == "category" {
+		return results
+	}
+	// Typo tolerance only applies to plain queries, as it would blur the
+	// precision boolean queries and phrases ask for
+	if len(results) >= minExactResults || !parsed.isPlain() {
+		rankResults(results, index, stemmed)
+		return results
+	}
+
+	// Each query word must match one of its close spellings
+	alternatives := fuzzyAlternatives(index.Vocabulary, tokenize(parsed.Groups[0][0].Text))
+	if alternatives == nil {
+		rankResults(results, index, stemmed)
+		return results
+	}
+
+	var fuzzyDocs []int
+	for n, words := range alternatives {
+		if n == 0 {
+			fuzzyDocs = index.wordDocs(words)
+		} else {
+			fuzzyDocs = intersectDocs(fuzzyDocs, index.wordDocs(words))
+		}
+	}
+
+	for _, i := range fuzzyDocs {
+		if matched[i] {
+			continue
+		}
+
+		fields := []string{index.Titles[i]}
+		if inContent {
+			fields = append(fields, index.Contents[i])
+		}
+
+		if matchesAlternatives(fields, alternatives) {
+			results = append(results, searchResult{Fatwa: fatwas[i], Doc: i, Fuzzy: true})
+		}
+	}
+
+	rankResults(results, index, stemmed)
+	return results
+}
+
+func matchesAlternatives(fields []string, alternatives [][]string) bool {
+	for _, words := range alternatives {
+		found := false
+		for _, word := range words {
+			for _, field := range fields {
+				if strings.Contains(field, word) {
+					found = true
+					break
+				}
+			}
+			if found {
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// fuzzyAlternatives returns, for every query word, the corpus words within
+// a small edit distance of it. It returns nil if any word has none.
+func fuzzyAlternatives(vocabulary []string, words []string) [][]string {
+	if len(words) == 0 {
+		return nil
+	}
+
+	alternatives := make([][]string, 0, len(words))
+	for _, word := range words {
+		maxEdits := allowedEdits(word)
+		if maxEdits == 0 {
+			alternatives = append(alternatives, []string{word})
+			continue
+		}
+
+		var close []string
+		for _, candidate := range vocabulary {
+			if editDistance(word, candidate, maxEdits) <= maxEdits {
+				close = append(close, candidate)
+			}
+		}
+		if len(close) == 0 {
+			return nil
+		}
+		alternatives = append(alternatives, close)
+	}
+
+	return alternatives
+}
+
+// allowedEdits scales typo tolerance with word length, so short words
+// don't match half the vocabulary.
+func allowedEdits(word string) int {
+	switch n := len([]rune(word)); {
+	case n < 4:
+		return 0
+	case n < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// tokenize splits lowercased text into words. Apostrophes are kept inside
+// words so transliterated terms such as "jama'" stay intact.
+func tokenize(text string) []string {
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	})
+
+	tokens := words[:0]
+	for _, word := range words {
+		if word = strings.Trim(word, "'"); word != "" {
+			tokens = append(tokens, word)
+		}
+	}
+	return tokens
+}
+
+// editDistance returns the Levenshtein distance between a and b, or
+// max+1 as soon as it is known to exceed max.
+func editDistance(a, b string, max int) int {
+	ra, rb := []rune(a), []rune(b)
+	if diff := len(ra) - len(rb); diff > max || -diff > max {
+		return max + 1
+	}
+
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		rowMin := curr[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, min(curr[j-1]+1, prev[j-1]+cost))
+			if curr[j] < rowMin {
+				rowMin = curr[j]
+			}
+		}
+		if rowMin > max {
+			return max + 1
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
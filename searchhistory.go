@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// recentSearchesShown is the number of searches listed by /history.
+const recentSearchesShown = 10
+
+// searchTypeLabels names the search types in the history list.
+var searchTypeLabels = map[string]string{
+	"keyword":  "🔍",
+	"title":    "📝",
+	"category": "📂",
+	"regex":    "🧩",
+}
+
+// showSearchHistory lists a chat's recent searches as buttons that run
+// them again.
+func (fb *FatwaBot) showSearchHistory(chatID int64) {
+	entries, err := fb.store.RecentSearches(chatID, recentSearchesShown)
+	if err != nil {
+		log.Printf("Error reading search history: %v", err)
+		fb.reply(chatID, "history.read_error")
+		return
+	}
+
+	if len(entries) == 0 {
+		fb.reply(chatID, "history.empty")
+		return
+	}
+
+	var keyboard [][]tgbotapi.InlineKeyboardButton
+	for _, entry := range entries {
+		label := fmt.Sprintf("%s %s", searchTypeLabels[entry.SearchType], entry.Query)
+		keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("rerun_%d", entry.ID)),
+		))
+	}
+
+	msg := tgbotapi.NewMessage(chatID, fb.t(chatID, "history.title"))
+	msg.ParseMode = tgbotapi.ModeHTML
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(keyboard...)
+	fb.send(msg)
+}
+
+// rerunSearch runs a search from the chat's history again. idStr is the
+// history entry ID from the button.
+func (fb *FatwaBot) rerunSearch(chatID int64, idStr string) {
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		fb.reply(chatID, "error.search")
+		return
+	}
+
+	entry, err := fb.store.Search(chatID, id)
+	if err != nil {
+		log.Printf("Error reading search: %v", err)
+		fb.reply(chatID, "history.missing")
+		return
+	}
+
+	fb.searchFatwas(chatID, entry.Query, entry.SearchType)
+}
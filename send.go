@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"html"
+	"log"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// sendAttempts is how many times a message is tried before giving up.
+	sendAttempts = 3
+
+	// sendBackoff is the wait before the first retry, doubled for each
+	// retry after it.
+	sendBackoff = time.Second
+
+	// maxRetryAfter is the longest Telegram may ask the bot to wait before
+	// a message is given up on instead.
+	maxRetryAfter = 30 * time.Second
+
+	// maxMessageLength is the longest text Telegram accepts in a message.
+	maxMessageLength = 4096
+)
+
+// htmlTag matches the tags of messages formatted in HTML.
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// send sends a message, edit or file, logging it if it can't be delivered
+// (see trySend).
+func (fb *FatwaBot) send(c tgbotapi.Chattable) {
+	if _, err := fb.trySend(c); err != nil {
+		log.Printf("Error sending message: %v", err)
+	}
+}
+
+// trySend sends a reply, edit or file to Telegram, in its turn in the
+// outbox. Transient failures, flood limits and server or network errors,
+// are retried with backoff; on a flood limit the whole outbox waits as
+// long as Telegram asks to. Text Telegram can't parse as HTML is sent
+// again as plain text, so a formatting mistake doesn't leave the user
+// without an answer. Edits that change nothing count as delivered.
+func (fb *FatwaBot) trySend(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	return fb.deliver(c, false)
+}
+
+// trySendBulk sends like trySend a message that is one of many, such as a
+// broadcast, digest or notification, after any waiting replies and no
+// faster than the chat's limit allows.
+func (fb *FatwaBot) trySendBulk(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	return fb.deliver(c, true)
+}
+
+func (fb *FatwaBot) deliver(c tgbotapi.Chattable, bulk bool) (tgbotapi.Message, error) {
+	chat := outboxChat(c)
+	wait := sendBackoff
+	for attempt := 1; ; attempt++ {
+		fb.outbox.wait(chat, bulk)
+		sent, err := fb.bot.Send(c)
+		if err == nil {
+			return sent, nil
+		}
+
+		var apiErr *tgbotapi.Error
+		isAPIError := errors.As(err, &apiErr)
+		switch {
+		case isAPIError && strings.Contains(apiErr.Message, "message is not modified"):
+			return sent, nil
+		case isAPIError && strings.Contains(apiErr.Message, "can't parse entities"):
+			plain, ok := withoutParseMode(c)
+			if !ok {
+				return sent, err
+			}
+			log.Printf("Sending as plain text after a formatting error: %v", err)
+			c = plain
+			continue
+		}
+
+		if attempt == sendAttempts || !isTransient(err) {
+			return sent, err
+		}
+
+		if isAPIError && apiErr.RetryAfter > 0 {
+			delay := time.Duration(apiErr.RetryAfter) * time.Second
+			fb.outbox.pause(delay)
+			if delay > maxRetryAfter {
+				return sent, err
+			}
+			continue
+		}
+		time.Sleep(wait)
+		wait *= 2
+	}
+}
+
+// isTransient reports whether sending may succeed if tried again: Telegram
+// asked the bot to slow down or failed itself, or the network did.
+func isTransient(err error) bool {
+	var apiErr *tgbotapi.Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code == 429 || apiErr.Code >= 500
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// withoutParseMode returns a message, edit or file caption formatted in
+// HTML as plain text, with its tags removed and entities decoded. It
+// reports false for anything sent without formatting.
+func withoutParseMode(c tgbotapi.Chattable) (tgbotapi.Chattable, bool) {
+	switch c := c.(type) {
+	case tgbotapi.MessageConfig:
+		if c.ParseMode == "" {
+			return c, false
+		}
+		c.Text, c.ParseMode = htmlToText(c.Text), ""
+		return c, true
+	case tgbotapi.EditMessageTextConfig:
+		if c.ParseMode == "" {
+			return c, false
+		}
+		c.Text, c.ParseMode = htmlToText(c.Text), ""
+		return c, true
+	case tgbotapi.DocumentConfig:
+		if c.ParseMode == "" {
+			return c, false
+		}
+		c.Caption, c.ParseMode = htmlToText(c.Caption), ""
+		return c, true
+	case tgbotapi.VoiceConfig:
+		if c.ParseMode == "" {
+			return c, false
+		}
+		c.Caption, c.ParseMode = htmlToText(c.Caption), ""
+		return c, true
+	}
+	return c, false
+}
+
+// htmlToText strips the formatting from a message written in HTML.
+func htmlToText(text string) string {
+	return html.UnescapeString(htmlTag.ReplaceAllString(text, ""))
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// pageSizes are the numbers of results per page users can choose.
+var pageSizes = []int{5, 10, 20}
+
+// previewLengths are the lengths of result previews users can choose, by
+// name, with the message ID of their button label and their snippet radius.
+var previewLengths = []struct {
+	Name   string
+	Label  string
+	Radius int
+}{
+	{"short", "button.preview_short", snippetRadius / 2},
+	{"normal", "button.preview_normal", snippetRadius},
+	{"long", "button.preview_long", snippetRadius * 2},
+}
+
+// previewRadius returns the snippet radius of a preview length, or the
+// default for unknown lengths.
+func previewRadius(preview string) int {
+	for _, length := range previewLengths {
+		if length.Name == preview {
+			return length.Radius
+		}
+	}
+	return snippetRadius
+}
+
+func isPreviewLength(preview string) bool {
+	for _, length := range previewLengths {
+		if length.Name == preview {
+			return true
+		}
+	}
+	return false
+}
+
+// settings returns a chat's display preferences, or the defaults if they
+// can't be read.
+func (fb *FatwaBot) settings(chatID int64) Settings {
+	settings, err := fb.store.Settings(chatID)
+	if err != nil {
+		log.Printf("Error reading settings: %v", err)
+		return Settings{}
+	}
+	return settings
+}
+
+// showSettings sends the chat's display preferences with buttons to change
+// them.
+func (fb *FatwaBot) showSettings(chatID int64) {
+	text, keyboard := settingsPage(fb.settings(chatID), fb.language(chatID))
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = tgbotapi.ModeHTML
+	msg.ReplyMarkup = keyboard
+	fb.send(msg)
+}
+
+// changeSetting saves a preference picked on the settings message, which
+// is edited to show it. value is "page_N" or "preview_LENGTH". Searches
+// already shown keep the settings they started with.
+func (fb *FatwaBot) changeSetting(message *tgbotapi.Message, value string) {
+	chatID := message.Chat.ID
+	settings := fb.settings(chatID)
+
+	name, option, _ := strings.Cut(value, "_")
+	switch name {
+	case "page":
+		size, err := strconv.Atoi(option)
+		if err != nil || !slices.Contains(pageSizes, size) {
+			fb.reply(chatID, "settings.invalid")
+			return
+		}
+		settings.PageSize = size
+	case "preview":
+		if !isPreviewLength(option) {
+			fb.reply(chatID, "settings.invalid")
+			return
+		}
+		settings.Preview = option
+	default:
+		fb.reply(chatID, "settings.invalid")
+		return
+	}
+
+	if err := fb.store.SetSettings(chatID, settings); err != nil {
+		log.Printf("Error saving settings: %v", err)
+		fb.reply(chatID, "settings.error")
+		return
+	}
+
+	text, keyboard := settingsPage(settings, fb.language(chatID))
+	fb.replaceStatus(chatID, message.MessageID, text, &keyboard)
+}
+
+// settingsPage formats the settings message, with a row of buttons for
+// each preference and the current choices ticked.
+func settingsPage(settings Settings, lang string) (string, tgbotapi.InlineKeyboardMarkup) {
+	pageSize := settings.PageSize
+	if pageSize == 0 {
+		pageSize = resultsPerPage
+	}
+	preview := settings.Preview
+	if preview == "" {
+		preview = "normal"
+	}
+
+	var sizes []tgbotapi.InlineKeyboardButton
+	for _, size := range pageSizes {
+		label := fmt.Sprintf("📄 %d", size)
+		if size == pageSize {
+			label = fmt.Sprintf("✅ %d", size)
+		}
+		sizes = append(sizes, tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("set_page_%d", size)))
+	}
+
+	var lengths []tgbotapi.InlineKeyboardButton
+	var previewName string
+	for _, length := range previewLengths {
+		label := tr(lang, length.Label)
+		if length.Name == preview {
+			previewName = label
+			label = "✅ " + label
+		}
+		lengths = append(lengths, tgbotapi.NewInlineKeyboardButtonData(label, "set_preview_"+length.Name))
+	}
+
+	text := tr(lang, "settings.title", pageSize, previewName)
+	return text, tgbotapi.NewInlineKeyboardMarkup(sizes, lengths)
+}
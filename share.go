@@ -0,0 +1,28 @@
+package main
+
+import (
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// shareButton is the "📤 Kongsi" button under fatwa details. It lets the
+// user pick a chat and fills in an inline query for the fatwa there, so
+// its summary card can be sent in two taps.
+func shareButton(key FatwaKey, lang string) tgbotapi.InlineKeyboardButton {
+	return tgbotapi.NewInlineKeyboardButtonSwitch(tr(lang, "button.share"), key.String())
+}
+
+// sharedFatwa finds the fatwa an inline query from the share button is
+// for. Only full "source:id" keys count, so numbers typed as keywords are
+// still searched.
+func (fb *FatwaBot) sharedFatwa(query string) (Fatwa, bool) {
+	if !strings.Contains(query, ":") {
+		return Fatwa{}, false
+	}
+	key, err := parseFatwaKey(query)
+	if err != nil {
+		return Fatwa{}, false
+	}
+	return fb.findFatwa(key)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"sort"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// similarShown is the number of similar fatwas listed by the 🔁 button.
+	similarShown = 5
+
+	// similarTerms is the number of a fatwa's most distinctive word roots
+	// that other fatwas are compared on.
+	similarTerms = 30
+)
+
+// similarButton is the "🔁 Similar" button under fatwa details.
+func similarButton(key FatwaKey, lang string) tgbotapi.InlineKeyboardButton {
+	return tgbotapi.NewInlineKeyboardButtonData(tr(lang, "button.similar"), fatwaCallback("similar", key))
+}
+
+// sendSimilarFatwas lists the fatwas whose content is closest to one,
+// given its ID as shown in its details ("123" or "source:123").
+func (fb *FatwaBot) sendSimilarFatwas(chatID int64, idStr string) {
+	key, err := parseFatwaKey(idStr)
+	if err != nil {
+		fb.reply(chatID, "id.invalid")
+		return
+	}
+
+	fb.sendTyping(chatID)
+
+	fatwas, index := fb.searchData()
+	doc := -1
+	for i, fatwa := range fatwas {
+		if fatwa.Key() == key {
+			doc = i
+			break
+		}
+	}
+	if doc < 0 {
+		fb.reply(chatID, "id.not_found", key)
+		return
+	}
+
+	similar := similarFatwas(fatwas, index, doc)
+	if len(similar) == 0 {
+		fb.reply(chatID, "similar.none", fatwas[doc].Title)
+		return
+	}
+
+	lang := fb.language(chatID)
+	message := tr(lang, "similar.title", fatwas[doc].Title) + "\n\n"
+	var keyboard [][]tgbotapi.InlineKeyboardButton
+	for i, fatwa := range similar {
+		message += fmt.Sprintf("<b>%d. %s</b>\n", i+1, html.EscapeString(fatwa.Title))
+		message += tr(lang, "results.stats", fatwa.Date, fatwa.Hits) + "\n\n"
+		keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(tr(lang, "button.read_fatwa", i+1), fatwaCallback("view", fatwa.Key())),
+		))
+	}
+
+	msg := tgbotapi.NewMessage(chatID, message)
+	msg.ParseMode = tgbotapi.ModeHTML
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(keyboard...)
+	fb.send(msg)
+}
+
+// similarFatwas returns the fatwas whose wording is closest to that of
+// document doc. The fatwa is reduced to the similarTerms roots of its title
+// and content with the highest tf-idf, which are then run against the
+// other fatwas' contents as a BM25 query weighted by those scores. Unlike
+// relatedFatwas, this ignores categories and finds fatwas discussing the
+// same things under different titles.
+func similarFatwas(fatwas []Fatwa, index *searchIndex, doc int) []Fatwa {
+	total := len(index.ContentLengths)
+	if total < 2 {
+		return nil
+	}
+
+	stemmer := index.malayStemmer()
+	counts := make(map[string]int)
+	for _, text := range []string{index.Titles[doc], index.Contents[doc]} {
+		for _, word := range removeStopwords(tokenize(text)) {
+			counts[stemmer.stem(word)]++
+		}
+	}
+
+	idf := func(root string) float64 {
+		docs := float64(len(index.ContentRoots[root]))
+		return math.Log(1 + (float64(total)-docs+0.5)/(docs+0.5))
+	}
+
+	// Roots no other fatwa contains can't match anything
+	type term struct {
+		Root   string
+		Weight float64
+	}
+	var terms []term
+	for root, count := range counts {
+		if len(index.ContentRoots[root]) < 2 {
+			continue
+		}
+		terms = append(terms, term{Root: root, Weight: float64(count) * idf(root)})
+	}
+	sort.Slice(terms, func(a, b int) bool {
+		if terms[a].Weight != terms[b].Weight {
+			return terms[a].Weight > terms[b].Weight
+		}
+		return terms[a].Root < terms[b].Root
+	})
+	terms = terms[:min(similarTerms, len(terms))]
+
+	var totalLength int
+	for _, length := range index.ContentLengths {
+		totalLength += length
+	}
+	averageLength := math.Max(float64(totalLength)/float64(total), 1)
+
+	scores := make(map[int]float64)
+	for _, t := range terms {
+		termIDF := idf(t.Root)
+		for _, p := range index.ContentRoots[t.Root] {
+			if p.Doc == doc {
+				continue
+			}
+			tf := float64(p.Count)
+			lengthNorm := 1 - bm25B + bm25B*float64(index.ContentLengths[p.Doc])/averageLength
+			scores[p.Doc] += t.Weight * termIDF * tf * (bm25K1 + 1) / (tf + bm25K1*lengthNorm)
+		}
+	}
+
+	candidates := make([]int, 0, len(scores))
+	for i := range scores {
+		// Repeated copies of the same fatwa aren't worth suggesting
+		if strings.EqualFold(fatwas[i].Title, fatwas[doc].Title) {
+			continue
+		}
+		candidates = append(candidates, i)
+	}
+	sort.Slice(candidates, func(a, b int) bool {
+		i, j := candidates[a], candidates[b]
+		if scores[i] != scores[j] {
+			return scores[i] > scores[j]
+		}
+		return i < j
+	})
+
+	var similar []Fatwa
+	for _, i := range candidates[:min(similarShown, len(candidates))] {
+		similar = append(similar, fatwas[i])
+	}
+	return similar
+}
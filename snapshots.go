@@ -0,0 +1,148 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Snapshots are gzip-compressed CSV copies of the dataset taken after each
+// successful scrape, regardless of whether the live dataset is a CSV file
+// or a database.
+
+func snapshotDir() string {
+	if dir := os.Getenv("SNAPSHOT_DIR"); dir != "" {
+		return dir
+	}
+	return "snapshots"
+}
+
+// snapshotKeep returns how many snapshots to retain.
+func snapshotKeep() int {
+	if keep, err := strconv.Atoi(os.Getenv("SNAPSHOT_KEEP")); err == nil && keep > 1 {
+		return keep
+	}
+	return 6
+}
+
+func saveSnapshot(fatwas []Fatwa) (string, error) {
+	if err := os.MkdirAll(snapshotDir(), 0755); err != nil {
+		return "", fmt.Errorf("cannot create snapshot directory: %v", err)
+	}
+
+	path := filepath.Join(snapshotDir(), "fatwa-"+time.Now().Format("20060102-150405")+".csv.gz")
+	if err := exportToCSV(fatwas, path); err != nil {
+		return "", err
+	}
+
+	return path, pruneSnapshots(snapshotKeep())
+}
+
+// listSnapshots returns the available snapshots, oldest first.
+func listSnapshots() ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(snapshotDir(), "fatwa-*.csv.gz"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+func pruneSnapshots(keep int) error {
+	snapshots, err := listSnapshots()
+	if err != nil {
+		return err
+	}
+
+	for len(snapshots) > keep {
+		if err := os.Remove(snapshots[0]); err != nil {
+			return fmt.Errorf("cannot remove old snapshot: %v", err)
+		}
+		// Snapshots taken before manifests existed have none
+		if err := os.Remove(checksumPath(snapshots[0])); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("cannot remove old snapshot manifest: %v", err)
+		}
+		snapshots = snapshots[1:]
+	}
+
+	return nil
+}
+
+// rollbackDataset restores the snapshot taken before the most recent one.
+// The most recent snapshot is marked as rejected so that a second rollback
+// goes one step further back.
+func rollbackDataset() (string, int, error) {
+	snapshots, err := listSnapshots()
+	if err != nil {
+		return "", 0, err
+	}
+	if len(snapshots) < 2 {
+		return "", 0, fmt.Errorf("no previous snapshot to roll back to")
+	}
+
+	latest := snapshots[len(snapshots)-1]
+	previous := snapshots[len(snapshots)-2]
+
+	fatwas, err := loadFatwaData(previous)
+	if err != nil {
+		return "", 0, fmt.Errorf("cannot load snapshot %s: %v", previous, err)
+	}
+
+	if err := saveDataset(fatwas); err != nil {
+		return "", 0, err
+	}
+
+	rejected := filepath.Join(snapshotDir(), "rejected-"+filepath.Base(latest))
+	if err := os.Rename(latest, rejected); err != nil {
+		return "", 0, fmt.Errorf("cannot mark snapshot %s as rejected: %v", latest, err)
+	}
+	if err := os.Rename(checksumPath(latest), checksumPath(rejected)); err != nil && !os.IsNotExist(err) {
+		return "", 0, fmt.Errorf("cannot mark snapshot manifest %s as rejected: %v", latest, err)
+	}
+
+	return filepath.Base(previous), len(fatwas), nil
+}
+
+func runRollback(args []string) error {
+	flags := flag.NewFlagSet("rollback", flag.ExitOnError)
+	list := flags.Bool("list", false, "list available snapshots instead of rolling back")
+	flags.Parse(args)
+
+	if *list {
+		snapshots, err := listSnapshots()
+		if err != nil {
+			return err
+		}
+		for _, snapshot := range snapshots {
+			fmt.Println(snapshot)
+		}
+		return nil
+	}
+
+	name, count, err := rollbackDataset()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Restored %d fatwas from snapshot %s\n", count, name)
+	return nil
+}
+
+func (fb *FatwaBot) handleRollback(chatID int64) {
+	name, count, err := rollbackDataset()
+	if err != nil {
+		fb.reply(chatID, "rollback.failed", err)
+		return
+	}
+
+	if err := fb.reloadDataset(); err != nil {
+		log.Printf("Error reloading dataset after rollback: %v", err)
+	}
+
+	fb.reply(chatID, "rollback.restored", count, name)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// snippetRadius is half the length of a result preview, in characters,
+// unless the user chose another preview length in /settings. Up to half
+// of it is spent on context before the first match.
+const snippetRadius = 60
+
+// highlighter finds the words of a query in fatwa content, either as typed
+// or through their roots. For regex searches it finds the words the
+// pattern matches. Reading is the Rumi reading of a query typed in Jawi.
+type highlighter struct {
+	words   map[string]bool
+	roots   map[string]bool
+	stemmer *malayStemmer
+	pattern *regexp.Regexp
+	reading string
+}
+
+func newHighlighter(query, searchType string, index *searchIndex) *highlighter {
+	stemmer := index.malayStemmer()
+	h := &highlighter{words: make(map[string]bool), roots: make(map[string]bool), stemmer: stemmer}
+
+	switch searchType {
+	case "regex":
+		h.pattern, _ = compileSearchPattern(query)
+		return h
+	case "year", "letter", "popular", "new":
+		// Browsed fatwas have no words to highlight
+		return h
+	}
+
+	if rumi, ok := index.transliterateJawi(foldText(query)); ok {
+		h.reading = rumi
+	}
+
+	parsed := parseQuery(query, index)
+	for _, group := range parsed.Groups {
+		for _, term := range group {
+			for _, variant := range append([]queryTerm{term}, term.Synonyms...) {
+				for _, word := range tokenize(variant.Text) {
+					h.words[word] = true
+				}
+				for _, root := range variant.Roots {
+					h.roots[root] = true
+				}
+			}
+		}
+	}
+	return h
+}
+
+func (h *highlighter) matches(word string) bool {
+	if h.pattern != nil {
+		return h.pattern.MatchString(word)
+	}
+	word = normalizeSearchText(word)
+	return h.words[word] || h.roots[h.stemmer.stem(word)]
+}
+
+// snippet returns an excerpt of content of about twice radius characters
+// around the first query match, escaped for HTML, with matched words in
+// bold. Without a match it falls back to the start of the content.
+func (h *highlighter) snippet(content string, radius int) string {
+	type span struct{ start, end int }
+
+	var words []span
+	first := -1
+	for start := 0; start < len(content); {
+		r, size := utf8.DecodeRuneInString(content[start:])
+		if !isWordRune(r) {
+			start += size
+			continue
+		}
+		end := start
+		for end < len(content) {
+			r, size := utf8.DecodeRuneInString(content[end:])
+			if !isWordRune(r) && r != '\'' {
+				break
+			}
+			end += size
+		}
+		word := span{start, end}
+		for word.end > word.start && content[word.end-1] == '\'' {
+			word.end--
+		}
+		if first < 0 && h.matches(content[word.start:word.end]) {
+			first = len(words)
+		}
+		words = append(words, word)
+		start = end
+	}
+
+	if len(words) == 0 {
+		return ""
+	}
+
+	// Widen the window word by word, mostly after the match
+	anchor := max(first, 0)
+	from, to := anchor, anchor
+	for from > 0 && utf8.RuneCountInString(content[words[from-1].start:words[anchor].start]) <= radius/2 {
+		from--
+	}
+	for to < len(words)-1 && utf8.RuneCountInString(content[words[from].start:words[to+1].end]) <= radius*2 {
+		to++
+	}
+
+	var snippet strings.Builder
+	if from > 0 {
+		snippet.WriteString("...")
+	}
+	position := words[from].start
+	for _, word := range words[from : to+1] {
+		snippet.WriteString(html.EscapeString(content[position:word.start]))
+		text := content[word.start:word.end]
+		if h.matches(text) {
+			snippet.WriteString("<b>" + html.EscapeString(text) + "</b>")
+		} else {
+			snippet.WriteString(html.EscapeString(text))
+		}
+		position = word.end
+	}
+	if to < len(words)-1 {
+		snippet.WriteString("...")
+	}
+
+	return strings.Join(strings.Fields(snippet.String()), " ")
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"sort"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Sort orders offered on the results message. Results come ranked by
+// relevance, so sortRelevance leaves them as they are.
+const (
+	sortRelevance = "relevan"
+	sortNewest    = "terbaru"
+	sortPopular   = "popular"
+)
+
+// sortLabels lists the sort orders with their icon and the message ID of
+// their name.
+var sortLabels = []struct {
+	Mode string
+	Icon string
+	Name string
+}{
+	{sortRelevance, "🎯", "sort.relevance"},
+	{sortNewest, "🆕", "sort.newest"},
+	{sortPopular, "👁", "sort.popular"},
+}
+
+func isSortMode(mode string) bool {
+	return sortName(mode) != ""
+}
+
+// sortName returns the message ID of the name of a sort order.
+func sortName(mode string) string {
+	for _, option := range sortLabels {
+		if option.Mode == mode {
+			return option.Name
+		}
+	}
+	return ""
+}
+
+// sortResults reorders results in place. Fatwas whose date cannot be
+// parsed go last when sorting by date.
+func sortResults(results []searchResult, mode string) {
+	switch mode {
+	case sortNewest:
+		sort.SliceStable(results, func(a, b int) bool {
+			dateA, okA := parseFatwaDate(results[a].Fatwa.Date)
+			dateB, okB := parseFatwaDate(results[b].Fatwa.Date)
+			if okA != okB {
+				return okA
+			}
+			return dateA.After(dateB)
+		})
+	case sortPopular:
+		sort.SliceStable(results, func(a, b int) bool {
+			return results[a].Fatwa.Hits > results[b].Fatwa.Hits
+		})
+	}
+}
+
+// sortButtons is the "Susun ikut" row of the results message, with the
+// current order marked.
+func sortButtons(hash, current, lang string) []tgbotapi.InlineKeyboardButton {
+	var row []tgbotapi.InlineKeyboardButton
+	for _, option := range sortLabels {
+		label := option.Icon + " " + tr(lang, option.Name)
+		if option.Mode == current {
+			label = "✅ " + tr(lang, option.Name)
+		}
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(
+			label,
+			callbackData{Action: "sort", Hash: hash, Arg: option.Mode}.String(),
+		))
+	}
+	return row
+}
@@ -0,0 +1,118 @@
+package main
+
+import "strings"
+
+// maxCorrectionEdits is the largest number of edits a spelling correction
+// may make to a query word. Shorter words allow fewer, see allowedEdits.
+const maxCorrectionEdits = 2
+
+// correctionPrefix is the number of leading characters deletions are
+// generated from. Words rarely differ only past it, and it bounds the
+// number of deletions stored per word.
+const correctionPrefix = 7
+
+// spellingDeletes maps every string obtained by deleting up to
+// maxCorrectionEdits characters from the start of a corpus word to the
+// words it came from. A misspelled word shares one of its own deletions
+// with each word within that many edits of it, so corrections are looked
+// up instead of compared against the whole vocabulary (the SymSpell
+// approach). It is built on first use, like the stemmer.
+func (index *searchIndex) spellingDeletes() map[string][]string {
+	index.deletesOnce.Do(func() {
+		index.deletes = make(map[string][]string)
+		for _, word := range index.Vocabulary {
+			for _, variant := range deleteVariants(word) {
+				index.deletes[variant] = append(index.deletes[variant], word)
+			}
+		}
+	})
+	return index.deletes
+}
+
+// deleteVariants returns the start of word with up to maxCorrectionEdits
+// characters deleted, including the unchanged start itself.
+func deleteVariants(word string) []string {
+	start := []rune(word)
+	if len(start) > correctionPrefix {
+		start = start[:correctionPrefix]
+	}
+
+	seen := map[string]bool{string(start): true}
+	level := []string{string(start)}
+	variants := []string{string(start)}
+	for edit := 0; edit < maxCorrectionEdits; edit++ {
+		var next []string
+		for _, variant := range level {
+			runes := []rune(variant)
+			for i := range runes {
+				deleted := string(runes[:i]) + string(runes[i+1:])
+				if !seen[deleted] {
+					seen[deleted] = true
+					next = append(next, deleted)
+				}
+			}
+		}
+		variants = append(variants, next...)
+		level = next
+	}
+	return variants
+}
+
+// correctWord returns the corpus word closest to a word missing from the
+// corpus, preferring common words among equally close ones. Words in the
+// corpus, stopwords and words too short to correct are returned as is.
+func (index *searchIndex) correctWord(word string) string {
+	maxEdits := min(allowedEdits(word), maxCorrectionEdits)
+	if maxEdits == 0 || index.WordDocs[word] != nil || malayStopwords[word] {
+		return word
+	}
+
+	deletes := index.spellingDeletes()
+	best, bestDistance, bestDocs := word, maxEdits+1, 0
+	seen := make(map[string]bool)
+	for _, variant := range deleteVariants(word) {
+		for _, candidate := range deletes[variant] {
+			if seen[candidate] {
+				continue
+			}
+			seen[candidate] = true
+
+			distance := editDistance(word, candidate, maxEdits)
+			docs := len(index.WordDocs[candidate])
+			if distance < bestDistance || (distance == bestDistance && docs > bestDocs) {
+				best, bestDistance, bestDocs = candidate, distance, docs
+			}
+		}
+	}
+	return best
+}
+
+// correctQuery corrects the misspelled words of a query, so "solat jamak
+// qasar" becomes "solat jama qasar". Operators, quoted phrases and words
+// in Arabic script are left alone. It reports false if nothing changed.
+func (index *searchIndex) correctQuery(query string) (string, bool) {
+	var parts []string
+	changed := false
+
+	for _, word := range splitQuery(query) {
+		if word.Quoted {
+			parts = append(parts, `"`+word.Text+`"`)
+			continue
+		}
+
+		tokens := tokenize(normalizeSearchText(word.Text))
+		if queryOperators[word.Text] != "" || len(tokens) != 1 || strings.ContainsFunc(word.Text, isArabic) {
+			parts = append(parts, word.Text)
+			continue
+		}
+
+		if corrected := index.correctWord(tokens[0]); corrected != tokens[0] {
+			parts = append(parts, corrected)
+			changed = true
+		} else {
+			parts = append(parts, word.Text)
+		}
+	}
+
+	return strings.Join(parts, " "), changed
+}
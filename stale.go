@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// staleFatwa answers a button for a fatwa that is no longer in the dataset,
+// such as one sent before a reload dropped or renumbered it. The user is
+// alerted, rather than left tapping a button that does nothing, and offered
+// the chat's latest search again to find the fatwa as it is now. The
+// callback is logged to diagnose what went missing.
+func (fb *FatwaBot) staleFatwa(callbackQuery *tgbotapi.CallbackQuery, key FatwaKey) {
+	chatID := callbackQuery.Message.Chat.ID
+	log.Printf("Stale callback %q in chat %d: fatwa %s is not among the %d loaded", callbackQuery.Data, chatID, key, len(fb.dataset()))
+
+	callback := tgbotapi.NewCallbackWithAlert(callbackQuery.ID, htmlToText(fb.t(chatID, "stale.alert", key)))
+	if _, err := fb.bot.Request(callback); err != nil {
+		log.Printf("Error answering stale callback: %v", err)
+	}
+
+	entries, err := fb.store.RecentSearches(chatID, 1)
+	if err != nil {
+		log.Printf("Error reading search history: %v", err)
+		return
+	}
+	if len(entries) == 0 {
+		return
+	}
+
+	entry := entries[0]
+	msg := tgbotapi.NewMessage(chatID, fb.t(chatID, "stale.rerun", entry.Query))
+	msg.ParseMode = tgbotapi.ModeHTML
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(fb.t(chatID, "button.rerun"), fmt.Sprintf("rerun_%d", entry.ID)),
+	))
+	fb.send(msg)
+}
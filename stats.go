@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"time"
+)
+
+// statsCategoriesShown is the number of largest categories listed by
+// /stats.
+const statsCategoriesShown = 10
+
+// showStats tells users how large and how current the dataset is: its
+// size, its largest categories, its newest fatwa and the last scrape.
+func (fb *FatwaBot) showStats(chatID int64) {
+	lang := fb.language(chatID)
+	fatwas := fb.dataset()
+
+	newest := tr(lang, "stats.unknown")
+	var newestDate time.Time
+	for _, fatwa := range fatwas {
+		if date, ok := parseFatwaDate(fatwa.Date); ok && date.After(newestDate) {
+			newestDate = date
+			newest = fatwa.Date
+		}
+	}
+
+	scraped := tr(lang, "stats.unknown")
+	if finished, ok := lastScrape(); ok {
+		scraped = finished.Format(adminTimeFormat)
+	}
+
+	message := tr(lang, "stats.summary", len(fatwas), newest, scraped) + "\n\n"
+
+	categories, counts := fb.datasetCategories()
+	sort.SliceStable(categories, func(i, j int) bool {
+		return counts[categories[i]] > counts[categories[j]]
+	})
+	message += tr(lang, "stats.categories", len(categories)) + "\n"
+	for _, category := range categories[:min(statsCategoriesShown, len(categories))] {
+		message += fmt.Sprintf("• %s: %d\n", html.EscapeString(category), counts[category])
+	}
+	if len(categories) > statsCategoriesShown {
+		message += tr(lang, "stats.more", len(categories)-statsCategoriesShown) + "\n"
+	}
+
+	fb.sendMessage(chatID, message)
+}
@@ -0,0 +1,135 @@
+package main
+
+import "strings"
+
+// malayStemmer reduces Malay words to their root by removing affixes such
+// as ber-, meng-, di-, -kan, -an and -nya. Without a dictionary, affix
+// removal is ambiguous ("sembelih" is a root, not se- + "mbelih"), so a
+// candidate root is only accepted if it occurs as a word in the corpus.
+type malayStemmer struct {
+	lexicon map[string]bool
+}
+
+var (
+	malayParticles   = []string{"lah", "kah", "tah", "pun"}
+	malayPossessives = []string{"nya", "ku", "mu"}
+	malaySuffixes    = []string{"kan", "an", "i"}
+	malayOuterPrefix = []string{"di", "ke", "se"}
+)
+
+func newMalayStemmer(vocabulary []string) *malayStemmer {
+	lexicon := make(map[string]bool, len(vocabulary))
+	for _, word := range vocabulary {
+		lexicon[word] = true
+	}
+	return &malayStemmer{lexicon: lexicon}
+}
+
+// stem returns the root of a lowercased word, or the word itself if no
+// shorter root is found in the corpus.
+func (s *malayStemmer) stem(word string) string {
+	root := word
+	if len([]rune(word)) > 4 {
+	search:
+		for _, base := range suffixForms(word) {
+			for _, candidate := range prefixForms(base) {
+				if candidate != word && len([]rune(candidate)) >= 3 && s.lexicon[candidate] {
+					root = candidate
+					break search
+				}
+			}
+		}
+	}
+
+	return root
+}
+
+// suffixForms lists the word with suffixes removed, most stripped first.
+func suffixForms(word string) []string {
+	forms := []string{word}
+
+	current := word
+	for _, group := range [][]string{malayParticles, malayPossessives, malaySuffixes} {
+		for _, suffix := range group {
+			if strings.HasSuffix(current, suffix) && len(current)-len(suffix) >= 3 {
+				current = strings.TrimSuffix(current, suffix)
+				forms = append(forms, current)
+				break
+			}
+		}
+	}
+
+	// Reverse so the most stripped form comes first
+	for i, j := 0, len(forms)-1; i < j; i, j = i+1, j-1 {
+		forms[i], forms[j] = forms[j], forms[i]
+	}
+	return forms
+}
+
+// prefixForms lists the word with prefixes removed, most stripped first,
+// including the sound changes of the meN- and peN- prefixes
+// (menyembelih → sembelih, memukul → pukul, menulis → tulis).
+func prefixForms(word string) []string {
+	var forms []string
+
+	bases := []string{word}
+	for _, prefix := range malayOuterPrefix {
+		if strings.HasPrefix(word, prefix) {
+			bases = append([]string{strings.TrimPrefix(word, prefix)}, bases...)
+			break
+		}
+	}
+
+	for _, base := range bases {
+		forms = append(forms, innerPrefixForms(base)...)
+		forms = append(forms, base)
+	}
+	return forms
+}
+
+func innerPrefixForms(word string) []string {
+	startsWithVowel := func(s string) bool {
+		return s != "" && strings.ContainsRune("aeiou", rune(s[0]))
+	}
+
+	var forms []string
+	for _, prefix := range []string{"meng", "peng", "meny", "peny", "mem", "pem", "men", "pen", "me", "pe"} {
+		if !strings.HasPrefix(word, prefix) {
+			continue
+		}
+		rest := strings.TrimPrefix(word, prefix)
+
+		switch {
+		case (prefix == "meny" || prefix == "peny") && startsWithVowel(rest):
+			forms = append(forms, "s"+rest)
+		case (prefix == "mem" || prefix == "pem") && startsWithVowel(rest):
+			forms = append(forms, "p"+rest, rest)
+		case (prefix == "men" || prefix == "pen") && startsWithVowel(rest):
+			forms = append(forms, "t"+rest, rest)
+		case (prefix == "meng" || prefix == "peng") && startsWithVowel(rest):
+			forms = append(forms, rest, "k"+rest)
+		default:
+			forms = append(forms, rest)
+		}
+		break
+	}
+
+	for _, prefix := range []string{"ber", "bel", "be", "ter", "per"} {
+		if strings.HasPrefix(word, prefix) {
+			forms = append(forms, strings.TrimPrefix(word, prefix))
+			break
+		}
+	}
+
+	return forms
+}
+
+// stemText stems every word of lowercased text and joins the roots with
+// single spaces.
+func (s *malayStemmer) stemText(text string) string {
+	tokens := tokenize(text)
+	for i, token := range tokens {
+		tokens[i] = s.stem(token)
+	}
+	return strings.Join(tokens, " ")
+}
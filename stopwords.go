@@ -0,0 +1,33 @@
+package main
+
+// malayStopwords are words common in questions and connecting phrases
+// that say nothing about which fatwa is wanted. "hukum" is included as
+// nearly every question asks for the ruling on something.
+var malayStopwords = map[string]bool{
+	"adakah": true, "apakah": true, "apa": true, "bagaimana": true,
+	"bagaimanakah": true, "bolehkah": true, "mengapa": true, "kenapa": true,
+	"bila": true, "bilakah": true, "siapa": true, "siapakah": true,
+	"hukum": true, "yang": true, "untuk": true, "dan": true, "atau": true,
+	"di": true, "ke": true, "dari": true, "daripada": true, "kepada": true,
+	"dengan": true, "dalam": true, "pada": true, "oleh": true, "bagi": true,
+	"ini": true, "itu": true, "ialah": true, "adalah": true, "akan": true,
+	"juga": true, "tentang": true, "mengenai": true, "saya": true,
+	"kita": true, "kami": true, "jika": true, "kalau": true, "sahaja": true,
+	"boleh": true, "tak": true, "tidak": true,
+	"tolong": true, "nak": true, "ingin": true, "mahu": true, "tanya": true,
+}
+
+// removeStopwords drops stopwords from query words. The words are returned
+// unchanged if nothing else would be left, so "hukum" alone still searches.
+func removeStopwords(words []string) []string {
+	var kept []string
+	for _, word := range words {
+		if !malayStopwords[word] {
+			kept = append(kept, word)
+		}
+	}
+	if len(kept) == 0 {
+		return words
+	}
+	return kept
+}
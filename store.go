@@ -0,0 +1,1262 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UserStore persists per-chat state (language, bookmarks, subscriptions
+// and search history) in SQLite, so restarts and redeploys don't wipe user
+// settings. It is kept in its own database file, separate from the
+// dataset, so user activity never triggers a dataset reload. Queries,
+// bookmarks and subscriptions are encrypted when a key is configured.
+type UserStore struct {
+	db      *sql.DB
+	cipher  *fieldCipher
+	chatKey []byte // see chatHash
+}
+
+// userDatabasePath returns the location of the user-state database.
+func userDatabasePath() string {
+	if path := os.Getenv("USER_DB_PATH"); path != "" {
+		return path
+	}
+	return "users.db"
+}
+
+func openUserStore(path string, cipher *fieldCipher) (*UserStore, error) {
+	db, err := openDatabase(path, userMigrations)
+	if err != nil {
+		return nil, err
+	}
+	s := &UserStore{db: db, cipher: cipher}
+
+	if cipher != nil {
+		s.chatKey = cipher.chatKey
+	} else if s.chatKey, err = s.storeKey("chat"); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := s.hashLegacyActivity(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := s.sealLegacyValues(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// storeKey returns a random key kept in the store under a name, creating
+// it the first time.
+func (s *UserStore) storeKey(name string) ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("cannot create %s key: %v", name, err)
+	}
+	if _, err := s.db.Exec("INSERT OR IGNORE INTO store_keys (name, key) VALUES (?, ?)", name, key); err != nil {
+		return nil, fmt.Errorf("cannot save %s key: %v", name, err)
+	}
+	if err := s.db.QueryRow("SELECT key FROM store_keys WHERE name = ?", name).Scan(&key); err != nil {
+		return nil, fmt.Errorf("cannot read %s key: %v", name, err)
+	}
+	return key, nil
+}
+
+// chatHash identifies a chat in usage data, such as daily activity,
+// without its ID: an HMAC keyed by USER_DATA_KEY, or by a key kept in the
+// store when no key is configured.
+func (s *UserStore) chatHash(chatID int64) string {
+	mac := hmac.New(sha256.New, s.chatKey)
+	mac.Write([]byte(strconv.FormatInt(chatID, 10)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// hashLegacyActivity replaces the chat IDs of daily activity recorded
+// before it was anonymized with their hashes.
+func (s *UserStore) hashLegacyActivity() error {
+	var legacy int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'activity_legacy'").Scan(&legacy)
+	if err != nil || legacy == 0 {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("cannot anonymize activity: %v", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query("SELECT day, chat_id FROM activity_legacy")
+	if err != nil {
+		return fmt.Errorf("cannot read activity: %v", err)
+	}
+	type activity struct {
+		day    string
+		chatID int64
+	}
+	var days []activity
+	for rows.Next() {
+		var a activity
+		if err := rows.Scan(&a.day, &a.chatID); err != nil {
+			rows.Close()
+			return fmt.Errorf("cannot read activity: %v", err)
+		}
+		days = append(days, a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("cannot read activity: %v", err)
+	}
+
+	for _, a := range days {
+		if _, err := tx.Exec("INSERT OR IGNORE INTO activity (day, chat_hash) VALUES (?, ?)", a.day, s.chatHash(a.chatID)); err != nil {
+			return fmt.Errorf("cannot anonymize activity: %v", err)
+		}
+	}
+	if _, err := tx.Exec("DROP TABLE activity_legacy"); err != nil {
+		return fmt.Errorf("cannot anonymize activity: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("cannot anonymize activity: %v", err)
+	}
+	return nil
+}
+
+// sealedColumns lists the user data encrypted when a key is configured,
+// as table and column.
+var sealedColumns = [][2]string{
+	{"bookmarks", "fatwa_id"},
+	{"subscriptions", "category"},
+	{"search_history", "query"},
+	{"alerts", "query"},
+	{"query_log", "query"},
+	{"conversations", "category"},
+	{"feedback", "query"},
+	{"topic_requests", "query"},
+}
+
+// sealLegacyValues encrypts the user data written before a key was
+// configured. Lookups compare sealed values, so plaintext rows would
+// otherwise never match again. A row whose sealed value already exists,
+// such as a subscription made again since, is dropped as a duplicate.
+func (s *UserStore) sealLegacyValues() error {
+	if s.cipher == nil {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("cannot encrypt user data: %v", err)
+	}
+	defer tx.Rollback()
+
+	for _, column := range sealedColumns {
+		table, name := column[0], column[1]
+
+		rows, err := tx.Query(fmt.Sprintf("SELECT rowid, %s FROM %s WHERE %s IS NOT NULL AND %s NOT LIKE ?", name, table, name, name), sealedPrefix+"%")
+		if err != nil {
+			return fmt.Errorf("cannot read %s: %v", table, err)
+		}
+		type plain struct {
+			rowid int64
+			value string
+		}
+		var values []plain
+		for rows.Next() {
+			var p plain
+			if err := rows.Scan(&p.rowid, &p.value); err != nil {
+				rows.Close()
+				return fmt.Errorf("cannot read %s: %v", table, err)
+			}
+			values = append(values, p)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("cannot read %s: %v", table, err)
+		}
+
+		for _, p := range values {
+			result, err := tx.Exec(fmt.Sprintf("UPDATE OR IGNORE %s SET %s = ? WHERE rowid = ?", table, name), s.cipher.seal(p.value), p.rowid)
+			if err != nil {
+				return fmt.Errorf("cannot encrypt %s: %v", table, err)
+			}
+			if n, _ := result.RowsAffected(); n > 0 {
+				continue
+			}
+			if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE rowid = ?", table), p.rowid); err != nil {
+				return fmt.Errorf("cannot encrypt %s: %v", table, err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("cannot encrypt user data: %v", err)
+	}
+	return nil
+}
+
+func (s *UserStore) Close() error {
+	return s.db.Close()
+}
+
+// storeTimestamp formats the current time for the TEXT timestamp columns.
+func storeTimestamp() string {
+	return time.Now().UTC().Format(time.RFC3339)
+}
+
+// TouchUser records that a chat has interacted with the bot, and on which
+// day for counting daily active users. It reports whether the chat is new.
+func (s *UserStore) TouchUser(chatID int64) (bool, error) {
+	result, err := s.db.Exec("INSERT OR IGNORE INTO users (chat_id, created_at, updated_at) VALUES (?, ?, ?)",
+		chatID, storeTimestamp(), storeTimestamp())
+	if err != nil {
+		return false, fmt.Errorf("cannot record user %d: %v", chatID, err)
+	}
+	created, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("cannot record user %d: %v", chatID, err)
+	}
+	if created == 0 {
+		_, err = s.db.Exec("UPDATE users SET updated_at = ? WHERE chat_id = ?", storeTimestamp(), chatID)
+		if err != nil {
+			return false, fmt.Errorf("cannot record user %d: %v", chatID, err)
+		}
+	}
+
+	_, err = s.db.Exec("INSERT OR IGNORE INTO activity (day, chat_hash) VALUES (?, ?)", time.Now().UTC().Format(time.DateOnly), s.chatHash(chatID))
+	if err != nil {
+		return false, fmt.Errorf("cannot record activity of %d: %v", chatID, err)
+	}
+	return created > 0, nil
+}
+
+// ChatIDs returns every chat that has interacted with the bot.
+func (s *UserStore) ChatIDs() ([]int64, error) {
+	rows, err := s.db.Query("SELECT chat_id FROM users ORDER BY chat_id")
+	if err != nil {
+		return nil, fmt.Errorf("cannot read users: %v", err)
+	}
+	defer rows.Close()
+
+	var chatIDs []int64
+	for rows.Next() {
+		var chatID int64
+		if err := rows.Scan(&chatID); err != nil {
+			return nil, fmt.Errorf("cannot read user: %v", err)
+		}
+		chatIDs = append(chatIDs, chatID)
+	}
+	return chatIDs, rows.Err()
+}
+
+// CountUsers returns how many chats have used the bot since a time, and
+// how many of them are groups. The zero time counts every chat.
+func (s *UserStore) CountUsers(since time.Time) (chats, groups int, err error) {
+	err = s.db.QueryRow("SELECT COUNT(*), COALESCE(SUM(chat_id < 0), 0) FROM users WHERE updated_at >= ?",
+		since.UTC().Format(time.RFC3339)).Scan(&chats, &groups)
+	if err != nil {
+		return 0, 0, fmt.Errorf("cannot count users: %v", err)
+	}
+	return chats, groups, nil
+}
+
+func (s *UserStore) Language(chatID int64) (string, error) {
+	var language string
+	err := s.db.QueryRow("SELECT language FROM users WHERE chat_id = ?", chatID).Scan(&language)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("cannot read language for %d: %v", chatID, err)
+	}
+	return language, nil
+}
+
+func (s *UserStore) SetLanguage(chatID int64, language string) error {
+	_, err := s.db.Exec(`INSERT INTO users (chat_id, language, created_at, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(chat_id) DO UPDATE SET language = excluded.language, updated_at = excluded.updated_at`,
+		chatID, language, storeTimestamp(), storeTimestamp())
+	if err != nil {
+		return fmt.Errorf("cannot save language for %d: %v", chatID, err)
+	}
+	return nil
+}
+
+// Settings are a chat's display preferences. Zero values mean the
+// defaults.
+type Settings struct {
+	PageSize int
+	Preview  string
+}
+
+func (s *UserStore) Settings(chatID int64) (Settings, error) {
+	var settings Settings
+	err := s.db.QueryRow("SELECT page_size, preview FROM users WHERE chat_id = ?", chatID).Scan(&settings.PageSize, &settings.Preview)
+	if err == sql.ErrNoRows {
+		return Settings{}, nil
+	}
+	if err != nil {
+		return Settings{}, fmt.Errorf("cannot read settings for %d: %v", chatID, err)
+	}
+	return settings, nil
+}
+
+func (s *UserStore) SetSettings(chatID int64, settings Settings) error {
+	_, err := s.db.Exec(`INSERT INTO users (chat_id, page_size, preview, created_at, updated_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(chat_id) DO UPDATE SET page_size = excluded.page_size, preview = excluded.preview, updated_at = excluded.updated_at`,
+		chatID, settings.PageSize, settings.Preview, storeTimestamp(), storeTimestamp())
+	if err != nil {
+		return fmt.Errorf("cannot save settings for %d: %v", chatID, err)
+	}
+	return nil
+}
+
+func (s *UserStore) AddBookmark(chatID int64, key FatwaKey) error {
+	_, err := s.db.Exec("INSERT OR IGNORE INTO bookmarks (chat_id, fatwa_id, created_at) VALUES (?, ?, ?)", chatID, s.cipher.seal(key.String()), storeTimestamp())
+	if err != nil {
+		return fmt.Errorf("cannot save bookmark for %d: %v", chatID, err)
+	}
+	return nil
+}
+
+func (s *UserStore) RemoveBookmark(chatID int64, key FatwaKey) error {
+	_, err := s.db.Exec("DELETE FROM bookmarks WHERE chat_id = ? AND fatwa_id = ?", chatID, s.cipher.seal(key.String()))
+	if err != nil {
+		return fmt.Errorf("cannot remove bookmark for %d: %v", chatID, err)
+	}
+	return nil
+}
+
+// Bookmarks returns the bookmarked fatwas of a chat, newest first.
+func (s *UserStore) Bookmarks(chatID int64) ([]FatwaKey, error) {
+	rows, err := s.db.Query("SELECT fatwa_id FROM bookmarks WHERE chat_id = ? ORDER BY created_at DESC", chatID)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read bookmarks for %d: %v", chatID, err)
+	}
+	defer rows.Close()
+
+	var keys []FatwaKey
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, fmt.Errorf("cannot read bookmark: %v", err)
+		}
+		value, err := s.cipher.open(value)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read bookmark: %v", err)
+		}
+		key, err := parseFatwaKey(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bookmark: %v", err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func (s *UserStore) Subscribe(chatID int64, category string) error {
+	_, err := s.db.Exec("INSERT OR IGNORE INTO subscriptions (chat_id, category, created_at) VALUES (?, ?, ?)", chatID, s.cipher.seal(category), storeTimestamp())
+	if err != nil {
+		return fmt.Errorf("cannot save subscription for %d: %v", chatID, err)
+	}
+	return nil
+}
+
+func (s *UserStore) Unsubscribe(chatID int64, category string) error {
+	_, err := s.db.Exec("DELETE FROM subscriptions WHERE chat_id = ? AND category = ?", chatID, s.cipher.seal(category))
+	if err != nil {
+		return fmt.Errorf("cannot remove subscription for %d: %v", chatID, err)
+	}
+	return nil
+}
+
+func (s *UserStore) Subscriptions(chatID int64) ([]string, error) {
+	rows, err := s.db.Query("SELECT category FROM subscriptions WHERE chat_id = ?", chatID)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read subscriptions for %d: %v", chatID, err)
+	}
+	defer rows.Close()
+
+	var categories []string
+	for rows.Next() {
+		var category string
+		if err := rows.Scan(&category); err != nil {
+			return nil, fmt.Errorf("cannot read subscription: %v", err)
+		}
+		category, err := s.cipher.open(category)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read subscription: %v", err)
+		}
+		categories = append(categories, category)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(categories)
+	return categories, nil
+}
+
+// Subscribers returns the chats subscribed to a category.
+func (s *UserStore) Subscribers(category string) ([]int64, error) {
+	rows, err := s.db.Query("SELECT chat_id FROM subscriptions WHERE category = ?", s.cipher.seal(category))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read subscribers of %s: %v", category, err)
+	}
+	defer rows.Close()
+
+	var chatIDs []int64
+	for rows.Next() {
+		var chatID int64
+		if err := rows.Scan(&chatID); err != nil {
+			return nil, fmt.Errorf("cannot read subscriber: %v", err)
+		}
+		chatIDs = append(chatIDs, chatID)
+	}
+	return chatIDs, rows.Err()
+}
+
+// searchHistoryLimit is the number of searches kept per chat.
+const searchHistoryLimit = 50
+
+// SearchHistoryEntry is a search made by a chat.
+type SearchHistoryEntry struct {
+	ID         int64
+	Query      string
+	SearchType string
+}
+
+// AddSearch records a search in a chat's history, dropping the oldest
+// searches beyond searchHistoryLimit.
+func (s *UserStore) AddSearch(chatID int64, query, searchType string) error {
+	_, err := s.db.Exec("INSERT INTO search_history (chat_id, query, search_type, searched_at) VALUES (?, ?, ?, ?)",
+		chatID, s.cipher.seal(query), searchType, storeTimestamp())
+	if err != nil {
+		return fmt.Errorf("cannot save search for %d: %v", chatID, err)
+	}
+
+	_, err = s.db.Exec(`DELETE FROM search_history WHERE chat_id = ? AND id NOT IN (
+		SELECT id FROM search_history WHERE chat_id = ? ORDER BY id DESC LIMIT ?)`, chatID, chatID, searchHistoryLimit)
+	if err != nil {
+		return fmt.Errorf("cannot prune search history for %d: %v", chatID, err)
+	}
+	return nil
+}
+
+// RecentSearches returns up to limit distinct searches of a chat, newest
+// first.
+func (s *UserStore) RecentSearches(chatID int64, limit int) ([]SearchHistoryEntry, error) {
+	rows, err := s.db.Query("SELECT id, query, search_type FROM search_history WHERE chat_id = ? ORDER BY id DESC", chatID)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read search history for %d: %v", chatID, err)
+	}
+	defer rows.Close()
+
+	var entries []SearchHistoryEntry
+	seen := make(map[SearchHistoryEntry]bool)
+	for rows.Next() && len(entries) < limit {
+		var entry SearchHistoryEntry
+		if err := rows.Scan(&entry.ID, &entry.Query, &entry.SearchType); err != nil {
+			return nil, fmt.Errorf("cannot read search history: %v", err)
+		}
+		if entry.Query, err = s.cipher.open(entry.Query); err != nil {
+			return nil, fmt.Errorf("cannot read search history: %v", err)
+		}
+
+		key := SearchHistoryEntry{Query: entry.Query, SearchType: entry.SearchType}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// Search returns a search from a chat's history by its ID.
+func (s *UserStore) Search(chatID, id int64) (SearchHistoryEntry, error) {
+	entry := SearchHistoryEntry{ID: id}
+	err := s.db.QueryRow("SELECT query, search_type FROM search_history WHERE chat_id = ? AND id = ?", chatID, id).
+		Scan(&entry.Query, &entry.SearchType)
+	if err != nil {
+		return entry, fmt.Errorf("cannot read search %d for %d: %v", id, chatID, err)
+	}
+	if entry.Query, err = s.cipher.open(entry.Query); err != nil {
+		return entry, fmt.Errorf("cannot read search %d for %d: %v", id, chatID, err)
+	}
+	return entry, nil
+}
+
+// Alert is a saved search whose new results are sent to the chat.
+type Alert struct {
+	ID     int64
+	ChatID int64
+	Query  string
+}
+
+func (s *UserStore) AddAlert(chatID int64, query string) error {
+	_, err := s.db.Exec("INSERT OR IGNORE INTO alerts (chat_id, query, created_at) VALUES (?, ?, ?)", chatID, s.cipher.seal(query), storeTimestamp())
+	if err != nil {
+		return fmt.Errorf("cannot save alert for %d: %v", chatID, err)
+	}
+	return nil
+}
+
+func (s *UserStore) RemoveAlert(chatID, id int64) error {
+	_, err := s.db.Exec("DELETE FROM alerts WHERE chat_id = ? AND id = ?", chatID, id)
+	if err != nil {
+		return fmt.Errorf("cannot remove alert for %d: %v", chatID, err)
+	}
+	return nil
+}
+
+// Alerts returns the saved searches of a chat, oldest first.
+func (s *UserStore) Alerts(chatID int64) ([]Alert, error) {
+	return s.queryAlerts("SELECT id, chat_id, query FROM alerts WHERE chat_id = ? ORDER BY id", chatID)
+}
+
+// AllAlerts returns the saved searches of every chat.
+func (s *UserStore) AllAlerts() ([]Alert, error) {
+	return s.queryAlerts("SELECT id, chat_id, query FROM alerts ORDER BY id")
+}
+
+func (s *UserStore) queryAlerts(query string, args ...any) ([]Alert, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read alerts: %v", err)
+	}
+	defer rows.Close()
+
+	var alerts []Alert
+	for rows.Next() {
+		var alert Alert
+		if err := rows.Scan(&alert.ID, &alert.ChatID, &alert.Query); err != nil {
+			return nil, fmt.Errorf("cannot read alert: %v", err)
+		}
+		if alert.Query, err = s.cipher.open(alert.Query); err != nil {
+			return nil, fmt.Errorf("cannot read alert: %v", err)
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts, rows.Err()
+}
+
+// Digest is a chat's opt-in summary of new and trending fatwas: how often
+// it is sent, at what hour, and when it was last sent.
+type Digest struct {
+	ChatID    int64
+	Frequency string
+	Hour      int
+	LastSent  time.Time
+}
+
+// SetDigest subscribes a chat to the digest, or changes its schedule.
+func (s *UserStore) SetDigest(chatID int64, frequency string, hour int) error {
+	_, err := s.db.Exec(`INSERT INTO digests (chat_id, frequency, hour, created_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(chat_id) DO UPDATE SET frequency = excluded.frequency, hour = excluded.hour`,
+		chatID, frequency, hour, storeTimestamp())
+	if err != nil {
+		return fmt.Errorf("cannot save digest for %d: %v", chatID, err)
+	}
+	return nil
+}
+
+func (s *UserStore) RemoveDigest(chatID int64) error {
+	_, err := s.db.Exec("DELETE FROM digests WHERE chat_id = ?", chatID)
+	if err != nil {
+		return fmt.Errorf("cannot remove digest for %d: %v", chatID, err)
+	}
+	return nil
+}
+
+// Digest returns the digest settings of a chat, if it has opted in.
+func (s *UserStore) Digest(chatID int64) (Digest, bool, error) {
+	digests, err := s.queryDigests("SELECT chat_id, frequency, hour, last_sent FROM digests WHERE chat_id = ?", chatID)
+	if err != nil || len(digests) == 0 {
+		return Digest{}, false, err
+	}
+	return digests[0], true, nil
+}
+
+// Digests returns the digest settings of every chat that has opted in.
+func (s *UserStore) Digests() ([]Digest, error) {
+	return s.queryDigests("SELECT chat_id, frequency, hour, last_sent FROM digests ORDER BY chat_id")
+}
+
+func (s *UserStore) queryDigests(query string, args ...any) ([]Digest, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read digests: %v", err)
+	}
+	defer rows.Close()
+
+	var digests []Digest
+	for rows.Next() {
+		var digest Digest
+		var lastSent string
+		if err := rows.Scan(&digest.ChatID, &digest.Frequency, &digest.Hour, &lastSent); err != nil {
+			return nil, fmt.Errorf("cannot read digest: %v", err)
+		}
+		if lastSent != "" {
+			if digest.LastSent, err = time.Parse(time.RFC3339, lastSent); err != nil {
+				return nil, fmt.Errorf("cannot read digest: %v", err)
+			}
+		}
+		digests = append(digests, digest)
+	}
+	return digests, rows.Err()
+}
+
+// MarkDigestSent records that a chat's digest was sent.
+func (s *UserStore) MarkDigestSent(chatID int64) error {
+	_, err := s.db.Exec("UPDATE digests SET last_sent = ? WHERE chat_id = ?", storeTimestamp(), chatID)
+	if err != nil {
+		return fmt.Errorf("cannot record digest for %d: %v", chatID, err)
+	}
+	return nil
+}
+
+// TopSearches returns the keyword queries searched by the most chats
+// since a time, most popular first. Encryption is deterministic, so equal
+// queries are counted together.
+func (s *UserStore) TopSearches(since time.Time, limit int) ([]string, error) {
+	rows, err := s.db.Query(`SELECT query FROM search_history
+		WHERE search_type = 'keyword' AND searched_at >= ?
+		GROUP BY query ORDER BY COUNT(DISTINCT chat_id) DESC, MAX(id) DESC LIMIT ?`,
+		since.UTC().Format(time.RFC3339), limit)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read top searches: %v", err)
+	}
+	defer rows.Close()
+
+	var queries []string
+	for rows.Next() {
+		var query string
+		if err := rows.Scan(&query); err != nil {
+			return nil, fmt.Errorf("cannot read top search: %v", err)
+		}
+		if query, err = s.cipher.open(query); err != nil {
+			return nil, fmt.Errorf("cannot read top search: %v", err)
+		}
+		queries = append(queries, query)
+	}
+	return queries, rows.Err()
+}
+
+// QueryCount is a query and how many times it was searched.
+type QueryCount struct {
+	Query string
+	Type  string // the search type, where it matters
+	Count int
+}
+
+// DayCount is a day (YYYY-MM-DD, UTC) and a count for it.
+type DayCount struct {
+	Day   string
+	Count int
+}
+
+// LogQuery records a search in the query log. Unlike the search history,
+// the log only identifies the chat that searched by its chatHash, so it
+// can be kept for usage reports. Queries are lowercased so different
+// capitalizations count as one.
+func (s *UserStore) LogQuery(chatID int64, query, searchType string, results int) error {
+	query = strings.ToLower(strings.Join(strings.Fields(query), " "))
+	_, err := s.db.Exec("INSERT INTO query_log (query, search_type, results, chat_hash, logged_at) VALUES (?, ?, ?, ?, ?)",
+		s.cipher.seal(query), searchType, results, s.chatHash(chatID), storeTimestamp())
+	if err != nil {
+		return fmt.Errorf("cannot log query: %v", err)
+	}
+	return nil
+}
+
+// ZeroResultQueries returns the queries that found nothing since a time,
+// most searched first: topics the dataset is missing, or spellings search
+// doesn't handle.
+func (s *UserStore) ZeroResultQueries(since time.Time, limit int) ([]QueryCount, error) {
+	rows, err := s.db.Query(`SELECT query, COUNT(*) FROM query_log
+		WHERE results = 0 AND logged_at >= ?
+		GROUP BY query ORDER BY COUNT(*) DESC, MAX(id) DESC LIMIT ?`,
+		since.UTC().Format(time.RFC3339), limit)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read zero-result queries: %v", err)
+	}
+	defer rows.Close()
+
+	var queries []QueryCount
+	for rows.Next() {
+		var query QueryCount
+		if err := rows.Scan(&query.Query, &query.Count); err != nil {
+			return nil, fmt.Errorf("cannot read zero-result query: %v", err)
+		}
+		if query.Query, err = s.cipher.open(query.Query); err != nil {
+			return nil, fmt.Errorf("cannot read zero-result query: %v", err)
+		}
+		queries = append(queries, query)
+	}
+	return queries, rows.Err()
+}
+
+// TrendingQueries returns the keyword and title queries that found
+// fatwas since a time, most searched first, with their search type. Only
+// queries searched by at least minChats chats are returned, so one user's
+// searches are never shown to others.
+func (s *UserStore) TrendingQueries(since time.Time, minChats, limit int) ([]QueryCount, error) {
+	rows, err := s.db.Query(`SELECT query, search_type, COUNT(*) FROM query_log
+		WHERE search_type IN ('keyword', 'title') AND results > 0 AND logged_at >= ? AND chat_hash != ''
+		GROUP BY query, search_type HAVING COUNT(DISTINCT chat_hash) >= ?
+		ORDER BY COUNT(*) DESC, MAX(id) DESC LIMIT ?`,
+		since.UTC().Format(time.RFC3339), minChats, limit)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read trending queries: %v", err)
+	}
+	defer rows.Close()
+
+	var queries []QueryCount
+	for rows.Next() {
+		var query QueryCount
+		if err := rows.Scan(&query.Query, &query.Type, &query.Count); err != nil {
+			return nil, fmt.Errorf("cannot read trending query: %v", err)
+		}
+		if query.Query, err = s.cipher.open(query.Query); err != nil {
+			return nil, fmt.Errorf("cannot read trending query: %v", err)
+		}
+		queries = append(queries, query)
+	}
+	return queries, rows.Err()
+}
+
+// DailyActiveUsers returns how many chats used the bot on each day since a
+// time, oldest first. Days without any are left out.
+func (s *UserStore) DailyActiveUsers(since time.Time) ([]DayCount, error) {
+	rows, err := s.db.Query("SELECT day, COUNT(*) FROM activity WHERE day >= ? GROUP BY day ORDER BY day",
+		since.UTC().Format(time.DateOnly))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read daily active users: %v", err)
+	}
+	defer rows.Close()
+
+	var days []DayCount
+	for rows.Next() {
+		var day DayCount
+		if err := rows.Scan(&day.Day, &day.Count); err != nil {
+			return nil, fmt.Errorf("cannot read daily active users: %v", err)
+		}
+		days = append(days, day)
+	}
+	return days, rows.Err()
+}
+
+// PruneAnalytics deletes query log entries, activity, feedback and topic
+// requests older than a time.
+func (s *UserStore) PruneAnalytics(before time.Time) error {
+	if _, err := s.db.Exec("DELETE FROM query_log WHERE logged_at < ?", before.UTC().Format(time.RFC3339)); err != nil {
+		return fmt.Errorf("cannot prune query log: %v", err)
+	}
+	if _, err := s.db.Exec("DELETE FROM activity WHERE day < ?", before.UTC().Format(time.DateOnly)); err != nil {
+		return fmt.Errorf("cannot prune activity: %v", err)
+	}
+	if _, err := s.db.Exec("DELETE FROM feedback WHERE voted_at < ?", before.UTC().Format(time.RFC3339)); err != nil {
+		return fmt.Errorf("cannot prune feedback: %v", err)
+	}
+	if _, err := s.db.Exec("DELETE FROM topic_requests WHERE requested_at < ?", before.UTC().Format(time.RFC3339)); err != nil {
+		return fmt.Errorf("cannot prune topic requests: %v", err)
+	}
+	return nil
+}
+
+// Conversation is the step a chat has reached in a multi-step flow such
+// as the /cari search wizard, with the answers given so far.
+type Conversation struct {
+	State      string
+	SearchType string
+	Category   string
+	UpdatedAt  time.Time
+}
+
+// SetConversation saves the step a chat has reached in a flow.
+func (s *UserStore) SetConversation(chatID int64, conversation Conversation) error {
+	_, err := s.db.Exec(`INSERT INTO conversations (chat_id, state, search_type, category, updated_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(chat_id) DO UPDATE SET state = excluded.state, search_type = excluded.search_type,
+			category = excluded.category, updated_at = excluded.updated_at`,
+		chatID, conversation.State, conversation.SearchType, s.cipher.seal(conversation.Category), storeTimestamp())
+	if err != nil {
+		return fmt.Errorf("cannot save conversation for %d: %v", chatID, err)
+	}
+	return nil
+}
+
+// Conversation returns the step a chat has reached in a flow, if it is in
+// one.
+func (s *UserStore) Conversation(chatID int64) (Conversation, bool, error) {
+	var conversation Conversation
+	var category, updatedAt string
+	err := s.db.QueryRow("SELECT state, search_type, category, updated_at FROM conversations WHERE chat_id = ?", chatID).
+		Scan(&conversation.State, &conversation.SearchType, &category, &updatedAt)
+	if err == sql.ErrNoRows {
+		return Conversation{}, false, nil
+	}
+	if err != nil {
+		return Conversation{}, false, fmt.Errorf("cannot read conversation for %d: %v", chatID, err)
+	}
+	if conversation.Category, err = s.cipher.open(category); err != nil {
+		return Conversation{}, false, err
+	}
+	if conversation.UpdatedAt, err = time.Parse(time.RFC3339, updatedAt); err != nil {
+		return Conversation{}, false, fmt.Errorf("cannot read conversation for %d: %v", chatID, err)
+	}
+	return conversation, true, nil
+}
+
+// EndConversation forgets the flow a chat was in.
+func (s *UserStore) EndConversation(chatID int64) error {
+	_, err := s.db.Exec("DELETE FROM conversations WHERE chat_id = ?", chatID)
+	if err != nil {
+		return fmt.Errorf("cannot end conversation for %d: %v", chatID, err)
+	}
+	return nil
+}
+
+// PruneConversations forgets the flows chats left before a time.
+func (s *UserStore) PruneConversations(before time.Time) (int64, error) {
+	result, err := s.db.Exec("DELETE FROM conversations WHERE updated_at < ?", before.UTC().Format(time.RFC3339))
+	if err != nil {
+		return 0, fmt.Errorf("cannot prune conversations: %v", err)
+	}
+	return result.RowsAffected()
+}
+
+// AddReport records that a chat reported a fatwa's link as broken, and
+// returns the report's ID.
+func (s *UserStore) AddReport(chatID int64, key FatwaKey) (int64, error) {
+	result, err := s.db.Exec("INSERT INTO reports (chat_id, source, fatwa_id, reported_at) VALUES (?, ?, ?, ?)",
+		chatID, key.Source, key.ID, storeTimestamp())
+	if err != nil {
+		return 0, fmt.Errorf("cannot save report: %v", err)
+	}
+	return result.LastInsertId()
+}
+
+// ReportedSince reports whether a fatwa has been reported since a time.
+func (s *UserStore) ReportedSince(key FatwaKey, since time.Time) (bool, error) {
+	var count int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM reports WHERE source = ? AND fatwa_id = ? AND reported_at >= ?",
+		key.Source, key.ID, since.UTC().Format(time.RFC3339)).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("cannot read reports: %v", err)
+	}
+	return count > 0, nil
+}
+
+// SetReportOutcome records what checking a reported fatwa found.
+func (s *UserStore) SetReportOutcome(id int64, outcome string) error {
+	_, err := s.db.Exec("UPDATE reports SET outcome = ? WHERE id = ?", outcome, id)
+	if err != nil {
+		return fmt.Errorf("cannot save report outcome: %v", err)
+	}
+	return nil
+}
+
+// Ban records that an admin banned a chat or user.
+func (s *UserStore) Ban(chatID, adminID int64) error {
+	_, err := s.db.Exec("INSERT OR REPLACE INTO bans (chat_id, banned_by, created_at) VALUES (?, ?, ?)",
+		chatID, adminID, storeTimestamp())
+	if err != nil {
+		return fmt.Errorf("cannot ban %d: %v", chatID, err)
+	}
+	return nil
+}
+
+func (s *UserStore) Unban(chatID int64) error {
+	_, err := s.db.Exec("DELETE FROM bans WHERE chat_id = ?", chatID)
+	if err != nil {
+		return fmt.Errorf("cannot unban %d: %v", chatID, err)
+	}
+	return nil
+}
+
+// Bans returns the banned chats and users, oldest ban first.
+func (s *UserStore) Bans() ([]int64, error) {
+	rows, err := s.db.Query("SELECT chat_id FROM bans ORDER BY created_at, chat_id")
+	if err != nil {
+		return nil, fmt.Errorf("cannot read bans: %v", err)
+	}
+	defer rows.Close()
+
+	var bans []int64
+	for rows.Next() {
+		var chatID int64
+		if err := rows.Scan(&chatID); err != nil {
+			return nil, fmt.Errorf("cannot read ban: %v", err)
+		}
+		bans = append(bans, chatID)
+	}
+	return bans, rows.Err()
+}
+
+// AddQuizAnswer records a user's answer to the quiz question made from a
+// fatwa. Only the first answer to each question counts; it reports false
+// for later ones.
+func (s *UserStore) AddQuizAnswer(userID int64, key FatwaKey, correct bool) (bool, error) {
+	result, err := s.db.Exec("INSERT OR IGNORE INTO quiz_answers (user_id, source, fatwa_id, correct, answered_at) VALUES (?, ?, ?, ?, ?)",
+		userID, key.Source, key.ID, correct, storeTimestamp())
+	if err != nil {
+		return false, fmt.Errorf("cannot save quiz answer for %d: %v", userID, err)
+	}
+	added, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("cannot save quiz answer for %d: %v", userID, err)
+	}
+	return added > 0, nil
+}
+
+// QuizScore returns how many quiz questions a user answered correctly, out
+// of how many answered.
+func (s *UserStore) QuizScore(userID int64) (correct, answered int, err error) {
+	err = s.db.QueryRow("SELECT COALESCE(SUM(correct), 0), COUNT(*) FROM quiz_answers WHERE user_id = ?", userID).Scan(&correct, &answered)
+	if err != nil {
+		return 0, 0, fmt.Errorf("cannot read quiz score for %d: %v", userID, err)
+	}
+	return correct, answered, nil
+}
+
+// SubscribeDailyFatwa opts a chat in to the fatwa of the day.
+func (s *UserStore) SubscribeDailyFatwa(chatID int64) error {
+	_, err := s.db.Exec("INSERT OR IGNORE INTO daily_fatwa_chats (chat_id, created_at) VALUES (?, ?)", chatID, storeTimestamp())
+	if err != nil {
+		return fmt.Errorf("cannot save fatwa of the day for %d: %v", chatID, err)
+	}
+	return nil
+}
+
+func (s *UserStore) UnsubscribeDailyFatwa(chatID int64) error {
+	_, err := s.db.Exec("DELETE FROM daily_fatwa_chats WHERE chat_id = ?", chatID)
+	if err != nil {
+		return fmt.Errorf("cannot remove fatwa of the day for %d: %v", chatID, err)
+	}
+	return nil
+}
+
+// DailyFatwaSubscribed reports whether a chat receives the fatwa of the day.
+func (s *UserStore) DailyFatwaSubscribed(chatID int64) (bool, error) {
+	var count int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM daily_fatwa_chats WHERE chat_id = ?", chatID).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("cannot read fatwa of the day for %d: %v", chatID, err)
+	}
+	return count > 0, nil
+}
+
+// DailyFatwaChats returns the chats that receive the fatwa of the day.
+func (s *UserStore) DailyFatwaChats() ([]int64, error) {
+	rows, err := s.db.Query("SELECT chat_id FROM daily_fatwa_chats ORDER BY chat_id")
+	if err != nil {
+		return nil, fmt.Errorf("cannot read fatwa of the day chats: %v", err)
+	}
+	defer rows.Close()
+
+	var chatIDs []int64
+	for rows.Next() {
+		var chatID int64
+		if err := rows.Scan(&chatID); err != nil {
+			return nil, fmt.Errorf("cannot read fatwa of the day chat: %v", err)
+		}
+		chatIDs = append(chatIDs, chatID)
+	}
+	return chatIDs, rows.Err()
+}
+
+// DailyFatwa returns the fatwa picked on a day ("2006-01-02"), if any.
+func (s *UserStore) DailyFatwa(day string) (FatwaKey, bool, error) {
+	var key FatwaKey
+	err := s.db.QueryRow("SELECT source, fatwa_id FROM daily_fatwas WHERE day = ?", day).Scan(&key.Source, &key.ID)
+	if err == sql.ErrNoRows {
+		return FatwaKey{}, false, nil
+	}
+	if err != nil {
+		return FatwaKey{}, false, fmt.Errorf("cannot read fatwa of the day: %v", err)
+	}
+	return key, true, nil
+}
+
+// SetDailyFatwa records the fatwa picked on a day, unless one already was.
+func (s *UserStore) SetDailyFatwa(day string, key FatwaKey) error {
+	_, err := s.db.Exec("INSERT OR IGNORE INTO daily_fatwas (day, source, fatwa_id) VALUES (?, ?, ?)", day, key.Source, key.ID)
+	if err != nil {
+		return fmt.Errorf("cannot save fatwa of the day: %v", err)
+	}
+	return nil
+}
+
+// DailyFatwasSince returns the fatwas picked on or after a day.
+func (s *UserStore) DailyFatwasSince(day string) (map[FatwaKey]bool, error) {
+	rows, err := s.db.Query("SELECT source, fatwa_id FROM daily_fatwas WHERE day >= ?", day)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read fatwas of the day: %v", err)
+	}
+	defer rows.Close()
+
+	picked := make(map[FatwaKey]bool)
+	for rows.Next() {
+		var key FatwaKey
+		if err := rows.Scan(&key.Source, &key.ID); err != nil {
+			return nil, fmt.Errorf("cannot read fatwa of the day: %v", err)
+		}
+		picked[key] = true
+	}
+	return picked, rows.Err()
+}
+
+// FeedbackCount is how many votes said a fatwa found by a query helped, and
+// how many said it didn't.
+type FeedbackCount struct {
+	Query     string
+	Key       FatwaKey
+	Helpful   int
+	Unhelpful int
+}
+
+// AddFeedback records whether a fatwa found by a query helped a chat. A
+// chat voting again on the same result replaces its vote. The query is
+// normalized like the query log's, and empty when the fatwa wasn't reached
+// from a search.
+func (s *UserStore) AddFeedback(chatID int64, key FatwaKey, query string, helpful bool) error {
+	query = strings.ToLower(strings.Join(strings.Fields(query), " "))
+	_, err := s.db.Exec(`INSERT INTO feedback (chat_id, source, fatwa_id, query, helpful, voted_at) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(chat_id, source, fatwa_id, query) DO UPDATE SET helpful = excluded.helpful, voted_at = excluded.voted_at`,
+		chatID, key.Source, key.ID, s.cipher.seal(query), helpful, storeTimestamp())
+	if err != nil {
+		return fmt.Errorf("cannot save feedback for %d: %v", chatID, err)
+	}
+	return nil
+}
+
+// UnhelpfulResults returns the query and fatwa pairs voted unhelpful since
+// a time, most unhelpful votes first, with their votes either way.
+func (s *UserStore) UnhelpfulResults(since time.Time, limit int) ([]FeedbackCount, error) {
+	rows, err := s.db.Query(`SELECT query, source, fatwa_id, SUM(helpful), SUM(1 - helpful) FROM feedback
+		WHERE voted_at >= ?
+		GROUP BY query, source, fatwa_id HAVING SUM(1 - helpful) > 0
+		ORDER BY SUM(1 - helpful) DESC, SUM(helpful) ASC, MAX(voted_at) DESC LIMIT ?`,
+		since.UTC().Format(time.RFC3339), limit)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read feedback: %v", err)
+	}
+	defer rows.Close()
+
+	var counts []FeedbackCount
+	for rows.Next() {
+		var count FeedbackCount
+		if err := rows.Scan(&count.Query, &count.Key.Source, &count.Key.ID, &count.Helpful, &count.Unhelpful); err != nil {
+			return nil, fmt.Errorf("cannot read feedback: %v", err)
+		}
+		if count.Query, err = s.cipher.open(count.Query); err != nil {
+			return nil, fmt.Errorf("cannot read feedback: %v", err)
+		}
+		counts = append(counts, count)
+	}
+	return counts, rows.Err()
+}
+
+// AddTopicRequest records that a chat asked for a topic its search found
+// nothing on. Asking again for the same topic counts once. The query is
+// normalized like the query log's.
+func (s *UserStore) AddTopicRequest(chatID int64, query string) error {
+	query = strings.ToLower(strings.Join(strings.Fields(query), " "))
+	_, err := s.db.Exec("INSERT OR IGNORE INTO topic_requests (chat_id, query, requested_at) VALUES (?, ?, ?)",
+		chatID, s.cipher.seal(query), storeTimestamp())
+	if err != nil {
+		return fmt.Errorf("cannot save topic request for %d: %v", chatID, err)
+	}
+	return nil
+}
+
+// TopicRequests returns the topics requested since a time, with how many
+// chats asked for each, most requested first.
+func (s *UserStore) TopicRequests(since time.Time, limit int) ([]QueryCount, error) {
+	rows, err := s.db.Query(`SELECT query, COUNT(*) FROM topic_requests
+		WHERE requested_at >= ?
+		GROUP BY query ORDER BY COUNT(*) DESC, MAX(requested_at) DESC LIMIT ?`,
+		since.UTC().Format(time.RFC3339), limit)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read topic requests: %v", err)
+	}
+	defer rows.Close()
+
+	var topics []QueryCount
+	for rows.Next() {
+		var topic QueryCount
+		if err := rows.Scan(&topic.Query, &topic.Count); err != nil {
+			return nil, fmt.Errorf("cannot read topic request: %v", err)
+		}
+		if topic.Query, err = s.cipher.open(topic.Query); err != nil {
+			return nil, fmt.Errorf("cannot read topic request: %v", err)
+		}
+		topics = append(topics, topic)
+	}
+	return topics, rows.Err()
+}
+
+// Summary returns the saved summary of a fatwa in a language, if there is
+// one for its content as identified by checksum.
+func (s *UserStore) Summary(key FatwaKey, lang, checksum string) (string, bool, error) {
+	var summary string
+	err := s.db.QueryRow("SELECT summary FROM summaries WHERE source = ? AND fatwa_id = ? AND language = ? AND checksum = ?",
+		key.Source, key.ID, lang, checksum).Scan(&summary)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("cannot read summary of %s: %v", key, err)
+	}
+	return summary, true, nil
+}
+
+// SetSummary saves the summary of a fatwa's content in a language,
+// replacing any of its earlier content.
+func (s *UserStore) SetSummary(key FatwaKey, lang, checksum, summary string) error {
+	_, err := s.db.Exec(`INSERT OR REPLACE INTO summaries (source, fatwa_id, language, checksum, summary, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`, key.Source, key.ID, lang, checksum, summary, storeTimestamp())
+	if err != nil {
+		return fmt.Errorf("cannot save summary of %s: %v", key, err)
+	}
+	return nil
+}
+
+// Translation is a fatwa's machine translation into English. Truncated
+// reports that only its first translateMaxParts chunks were translated.
+type Translation struct {
+	Title     string
+	Content   string
+	Truncated bool
+}
+
+// Translation returns the saved translation of a fatwa, if there is one
+// for its content as identified by checksum.
+func (s *UserStore) Translation(key FatwaKey, checksum string) (Translation, bool, error) {
+	var translation Translation
+	err := s.db.QueryRow("SELECT title, content, truncated FROM translations WHERE source = ? AND fatwa_id = ? AND checksum = ?",
+		key.Source, key.ID, checksum).Scan(&translation.Title, &translation.Content, &translation.Truncated)
+	if err == sql.ErrNoRows {
+		return Translation{}, false, nil
+	}
+	if err != nil {
+		return Translation{}, false, fmt.Errorf("cannot read translation of %s: %v", key, err)
+	}
+	return translation, true, nil
+}
+
+// SetTranslation saves the translation of a fatwa's content, replacing any
+// of its earlier content.
+func (s *UserStore) SetTranslation(key FatwaKey, checksum string, translation Translation) error {
+	_, err := s.db.Exec(`INSERT OR REPLACE INTO translations (source, fatwa_id, checksum, title, content, truncated, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`, key.Source, key.ID, checksum, translation.Title, translation.Content, translation.Truncated, storeTimestamp())
+	if err != nil {
+		return fmt.Errorf("cannot save translation of %s: %v", key, err)
+	}
+	return nil
+}
+
+// VoiceNotes returns the file IDs of the voice notes already sent reading a
+// fatwa aloud, by part, for the text read as identified by checksum.
+func (s *UserStore) VoiceNotes(key FatwaKey, checksum string) (map[int]string, error) {
+	rows, err := s.db.Query("SELECT part, file_id FROM voice_notes WHERE source = ? AND fatwa_id = ? AND checksum = ?",
+		key.Source, key.ID, checksum)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read voice notes of %s: %v", key, err)
+	}
+	defer rows.Close()
+
+	notes := make(map[int]string)
+	for rows.Next() {
+		var part int
+		var fileID string
+		if err := rows.Scan(&part, &fileID); err != nil {
+			return nil, fmt.Errorf("cannot read voice note: %v", err)
+		}
+		notes[part] = fileID
+	}
+	return notes, rows.Err()
+}
+
+// SetVoiceNote saves the file ID of a part of a fatwa read aloud, replacing
+// the part as read from any earlier text.
+func (s *UserStore) SetVoiceNote(key FatwaKey, part int, checksum, fileID string) error {
+	_, err := s.db.Exec(`INSERT OR REPLACE INTO voice_notes (source, fatwa_id, part, checksum, file_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`, key.Source, key.ID, part, checksum, fileID, storeTimestamp())
+	if err != nil {
+		return fmt.Errorf("cannot save voice note of %s: %v", key, err)
+	}
+	return nil
+}
+
+// BotText returns the text admins set in place of a default, if they did.
+func (s *UserStore) BotText(name string) (string, bool, error) {
+	var text string
+	err := s.db.QueryRow("SELECT text FROM bot_texts WHERE name = ?", name).Scan(&text)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("cannot read %s text: %v", name, err)
+	}
+	return text, true, nil
+}
+
+// SetBotText replaces a default text with one set by an admin.
+func (s *UserStore) SetBotText(name, text string) error {
+	_, err := s.db.Exec(`INSERT INTO bot_texts (name, text, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET text = excluded.text, updated_at = excluded.updated_at`,
+		name, text, storeTimestamp())
+	if err != nil {
+		return fmt.Errorf("cannot save %s text: %v", name, err)
+	}
+	return nil
+}
+
+// DeleteBotText goes back to the default text.
+func (s *UserStore) DeleteBotText(name string) error {
+	if _, err := s.db.Exec("DELETE FROM bot_texts WHERE name = ?", name); err != nil {
+		return fmt.Errorf("cannot delete %s text: %v", name, err)
+	}
+	return nil
+}
+
+// ClaimNotifications records that users are being told about fatwas, and
+// returns those they hadn't been told about before, so each fatwa is only
+// announced once however often the dataset is reloaded.
+func (s *UserStore) ClaimNotifications(keys []FatwaKey) ([]FatwaKey, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("cannot record notified fatwas: %v", err)
+	}
+	defer tx.Rollback()
+
+	var claimed []FatwaKey
+	for _, key := range keys {
+		result, err := tx.Exec("INSERT OR IGNORE INTO notified_fatwas (source, fatwa_id, notified_at) VALUES (?, ?, ?)",
+			key.Source, key.ID, storeTimestamp())
+		if err != nil {
+			return nil, fmt.Errorf("cannot record notified fatwa %s: %v", key, err)
+		}
+		if n, err := result.RowsAffected(); err == nil && n > 0 {
+			claimed = append(claimed, key)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("cannot record notified fatwas: %v", err)
+	}
+	return claimed, nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// sttMaxDuration is the longest voice message transcribed, in seconds.
+	// A question rarely takes longer, and transcription is paid per minute.
+	sttMaxDuration = 60
+
+	// sttMaxSize is the largest voice file downloaded, well above what
+	// sttMaxDuration of Opus speech takes.
+	sttMaxSize = 2 << 20
+)
+
+// sttConfig holds the settings for transcribing voice questions with the
+// OpenAI Whisper API, or a local Whisper server offering the same API.
+type sttConfig struct {
+	APIKey   string
+	Model    string
+	Endpoint string
+}
+
+// loadSTTConfig reads the speech-to-text settings from the environment.
+// Voice search is disabled unless STT_API_KEY or STT_ENDPOINT is set; a
+// local server may need no key.
+func loadSTTConfig() (*sttConfig, bool) {
+	cfg := &sttConfig{
+		APIKey:   os.Getenv("STT_API_KEY"),
+		Model:    os.Getenv("STT_MODEL"),
+		Endpoint: os.Getenv("STT_ENDPOINT"),
+	}
+
+	if cfg.APIKey == "" && cfg.Endpoint == "" {
+		return nil, false
+	}
+	if cfg.Model == "" {
+		cfg.Model = "whisper-1"
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "https://api.openai.com/v1/audio/transcriptions"
+	}
+
+	return cfg, true
+}
+
+// transcribe returns the text spoken in an Ogg Opus voice message,
+// expected to be in Malay.
+func (cfg *sttConfig) transcribe(audio []byte) (string, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	file, err := form.CreateFormFile("file", "voice.ogg")
+	if err != nil {
+		return "", fmt.Errorf("cannot encode transcription request: %v", err)
+	}
+	file.Write(audio)
+	form.WriteField("model", cfg.Model)
+	form.WriteField("language", "ms")
+	form.WriteField("response_format", "json")
+	if err := form.Close(); err != nil {
+		return "", fmt.Errorf("cannot encode transcription request: %v", err)
+	}
+
+	req, err := http.NewRequest("POST", cfg.Endpoint, &body)
+	if err != nil {
+		return "", fmt.Errorf("cannot create transcription request: %v", err)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	if cfg.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+cfg.APIKey)
+	}
+
+	client := &http.Client{Timeout: 60 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("cannot request transcription: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("transcription request failed: %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+
+	var result struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("cannot decode transcription response: %v", err)
+	}
+	return strings.TrimSpace(result.Text), nil
+}
+
+// downloadFile fetches a file sent to the bot, refusing files larger than
+// maxSize.
+func (fb *FatwaBot) downloadFile(fileID string, maxSize int64) ([]byte, error) {
+	url, err := fb.bot.GetFileDirectURL(fileID)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get file: %v", err)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("cannot download file: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cannot download file: %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("cannot download file: %v", err)
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("file larger than %d bytes", maxSize)
+	}
+	return data, nil
+}
+
+// searchByVoice transcribes a spoken question and runs it as a keyword
+// search, for users who would rather ask than type. The transcript is
+// shown first, so a misheard question is easy to spot and type instead.
+func (fb *FatwaBot) searchByVoice(chatID int64, voice *tgbotapi.Voice) {
+	if fb.stt == nil {
+		fb.reply(chatID, "stt.disabled")
+		return
+	}
+	if voice.Duration > sttMaxDuration {
+		fb.reply(chatID, "stt.too_long", sttMaxDuration)
+		return
+	}
+
+	fb.sendTyping(chatID)
+
+	audio, err := fb.downloadFile(voice.FileID, sttMaxSize)
+	if err != nil {
+		log.Printf("Error downloading voice message: %v", err)
+		fb.reply(chatID, "stt.error")
+		return
+	}
+
+	transcript, err := fb.stt.transcribe(audio)
+	if err != nil {
+		log.Printf("Error transcribing voice message: %v", err)
+		fb.reply(chatID, "stt.error")
+		return
+	}
+	if transcript == "" {
+		fb.reply(chatID, "stt.empty")
+		return
+	}
+
+	fb.reply(chatID, "stt.heard", transcript)
+	fb.searchFatwas(chatID, transcript, "keyword")
+}
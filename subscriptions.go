@@ -0,0 +1,256 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"sort"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// datasetCategories returns the distinct categories of the dataset with
+// the number of fatwas in each, sorted by name.
+func (fb *FatwaBot) datasetCategories() ([]string, map[string]int) {
+	counts := make(map[string]int)
+	for _, fatwa := range fb.dataset() {
+		if fatwa.Category != "" {
+			counts[fatwa.Category]++
+		}
+	}
+
+	categories := make([]string, 0, len(counts))
+	for category := range counts {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	return categories, counts
+}
+
+// findCategory resolves a category typed by a user to its name in the
+// dataset: an exact match ignoring case, or else the only category
+// containing it.
+func (fb *FatwaBot) findCategory(name string) (string, bool) {
+	name = normalizeSearchText(strings.TrimSpace(name))
+	categories, _ := fb.datasetCategories()
+
+	var partial []string
+	for _, category := range categories {
+		normalized := normalizeSearchText(category)
+		if normalized == name {
+			return category, true
+		}
+		if strings.Contains(normalized, name) {
+			partial = append(partial, category)
+		}
+	}
+
+	if len(partial) == 1 {
+		return partial[0], true
+	}
+	return "", false
+}
+
+// categoryButtons returns a row per category with a button sending
+// callback with the category's hash, labelled with the number of fatwas in
+// it when counts is given. Category names can be longer than callback data,
+// which is limited to 64 bytes, so the hash is resolved with
+// categoryByHash when the button is tapped.
+func categoryButtons(categories []string, counts map[string]int, label string, callback callbackData) [][]tgbotapi.InlineKeyboardButton {
+	var keyboard [][]tgbotapi.InlineKeyboardButton
+	for _, category := range categories {
+		text := label + " " + category
+		if counts != nil {
+			text += fmt.Sprintf(" (%d)", counts[category])
+		}
+		callback.Hash = textHash(category)
+		keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(text, callback.String()),
+		))
+	}
+	return keyboard
+}
+
+// categoryByHash finds the category of categories a button was sent for
+// by its hash, see categoryButtons.
+func categoryByHash(categories []string, hash string) (string, bool) {
+	for _, category := range categories {
+		if textHash(category) == hash {
+			return category, true
+		}
+	}
+	return "", false
+}
+
+// callbackCategory resolves the category a button was sent for among
+// categories, or reports that it is gone. Buttons from before categories
+// were hashed carry the name itself.
+func (fb *FatwaBot) callbackCategory(chatID int64, data callbackData, categories []string) (string, bool) {
+	if data.Hash == "" {
+		return data.Arg, true
+	}
+	category, ok := categoryByHash(categories, data.Hash)
+	if !ok {
+		fb.reply(chatID, "categories.expired")
+	}
+	return category, ok
+}
+
+// subscribe subscribes a chat to new fatwas in a category. Without a
+// category it lists the chat's subscriptions and offers the others.
+func (fb *FatwaBot) subscribe(chatID int64, name string) {
+	if strings.TrimSpace(name) == "" {
+		fb.showSubscriptions(chatID)
+		return
+	}
+
+	category, ok := fb.findCategory(name)
+	if !ok {
+		fb.reply(chatID, "subscriptions.not_found", strings.TrimSpace(name))
+		return
+	}
+
+	if err := fb.store.Subscribe(chatID, category); err != nil {
+		log.Printf("Error saving subscription: %v", err)
+		fb.reply(chatID, "subscriptions.save_error")
+		return
+	}
+
+	fb.reply(chatID, "subscriptions.subscribed", category, category)
+}
+
+// unsubscribe stops notifications for a category. Without a category it
+// offers a button for each subscription.
+func (fb *FatwaBot) unsubscribe(chatID int64, name string) {
+	name = strings.TrimSpace(name)
+
+	subscriptions, err := fb.store.Subscriptions(chatID)
+	if err != nil {
+		log.Printf("Error reading subscriptions: %v", err)
+		fb.reply(chatID, "subscriptions.read_error")
+		return
+	}
+	if len(subscriptions) == 0 {
+		fb.reply(chatID, "subscriptions.none")
+		return
+	}
+
+	if name == "" {
+		msg := tgbotapi.NewMessage(chatID, fb.t(chatID, "subscriptions.pick"))
+		msg.ParseMode = tgbotapi.ModeHTML
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(categoryButtons(subscriptions, nil, "🔕", callbackData{Action: "unsub"})...)
+		fb.send(msg)
+		return
+	}
+
+	// Subscriptions may be to categories that have since disappeared, so
+	// they are matched directly rather than through the dataset
+	category := ""
+	for _, subscription := range subscriptions {
+		if normalizeSearchText(subscription) == normalizeSearchText(name) {
+			category = subscription
+		}
+	}
+	if category == "" {
+		fb.reply(chatID, "subscriptions.not_subscribed", name)
+		return
+	}
+
+	if err := fb.store.Unsubscribe(chatID, category); err != nil {
+		log.Printf("Error removing subscription: %v", err)
+		fb.reply(chatID, "subscriptions.remove_error")
+		return
+	}
+
+	fb.reply(chatID, "subscriptions.unsubscribed", category)
+}
+
+// showSubscriptions lists a chat's subscriptions, with buttons to
+// subscribe to the remaining categories.
+func (fb *FatwaBot) showSubscriptions(chatID int64) {
+	subscriptions, err := fb.store.Subscriptions(chatID)
+	if err != nil {
+		log.Printf("Error reading subscriptions: %v", err)
+		fb.reply(chatID, "subscriptions.read_error")
+		return
+	}
+
+	subscribed := make(map[string]bool, len(subscriptions))
+	message := fb.t(chatID, "subscriptions.title") + "\n\n"
+	if len(subscriptions) == 0 {
+		message += fb.t(chatID, "subscriptions.empty") + "\n"
+	}
+	for _, category := range subscriptions {
+		subscribed[category] = true
+		message += fmt.Sprintf("• %s\n", html.EscapeString(category))
+	}
+
+	categories, _ := fb.datasetCategories()
+	var others []string
+	for _, category := range categories {
+		if !subscribed[category] {
+			others = append(others, category)
+		}
+	}
+
+	msg := tgbotapi.NewMessage(chatID, message+"\n"+fb.t(chatID, "subscriptions.hint"))
+	msg.ParseMode = tgbotapi.ModeHTML
+	if keyboard := categoryButtons(others, nil, "🔔", callbackData{Action: "sub"}); len(keyboard) > 0 {
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(keyboard...)
+	}
+	fb.send(msg)
+}
+
+// notifySubscribers tells the subscribers of each category about the
+// fatwas just added to it.
+func (fb *FatwaBot) notifySubscribers(added map[FatwaKey]bool) {
+	if len(added) == 0 {
+		return
+	}
+
+	byCategory := make(map[string][]Fatwa)
+	for _, fatwa := range fb.dataset() {
+		if added[fatwa.Key()] {
+			byCategory[fatwa.Category] = append(byCategory[fatwa.Category], fatwa)
+		}
+	}
+
+	for category, fatwas := range byCategory {
+		subscribers, err := fb.store.Subscribers(category)
+		if err != nil {
+			log.Printf("Error reading subscribers: %v", err)
+			continue
+		}
+		if len(subscribers) == 0 {
+			continue
+		}
+
+		for _, chatID := range subscribers {
+			message, keyboard := subscriptionUpdate(category, fatwas, fb.language(chatID))
+			msg := tgbotapi.NewMessage(chatID, message)
+			msg.ParseMode = tgbotapi.ModeHTML
+			msg.ReplyMarkup = keyboard
+			if _, err := fb.trySendBulk(msg); err != nil {
+				log.Printf("Error sending subscription update to %d: %v", chatID, err)
+			}
+		}
+	}
+}
+
+// subscriptionUpdate lists the fatwas just added to a category, with a
+// button to read each.
+func subscriptionUpdate(category string, fatwas []Fatwa, lang string) (string, tgbotapi.InlineKeyboardMarkup) {
+	message := tr(lang, "subscriptions.new", category) + "\n\n"
+	var keyboard [][]tgbotapi.InlineKeyboardButton
+	for i, fatwa := range fatwas[:min(len(fatwas), maxAlertResults)] {
+		message += fmt.Sprintf("<b>%d. %s</b>\n📅 %s\n\n", i+1, html.EscapeString(fatwa.Title), html.EscapeString(fatwa.Date))
+		keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(tr(lang, "button.read_fatwa", i+1), fatwaCallback("view", fatwa.Key())),
+		))
+	}
+	if len(fatwas) > maxAlertResults {
+		message += tr(lang, "subscriptions.more", len(fatwas)-maxAlertResults, category) + "\n"
+	}
+	return message, tgbotapi.NewInlineKeyboardMarkup(keyboard...)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// maxSuggestions is the number of "did you mean" buttons offered.
+const maxSuggestions = 3
+
+// suggestQueries proposes corrected spellings of a query that found
+// nothing, by replacing words missing from the corpus with close words
+// that are in it. The search itself already tolerated small typos, so one
+// more edit is allowed here. Common words are preferred among equally
+// close candidates.
+func suggestQueries(index *searchIndex, query string) []string {
+	words := tokenize(normalizeSearchText(query))
+	if len(words) == 0 {
+		return nil
+	}
+
+	options := make([][]string, len(words))
+	corrected := false
+	for i, word := range words {
+		if index.WordDocs[word] != nil || malayStopwords[word] {
+			options[i] = []string{word}
+			continue
+		}
+
+		candidates := closeWords(index, word, allowedEdits(word)+1)
+		if len(candidates) == 0 {
+			return nil
+		}
+		options[i] = candidates
+		corrected = true
+	}
+	if !corrected {
+		return nil
+	}
+
+	// Vary one misspelled word at a time, keeping the others at their
+	// best candidate
+	best := make([]string, len(words))
+	for i := range options {
+		best[i] = options[i][0]
+	}
+
+	suggestions := []string{strings.Join(best, " ")}
+	for i := range options {
+		for _, alternative := range options[i][1:] {
+			if len(suggestions) == maxSuggestions {
+				return suggestions
+			}
+			variant := append([]string(nil), best...)
+			variant[i] = alternative
+			suggestions = append(suggestions, strings.Join(variant, " "))
+		}
+	}
+	return suggestions
+}
+
+// closeWords returns the corpus words within maxEdits of word, closest and
+// then most common first.
+func closeWords(index *searchIndex, word string, maxEdits int) []string {
+	type candidate struct {
+		word     string
+		distance int
+		docs     int
+	}
+
+	var candidates []candidate
+	for _, other := range index.Vocabulary {
+		if distance := editDistance(word, other, maxEdits); distance <= maxEdits {
+			candidates = append(candidates, candidate{other, distance, len(index.WordDocs[other])})
+		}
+	}
+
+	sort.Slice(candidates, func(a, b int) bool {
+		if candidates[a].distance != candidates[b].distance {
+			return candidates[a].distance < candidates[b].distance
+		}
+		if candidates[a].docs != candidates[b].docs {
+			return candidates[a].docs > candidates[b].docs
+		}
+		return candidates[a].word < candidates[b].word
+	})
+
+	words := make([]string, 0, maxSuggestions)
+	for _, c := range candidates[:min(len(candidates), maxSuggestions)] {
+		words = append(words, c.word)
+	}
+	return words
+}
+
+// sendNoResults reports a search that found nothing, with buttons for any
+// suggested spellings and, for keyword and title searches, to request the
+// topic.
+func (fb *FatwaBot) sendNoResults(chatID int64, query, searchType string, index *searchIndex) {
+	var suggestions []string
+	if searchType == "keyword" || searchType == "title" {
+		suggestions = suggestQueries(index, query)
+	}
+
+	var keyboard [][]tgbotapi.InlineKeyboardButton
+	for _, suggestion := range suggestions {
+		keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔎 "+suggestion, fb.textCallback("suggest", searchType, suggestion)),
+		))
+	}
+
+	message := fb.t(chatID, "search.none", query)
+	if len(keyboard) > 0 {
+		message = fb.t(chatID, "search.suggest", query, suggestions[0])
+	}
+	if searchType == "keyword" || searchType == "title" {
+		keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(fb.topicButton(query, fb.language(chatID))))
+		message += "\n\n" + fb.t(chatID, "search.topic_hint")
+	}
+
+	if len(keyboard) == 0 {
+		fb.sendMessage(chatID, message)
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, message)
+	msg.ParseMode = tgbotapi.ModeHTML
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(keyboard...)
+	fb.send(msg)
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"log"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// summaryMaxInput is how much of a fatwa's content is summarized, in
+	// characters, to bound the cost of the longest fatwas. Rulings are
+	// usually stated well before the end.
+	summaryMaxInput = 12000
+
+	// summaryPrompt instructs the chat model, given who issued the fatwa,
+	// the language to write in and the label that starts the line with the
+	// ruling.
+	summaryPrompt = "Summarise the following fatwa by %s " +
+		"in 3 to 5 sentences, in %s. Then, on a last line starting with %q, state its ruling " +
+		"in one sentence. Reply with the summary and the ruling only, in plain text."
+)
+
+// sourceNames names the sources of fatwas for the chat model. Fatwas
+// imported from other sources are named by their source as imported.
+var sourceNames = map[string]string{
+	defaultSource: "the Federal Territories Mufti's Office",
+}
+
+// sourceName names the issuer of fatwas from a source for the chat model.
+func sourceName(source string) string {
+	if name, ok := sourceNames[source]; ok {
+		return name
+	}
+	return source
+}
+
+// summaryLanguages names the interface languages for the chat model.
+// Summaries for other languages are written in Malay.
+var summaryLanguages = map[string]string{
+	"ms": "Malay",
+	"en": "English",
+}
+
+// summaryButton is the "🧠 Ringkasan" button under fatwa details.
+func summaryButton(key FatwaKey, lang string) tgbotapi.InlineKeyboardButton {
+	return tgbotapi.NewInlineKeyboardButtonData(tr(lang, "button.summary"), fatwaCallback("summary", key))
+}
+
+// contentChecksum identifies a version of a fatwa's content, so a summary
+// is made again when the content changes.
+func contentChecksum(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:16])
+}
+
+// sendSummary sends a short summary of a fatwa and its ruling, given its ID
+// as shown in its details. Summaries are saved per fatwa and language, so
+// each is only requested from the chat model once; new ones take a while,
+// so they are made in the background, and chats asking for one already
+// being made wait for it.
+func (fb *FatwaBot) sendSummary(chatID int64, idStr string) {
+	if fb.llm == nil {
+		fb.reply(chatID, "summary.disabled")
+		return
+	}
+
+	key, err := parseFatwaKey(strings.TrimSpace(idStr))
+	if err != nil {
+		fb.reply(chatID, "id.invalid")
+		return
+	}
+	fatwa, ok := fb.findFatwa(key)
+	if !ok {
+		fb.reply(chatID, "id.not_found", key)
+		return
+	}
+
+	lang := fb.language(chatID)
+	checksum := contentChecksum(fatwa.Content)
+	summary, ok, err := fb.store.Summary(key, lang, checksum)
+	if err != nil {
+		log.Printf("Error reading summary: %v", err)
+	}
+	if ok {
+		fb.sendMessage(chatID, summaryMessage(fatwa, summary, lang))
+		return
+	}
+
+	fb.sendTyping(chatID)
+	request := key.String() + "/" + lang
+	if !fb.summarizing.join(request, chatID) {
+		return
+	}
+	fb.goSafe(chatID, func() {
+		// Forget the request even if making it panics
+		defer fb.summarizing.done(request)
+
+		summary, err := fb.summarize(fatwa, lang)
+		chats := fb.summarizing.done(request)
+		if err != nil {
+			log.Printf("Error summarizing fatwa %s: %v", key, err)
+			for _, chatID := range chats {
+				fb.reply(chatID, "summary.error")
+			}
+			return
+		}
+		if err := fb.store.SetSummary(key, lang, checksum, summary); err != nil {
+			log.Printf("Error saving summary: %v", err)
+		}
+		for _, chatID := range chats {
+			fb.sendMessage(chatID, summaryMessage(fatwa, summary, lang))
+		}
+	})
+}
+
+// summarize asks the chat model for a summary of a fatwa in a language.
+func (fb *FatwaBot) summarize(fatwa Fatwa, lang string) (string, error) {
+	language, ok := summaryLanguages[lang]
+	if !ok {
+		language, lang = summaryLanguages[langMalay], langMalay
+	}
+
+	content := strings.TrimSpace(fatwa.Content)
+	if runes := []rune(content); len(runes) > summaryMaxInput {
+		content = string(runes[:summaryMaxInput])
+	}
+	if content == "" {
+		return "", fmt.Errorf("fatwa has no content")
+	}
+
+	prompt := fmt.Sprintf(summaryPrompt, sourceName(fatwa.Source), language, tr(lang, "summary.ruling"))
+	return fb.llm.complete(prompt, fatwa.Title+"\n\n"+content)
+}
+
+// summaryMessage formats a summary under the fatwa's title, with its
+// ruling line in bold and a note that it was written by a machine.
+func summaryMessage(fatwa Fatwa, summary, lang string) string {
+	label := tr(lang, "summary.ruling")
+	lines := strings.Split(strings.TrimSpace(summary), "\n")
+	for i, line := range lines {
+		if ruling, ok := strings.CutPrefix(strings.TrimSpace(line), label); ok {
+			lines[i] = fmt.Sprintf("⚖️ <b>%s</b>%s", html.EscapeString(label), html.EscapeString(ruling))
+		} else {
+			lines[i] = html.EscapeString(line)
+		}
+	}
+
+	message := tr(lang, "summary.title", fatwa.Title) + "\n\n"
+	message += strings.Join(lines, "\n") + "\n\n"
+	message += tr(lang, "summary.disclaimer")
+	return message
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// supportTextName names the /sokong message among the texts admins
+	// can replace.
+	supportTextName = "support"
+
+	// supportPayload starts the payload of a donation invoice, followed by
+	// its amount in Stars.
+	supportPayload = "sokong_"
+
+	// starsCurrency is Telegram Stars, which need no payment provider.
+	starsCurrency = "XTR"
+
+	// supportStarsPerRow is how many Stars amounts are offered per row of
+	// buttons.
+	supportStarsPerRow = 3
+)
+
+// supportConfig holds the ways users can fund the bot's hosting: amounts
+// of Telegram Stars paid in the chat, and links to donation pages.
+type supportConfig struct {
+	Stars []int
+	Links []supportLink
+}
+
+type supportLink struct {
+	Label string
+	URL   string
+}
+
+// loadSupportConfig reads the donation options from the environment:
+// SUPPORT_STARS as comma-separated amounts (e.g. "50,100,500") and
+// SUPPORT_LINKS as comma-separated "label=URL" pairs (e.g.
+// "Ko-fi=https://ko-fi.com/example"). /sokong is disabled unless one of
+// them is set.
+func loadSupportConfig() (*supportConfig, bool) {
+	cfg := &supportConfig{}
+
+	for _, part := range strings.Split(os.Getenv("SUPPORT_STARS"), ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		stars, err := strconv.Atoi(part)
+		if err != nil || stars <= 0 {
+			log.Printf("Ignoring invalid Stars amount %q", part)
+			continue
+		}
+		cfg.Stars = append(cfg.Stars, stars)
+	}
+
+	for _, part := range strings.Split(os.Getenv("SUPPORT_LINKS"), ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		label, url, found := strings.Cut(part, "=")
+		label, url = strings.TrimSpace(label), strings.TrimSpace(url)
+		if !found || label == "" || !(strings.HasPrefix(url, "https://") || strings.HasPrefix(url, "http://")) {
+			log.Printf("Ignoring invalid support link %q", part)
+			continue
+		}
+		cfg.Links = append(cfg.Links, supportLink{Label: label, URL: url})
+	}
+
+	if len(cfg.Stars) == 0 && len(cfg.Links) == 0 {
+		return nil, false
+	}
+	return cfg, true
+}
+
+// showSupport sends the /sokong message with a button for each donation
+// option. Admins may replace the message with /supporttext; their text is
+// sent as plain text, like announcements, so stray markup can't break it.
+func (fb *FatwaBot) showSupport(chatID int64) {
+	if fb.support == nil {
+		fb.reply(chatID, "support.disabled")
+		return
+	}
+
+	lang := fb.language(chatID)
+	msg := tgbotapi.NewMessage(chatID, tr(lang, "support.text"))
+	msg.ParseMode = tgbotapi.ModeHTML
+	text, ok, err := fb.store.BotText(supportTextName)
+	if err != nil {
+		log.Printf("Error reading support text: %v", err)
+	}
+	if ok {
+		msg = tgbotapi.NewMessage(chatID, text)
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for chunk := range slices.Chunk(fb.support.Stars, supportStarsPerRow) {
+		var row []tgbotapi.InlineKeyboardButton
+		for _, stars := range chunk {
+			row = append(row, tgbotapi.NewInlineKeyboardButtonData(
+				tr(lang, "button.support_stars", stars),
+				callbackData{Action: "sokong", Arg: strconv.Itoa(stars)}.String(),
+			))
+		}
+		rows = append(rows, row)
+	}
+	for _, link := range fb.support.Links {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonURL("💝 "+link.Label, link.URL)))
+	}
+	if len(rows) > 0 {
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	}
+	msg.DisableWebPagePreview = true
+	fb.send(msg)
+}
+
+// sendSupportInvoice sends an invoice for a donation in Telegram Stars,
+// given the amount from its button. Only the configured amounts are
+// invoiced.
+func (fb *FatwaBot) sendSupportInvoice(chatID int64, amount string) {
+	stars, err := strconv.Atoi(amount)
+	if err != nil || fb.support == nil || !slices.Contains(fb.support.Stars, stars) {
+		fb.reply(chatID, "support.disabled")
+		return
+	}
+
+	lang := fb.language(chatID)
+	invoice := tgbotapi.NewInvoice(chatID,
+		htmlToText(tr(lang, "support.invoice_title")),
+		htmlToText(tr(lang, "support.invoice_description", stars)),
+		supportPayload+strconv.Itoa(stars), "", "", starsCurrency,
+		[]tgbotapi.LabeledPrice{{Label: htmlToText(tr(lang, "support.invoice_label")), Amount: stars}})
+	// The library sends no tip amounts as null, which Telegram rejects
+	invoice.SuggestedTipAmounts = []int{}
+	if _, err := fb.trySend(invoice); err != nil {
+		log.Printf("Error sending support invoice: %v", err)
+		fb.reply(chatID, "support.error")
+	}
+}
+
+// answerPreCheckout confirms a donation before Telegram takes the Stars,
+// as long as it is still one of the configured amounts.
+func (fb *FatwaBot) answerPreCheckout(query *tgbotapi.PreCheckoutQuery) {
+	stars, err := strconv.Atoi(strings.TrimPrefix(query.InvoicePayload, supportPayload))
+	ok := err == nil && strings.HasPrefix(query.InvoicePayload, supportPayload) &&
+		query.Currency == starsCurrency && query.TotalAmount == stars &&
+		fb.support != nil && slices.Contains(fb.support.Stars, stars)
+
+	answer := tgbotapi.PreCheckoutConfig{PreCheckoutQueryID: query.ID, OK: ok}
+	if !ok {
+		log.Printf("Rejecting payment %q of %d %s from %d", query.InvoicePayload, query.TotalAmount, query.Currency, query.From.ID)
+		answer.ErrorMessage = htmlToText(fb.t(query.From.ID, "support.unavailable"))
+	}
+	if _, err := fb.bot.Request(answer); err != nil {
+		log.Printf("Error answering pre-checkout query: %v", err)
+	}
+}
+
+// thankSupporter thanks a user whose donation went through and tells the
+// admins, with the user and charge IDs needed to refund it.
+func (fb *FatwaBot) thankSupporter(message *tgbotapi.Message) {
+	payment := message.SuccessfulPayment
+	chatID := message.Chat.ID
+	log.Printf("Received %d %s from chat %d (charge %s)", payment.TotalAmount, payment.Currency, chatID, payment.TelegramPaymentChargeID)
+
+	fb.reply(chatID, "support.thanks")
+	for adminID := range fb.admins {
+		fb.reply(adminID, "admin.support", payment.TotalAmount, chatID, message.From.ID, payment.TelegramPaymentChargeID)
+	}
+}
+
+// showPaySupport answers /paysupport, which Telegram requires of bots
+// taking Stars, and tells the admins who asked, so they can refund them.
+func (fb *FatwaBot) showPaySupport(chatID int64, user *tgbotapi.User) {
+	fb.reply(chatID, "support.paysupport")
+	if user == nil {
+		return
+	}
+	for adminID := range fb.admins {
+		fb.reply(adminID, "admin.paysupport", chatID, user.ID)
+	}
+}
+
+// refundSupport refunds a donation in Stars for an admin, given "USER
+// CHARGE" with the IDs they were told of when it was made.
+func (fb *FatwaBot) refundSupport(chatID int64, args string) {
+	fields := strings.Fields(args)
+	if len(fields) != 2 {
+		fb.reply(chatID, "support.refund_usage")
+		return
+	}
+	userID, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		fb.reply(chatID, "support.refund_usage")
+		return
+	}
+
+	// The library predates refunds, so the method is called by name
+	params := tgbotapi.Params{"telegram_payment_charge_id": fields[1]}
+	params.AddNonZero64("user_id", userID)
+	if _, err := fb.bot.MakeRequest("refundStarPayment", params); err != nil {
+		log.Printf("Error refunding charge %s: %v", fields[1], err)
+		fb.reply(chatID, "support.refund_error", err)
+		return
+	}
+	log.Printf("Refunded charge %s to user %d", fields[1], userID)
+	fb.reply(chatID, "support.refunded", userID)
+}
+
+// setSupportText replaces the /sokong message with an admin's text, or
+// restores the default with "default". Without a text it shows how.
+func (fb *FatwaBot) setSupportText(chatID int64, text string) {
+	text = strings.TrimSpace(text)
+	switch text {
+	case "":
+		fb.reply(chatID, "support.text_usage")
+		return
+	case "default":
+		if err := fb.store.DeleteBotText(supportTextName); err != nil {
+			log.Printf("Error restoring support text: %v", err)
+			fb.reply(chatID, "support.text_error")
+			return
+		}
+		fb.reply(chatID, "support.text_restored")
+	default:
+		if err := fb.store.SetBotText(supportTextName, text); err != nil {
+			log.Printf("Error saving support text: %v", err)
+			fb.reply(chatID, "support.text_error")
+			return
+		}
+		fb.reply(chatID, "support.text_saved")
+	}
+
+	if fb.support != nil {
+		fb.showSupport(chatID)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bufio"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// defaultSynonyms maps colloquial terms to the formal wording used in
+// fatwas. Entries from the synonyms file are added to these.
+var defaultSynonyms = map[string][]string{
+	"sembahyang": {"solat"},
+	"duit":       {"wang"},
+	"asb":        {"amanah saham"},
+	"qurban":     {"korban"},
+	"kahwin":     {"nikah", "perkahwinan"},
+	"bini":       {"isteri"},
+	"laki":       {"suami"},
+}
+
+// synonym expands a term to its alternatives wherever it occurs as whole
+// words in a query.
+type synonym struct {
+	Pattern      *regexp.Regexp
+	Alternatives []string
+}
+
+func synonymsPath() string {
+	if path := os.Getenv("SYNONYMS_PATH"); path != "" {
+		return path
+	}
+	return "synonyms.txt"
+}
+
+// querySynonyms loads the synonyms on first use. The synonyms file is
+// optional; each line has the form "term = alternative, alternative", and
+// lines starting with # are comments.
+var querySynonyms = sync.OnceValue(func() []synonym {
+	entries := make(map[string][]string, len(defaultSynonyms))
+	for term, alternatives := range defaultSynonyms {
+		entries[term] = alternatives
+	}
+
+	if file, err := os.Open(synonymsPath()); err == nil {
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			term, list, found := strings.Cut(line, "=")
+			term = normalizeSearchText(strings.TrimSpace(term))
+			if !found || term == "" {
+				log.Printf("Ignoring invalid synonym line %q", line)
+				continue
+			}
+			for _, alternative := range strings.Split(list, ",") {
+				if alternative = normalizeSearchText(strings.TrimSpace(alternative)); alternative != "" {
+					entries[term] = append(entries[term], alternative)
+				}
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			log.Printf("Error reading synonyms: %v", err)
+		}
+		file.Close()
+	} else if !os.IsNotExist(err) {
+		log.Printf("Error opening synonyms: %v", err)
+	}
+
+	terms := make([]string, 0, len(entries))
+	for term := range entries {
+		terms = append(terms, term)
+	}
+	sort.Strings(terms)
+
+	synonyms := make([]synonym, 0, len(terms))
+	for _, term := range terms {
+		synonyms = append(synonyms, synonym{
+			Pattern:      regexp.MustCompile(`(^|[^\pL\pN])` + regexp.QuoteMeta(term) + `([^\pL\pN]|$)`),
+			Alternatives: entries[term],
+		})
+	}
+	return synonyms
+})
+
+// expandSynonyms returns the variants of normalized query text with each
+// known term replaced by its alternatives, not including the text itself.
+func expandSynonyms(text string) []string {
+	var variants []string
+	for _, synonym := range querySynonyms() {
+		if !synonym.Pattern.MatchString(text) {
+			continue
+		}
+		for _, alternative := range synonym.Alternatives {
+			variant := synonym.Pattern.ReplaceAllString(text, "${1}"+strings.ReplaceAll(alternative, "$", "$$")+"${2}")
+			if variant != text {
+				variants = append(variants, variant)
+			}
+		}
+	}
+	return variants
+}
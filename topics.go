@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// topicsPeriod and topicsShown bound the requested topics report.
+	topicsPeriod = 30 * 24 * time.Hour
+	topicsShown  = 20
+)
+
+// topicButton is the "📝 Cadang topik" button offered when a search finds
+// nothing.
+func (fb *FatwaBot) topicButton(query, lang string) tgbotapi.InlineKeyboardButton {
+	return tgbotapi.NewInlineKeyboardButtonData(tr(lang, "button.suggest_topic"), fb.textCallback("topic", "", query))
+}
+
+// requestTopic records a search that found nothing as a topic the chat
+// wants covered. Unlike the query log behind /gaps, these are questions
+// users took the trouble to ask for. The request is acknowledged in the
+// callback answer, which it sends itself.
+func (fb *FatwaBot) requestTopic(callbackQuery *tgbotapi.CallbackQuery, query string) {
+	chatID := callbackQuery.Message.Chat.ID
+
+	id := "topic.thanks"
+	if err := fb.store.AddTopicRequest(chatID, query); err != nil {
+		log.Printf("Error saving topic request: %v", err)
+		id = "topic.error"
+	}
+	callback := tgbotapi.NewCallback(callbackQuery.ID, htmlToText(fb.t(chatID, id)))
+	if _, err := fb.bot.Request(callback); err != nil {
+		log.Printf("Error answering topic request: %v", err)
+	}
+}
+
+// showTopicRequests lists the topics users asked for over the last month,
+// most requested first.
+func (fb *FatwaBot) showTopicRequests(chatID int64) {
+	topics, err := fb.store.TopicRequests(time.Now().Add(-topicsPeriod), topicsShown)
+	if err != nil {
+		log.Printf("Error reading topic requests: %v", err)
+		fb.reply(chatID, "analytics.error")
+		return
+	}
+	if len(topics) == 0 {
+		fb.reply(chatID, "topic.none")
+		return
+	}
+
+	message := fb.t(chatID, "topic.report") + "\n\n"
+	for i, topic := range topics {
+		message += fmt.Sprintf("%d. <code>%s</code> × %d\n", i+1, html.EscapeString(topic.Query), topic.Count)
+	}
+	fb.sendMessage(chatID, message)
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// translateChunkLength is how much of a fatwa is translated per
+	// request.
+	translateChunkLength = 3000
+
+	// translateMaxParts caps the chunks translated for one fatwa; longer
+	// fatwas end with a link to read the rest in Malay.
+	translateMaxParts = 6
+
+	// llmTranslatePrompt instructs a chat model to translate a fatwa.
+	llmTranslatePrompt = "Translate the following Malay fatwa into English. " +
+		"Leave Arabic passages, such as Quran verses and hadith, as they are. " +
+		"Reply with the translation only."
+)
+
+// translateConfig holds the settings for translating fatwas into English
+// with DeepL, Google Cloud Translation, or a chat model with the OpenAI
+// chat completions API.
+type translateConfig struct {
+	Provider string
+	APIKey   string
+	Model    string
+	Endpoint string
+}
+
+// loadTranslateConfig reads the translation settings from the environment.
+// Translation is disabled unless TRANSLATE_API_KEY is set.
+// TRANSLATE_PROVIDER is "deepl" (the default), "google" or "llm".
+func loadTranslateConfig() (*translateConfig, bool) {
+	cfg := &translateConfig{
+		Provider: strings.ToLower(os.Getenv("TRANSLATE_PROVIDER")),
+		APIKey:   os.Getenv("TRANSLATE_API_KEY"),
+		Model:    os.Getenv("TRANSLATE_MODEL"),
+		Endpoint: os.Getenv("TRANSLATE_ENDPOINT"),
+	}
+
+	if cfg.APIKey == "" {
+		return nil, false
+	}
+	if cfg.Provider == "" {
+		cfg.Provider = "deepl"
+	}
+
+	switch cfg.Provider {
+	case "deepl":
+		if cfg.Endpoint == "" {
+			// Keys of DeepL's free plan end in ":fx" and have their own host
+			cfg.Endpoint = "https://api.deepl.com/v2/translate"
+			if strings.HasSuffix(cfg.APIKey, ":fx") {
+				cfg.Endpoint = "https://api-free.deepl.com/v2/translate"
+			}
+		}
+	case "google":
+		if cfg.Endpoint == "" {
+			cfg.Endpoint = "https://translation.googleapis.com/language/translate/v2"
+		}
+	case "llm":
+		if cfg.Model == "" {
+			cfg.Model = "gpt-4o-mini"
+		}
+		if cfg.Endpoint == "" {
+			cfg.Endpoint = "https://api.openai.com/v1/chat/completions"
+		}
+	default:
+		log.Printf("Unknown TRANSLATE_PROVIDER %q, translation disabled", cfg.Provider)
+		return nil, false
+	}
+
+	return cfg, true
+}
+
+// translate returns the English translation of Malay text.
+func (cfg *translateConfig) translate(text string) (string, error) {
+	if cfg.Provider == "llm" {
+		llm := &llmConfig{APIKey: cfg.APIKey, Model: cfg.Model, Endpoint: cfg.Endpoint}
+		return llm.complete(llmTranslatePrompt, text)
+	}
+
+	var request any
+	switch cfg.Provider {
+	case "deepl":
+		request = map[string]any{"text": []string{text}, "target_lang": "EN-GB"}
+	case "google":
+		request = map[string]any{"q": []string{text}, "source": "ms", "target": "en", "format": "text"}
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return "", fmt.Errorf("cannot encode translation request: %v", err)
+	}
+
+	req, err := http.NewRequest("POST", cfg.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("cannot create translation request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	switch cfg.Provider {
+	case "deepl":
+		req.Header.Set("Authorization", "DeepL-Auth-Key "+cfg.APIKey)
+	case "google":
+		req.Header.Set("X-Goog-Api-Key", cfg.APIKey)
+	}
+
+	client := &http.Client{Timeout: 60 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("cannot request translation: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("translation request failed: %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+
+	var result struct {
+		// DeepL
+		Translations []struct {
+			Text string `json:"text"`
+		} `json:"translations"`
+		// Google
+		Data struct {
+			Translations []struct {
+				TranslatedText string `json:"translatedText"`
+			} `json:"translations"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("cannot decode translation response: %v", err)
+	}
+
+	var translated string
+	switch {
+	case len(result.Translations) > 0:
+		translated = result.Translations[0].Text
+	case len(result.Data.Translations) > 0:
+		translated = result.Data.Translations[0].TranslatedText
+	default:
+		return "", fmt.Errorf("translation response has no translation")
+	}
+	return strings.TrimSpace(translated), nil
+}
+
+// translateButton is the "🌐 Translate" button under fatwa details.
+func translateButton(key FatwaKey, lang string) tgbotapi.InlineKeyboardButton {
+	return tgbotapi.NewInlineKeyboardButtonData(tr(lang, "button.translate"), fatwaCallback("translate", key))
+}
+
+// sendTranslation translates a fatwa into English, given its ID as shown in
+// its details. Translations are saved per fatwa, so each is only requested
+// once; new ones take a while, so they are made in the background, and
+// chats asking for one already being made wait for it.
+func (fb *FatwaBot) sendTranslation(chatID int64, idStr string) {
+	if fb.translator == nil {
+		fb.reply(chatID, "translate.disabled")
+		return
+	}
+
+	key, err := parseFatwaKey(strings.TrimSpace(idStr))
+	if err != nil {
+		fb.reply(chatID, "id.invalid")
+		return
+	}
+	fatwa, ok := fb.findFatwa(key)
+	if !ok {
+		fb.reply(chatID, "id.not_found", key)
+		return
+	}
+
+	checksum := contentChecksum(fatwa.Content)
+	translation, ok, err := fb.store.Translation(key, checksum)
+	if err != nil {
+		log.Printf("Error reading translation: %v", err)
+	}
+	if ok {
+		fb.sendTranslated(chatID, fatwa, translation)
+		return
+	}
+
+	fb.sendTyping(chatID)
+	if !fb.translating.join(key.String(), chatID) {
+		return
+	}
+	fb.goSafe(chatID, func() {
+		// Forget the request even if making it panics
+		defer fb.translating.done(key.String())
+
+		translation, err := fb.translateFatwa(fatwa)
+		chats := fb.translating.done(key.String())
+		if err != nil {
+			log.Printf("Error translating fatwa %s: %v", key, err)
+			for _, chatID := range chats {
+				fb.reply(chatID, "translate.error")
+			}
+			return
+		}
+		if err := fb.store.SetTranslation(key, checksum, translation); err != nil {
+			log.Printf("Error saving translation: %v", err)
+		}
+		for _, chatID := range chats {
+			fb.sendTranslated(chatID, fatwa, translation)
+		}
+	})
+}
+
+// translateFatwa translates a fatwa's title, and its content chunk by
+// chunk up to translateMaxParts chunks.
+func (fb *FatwaBot) translateFatwa(fatwa Fatwa) (Translation, error) {
+	title, err := fb.translator.translate(fatwa.Title)
+	if err != nil {
+		return Translation{}, err
+	}
+	translation := Translation{Title: title}
+
+	chunks := fb.splitText(strings.TrimSpace(fatwa.Content), translateChunkLength)
+	if len(chunks) > translateMaxParts {
+		chunks, translation.Truncated = chunks[:translateMaxParts], true
+	}
+
+	translated := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		if translated[i], err = fb.translator.translate(chunk); err != nil {
+			return Translation{}, err
+		}
+	}
+	translation.Content = strings.Join(translated, "\n\n")
+	return translation, nil
+}
+
+// sendTranslated sends a fatwa's translation in parts of readingPartLength,
+// escaping each so no entity is cut in two, followed by a disclaimer
+// pointing at the original, as a machine translation of a ruling may miss
+// its nuances.
+func (fb *FatwaBot) sendTranslated(chatID int64, fatwa Fatwa, translation Translation) {
+	for i, part := range fb.splitText(translation.Content, readingPartLength) {
+		message := html.EscapeString(part)
+		if i == 0 {
+			message = fmt.Sprintf("🌐 <b>%s</b>\n\n%s", html.EscapeString(translation.Title), message)
+		}
+		fb.sendMessage(chatID, message)
+	}
+
+	if translation.Truncated {
+		fb.reply(chatID, "translate.truncated", fatwa.URL)
+	}
+	fb.reply(chatID, "translate.disclaimer", fatwa.URL)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// trendingPeriod and trendingShown bound the /trending list.
+	trendingPeriod = 7 * 24 * time.Hour
+	trendingShown  = 10
+
+	// trendingMinChats is how many different chats must have searched for
+	// something before it is listed, so no one's own searches are shown.
+	trendingMinChats = 3
+)
+
+// showTrending lists the most searched topics of the last week, with a
+// button to run each search the way it was made, so seasonal questions (Ramadan, korban,
+// zakat) are a tap away.
+func (fb *FatwaBot) showTrending(chatID int64) {
+	queries, err := fb.store.TrendingQueries(time.Now().Add(-trendingPeriod), trendingMinChats, trendingShown)
+	if err != nil {
+		log.Printf("Error reading trending queries: %v", err)
+		fb.reply(chatID, "trending.error")
+		return
+	}
+	if len(queries) == 0 {
+		fb.reply(chatID, "trending.none")
+		return
+	}
+
+	lang := fb.language(chatID)
+	message := tr(lang, "trending.title") + "\n\n"
+	var keyboard [][]tgbotapi.InlineKeyboardButton
+	for i, query := range queries {
+		message += fmt.Sprintf("%d. <b>%s</b> — %s\n", i+1, html.EscapeString(query.Query), tr(lang, "trending.count", query.Count))
+		keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔥 "+query.Query, fb.textCallback("trend", query.Type, query.Query)),
+		))
+	}
+
+	msg := tgbotapi.NewMessage(chatID, message)
+	msg.ParseMode = tgbotapi.ModeHTML
+	if len(keyboard) > 0 {
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(keyboard...)
+	}
+	fb.send(msg)
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// ttsChunkLength keeps each request under the API's limit of 5000 bytes
+	// of input text.
+	ttsChunkLength = 4500
+
+	// ttsMaxParts caps the voice notes sent for one fatwa, about 25 minutes
+	// of speech; longer fatwas end with a link to read the rest.
+	ttsMaxParts = 5
+)
+
+// ttsConfig holds the settings for reading fatwas aloud with the Google
+// Cloud Text-to-Speech API.
+type ttsConfig struct {
+	APIKey   string
+	Voice    string
+	Endpoint string
+}
+
+// loadTTSConfig reads the text-to-speech settings from the environment.
+// Audio is disabled unless TTS_API_KEY is set.
+func loadTTSConfig() (*ttsConfig, bool) {
+	cfg := &ttsConfig{
+		APIKey:   os.Getenv("TTS_API_KEY"),
+		Voice:    os.Getenv("TTS_VOICE"),
+		Endpoint: os.Getenv("TTS_ENDPOINT"),
+	}
+
+	if cfg.APIKey == "" {
+		return nil, false
+	}
+	if cfg.Voice == "" {
+		cfg.Voice = "ms-MY-Wavenet-A"
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "https://texttospeech.googleapis.com/v1/text:synthesize"
+	}
+
+	return cfg, true
+}
+
+// synthesize returns the speech for a text as an Ogg Opus file, the format
+// Telegram plays as a voice note.
+func (cfg *ttsConfig) synthesize(text string) ([]byte, error) {
+	body, err := json.Marshal(map[string]any{
+		"input":       map[string]string{"text": text},
+		"voice":       map[string]string{"languageCode": "ms-MY", "name": cfg.Voice},
+		"audioConfig": map[string]string{"audioEncoding": "OGG_OPUS"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode speech request: %v", err)
+	}
+
+	req, err := http.NewRequest("POST", cfg.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("cannot create speech request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Goog-Api-Key", cfg.APIKey)
+
+	client := &http.Client{Timeout: 60 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("cannot request speech: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("speech request failed: %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+
+	var result struct {
+		AudioContent string `json:"audioContent"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("cannot decode speech response: %v", err)
+	}
+
+	audio, err := base64.StdEncoding.DecodeString(result.AudioContent)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode audio: %v", err)
+	}
+	return audio, nil
+}
+
+// listenButton is the "🔊 Dengar" button under fatwa details.
+func listenButton(key FatwaKey, lang string) tgbotapi.InlineKeyboardButton {
+	return tgbotapi.NewInlineKeyboardButtonData(tr(lang, "button.listen"), fatwaCallback("tts", key))
+}
+
+// sendFatwaAudio reads a fatwa aloud as voice notes, given its ID as shown
+// in its details. Speech takes a while to generate, so it is sent in the
+// background. Voice notes once sent are sent again by their file IDs.
+func (fb *FatwaBot) sendFatwaAudio(chatID int64, idStr string) {
+	if fb.tts == nil {
+		fb.reply(chatID, "tts.disabled")
+		return
+	}
+
+	key, err := parseFatwaKey(strings.TrimSpace(idStr))
+	if err != nil {
+		fb.reply(chatID, "id.invalid")
+		return
+	}
+
+	fatwa, ok := fb.findFatwa(key)
+	if !ok {
+		fb.reply(chatID, "id.not_found", key)
+		return
+	}
+
+	fb.bot.Request(tgbotapi.NewChatAction(chatID, tgbotapi.ChatRecordVoice))
+	fb.goSafe(chatID, func() { fb.speakFatwa(chatID, fatwa) })
+}
+
+// speakFatwa sends the voice notes of a fatwa, one per chunk of its title
+// and content. Parts not sent before are generated, and their file IDs
+// saved for the text read, so they are only paid for once.
+func (fb *FatwaBot) speakFatwa(chatID int64, fatwa Fatwa) {
+	text := ttsText(fatwa)
+	checksum := contentChecksum(text)
+	saved, err := fb.store.VoiceNotes(fatwa.Key(), checksum)
+	if err != nil {
+		log.Printf("Error reading voice notes: %v", err)
+	}
+
+	chunks := fb.splitText(text, ttsChunkLength)
+	truncated := len(chunks) > ttsMaxParts
+	if truncated {
+		chunks = chunks[:ttsMaxParts]
+	}
+
+	for i, chunk := range chunks {
+		var file tgbotapi.RequestFileData
+		if fileID, ok := saved[i+1]; ok {
+			file = tgbotapi.FileID(fileID)
+		} else {
+			audio, err := fb.tts.synthesize(chunk)
+			if err != nil {
+				log.Printf("Error generating audio for fatwa %s: %v", fatwa.Key(), err)
+				fb.reply(chatID, "tts.error")
+				return
+			}
+			file = tgbotapi.FileBytes{
+				Name:  fmt.Sprintf("fatwa-%s-%d-%d.ogg", fatwa.Source, fatwa.ID, i+1),
+				Bytes: audio,
+			}
+		}
+
+		voice := tgbotapi.NewVoice(chatID, file)
+		voice.Caption = fmt.Sprintf("🔊 <b>%s</b>", html.EscapeString(fatwa.Title))
+		if len(chunks) > 1 {
+			voice.Caption += " " + fb.t(chatID, "tts.part", i+1, len(chunks))
+		}
+		voice.ParseMode = tgbotapi.ModeHTML
+		sent, err := fb.trySend(voice)
+		if err != nil {
+			log.Printf("Error sending audio: %v", err)
+			fb.reply(chatID, "tts.error")
+			return
+		}
+
+		if _, ok := saved[i+1]; !ok && sent.Voice != nil {
+			if err := fb.store.SetVoiceNote(fatwa.Key(), i+1, checksum, sent.Voice.FileID); err != nil {
+				log.Printf("Error saving voice note: %v", err)
+			}
+		}
+	}
+
+	if truncated {
+		fb.reply(chatID, "tts.truncated", fatwa.URL)
+	}
+}
+
+// ttsText is what is read aloud for a fatwa: its title, then its content.
+// Arabic passages are left out, as a Malay voice can't pronounce them.
+func ttsText(fatwa Fatwa) string {
+	text := fatwa.Title + ". " + fatwa.Content
+	text = arabicRun.ReplaceAllString(text, "")
+	return strings.Join(strings.Fields(text), " ")
+}
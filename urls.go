@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+)
+
+// trackingParams are query parameters that never affect which article a
+// URL points to.
+var trackingParams = map[string]bool{
+	"fbclid": true,
+	"gclid":  true,
+	"igshid": true,
+	"mc_cid": true,
+	"mc_eid": true,
+	"ref":    true,
+}
+
+// absoluteURL resolves a link found on a page against the page's URL.
+func absoluteURL(pageURL, href string) string {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return href
+	}
+	ref, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return href
+	}
+	return base.ResolveReference(ref).String()
+}
+
+// canonicalURL normalizes an article URL so the same fatwa reached through
+// different links is stored once: https only, a single host name, the ms/
+// language prefix on article paths, no trailing slash, no fragment and no
+// tracking parameters.
+func canonicalURL(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return raw
+	}
+
+	u.Scheme = "https"
+
+	host := strings.ToLower(u.Hostname())
+	if host == "muftiwp.gov.my" {
+		host = "www.muftiwp.gov.my"
+	}
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		host += ":" + port
+	}
+	u.Host = host
+
+	path := u.Path
+	if host == "www.muftiwp.gov.my" && strings.HasPrefix(path, "/artikel/") {
+		path = "/ms" + path
+	}
+	if len(path) > 1 {
+		path = strings.TrimRight(path, "/")
+	}
+	u.Path = path
+	u.RawPath = ""
+
+	query := u.Query()
+	for key := range query {
+		if trackingParams[strings.ToLower(key)] || strings.HasPrefix(strings.ToLower(key), "utm_") {
+			query.Del(key)
+		}
+	}
+	u.RawQuery = query.Encode()
+	u.Fragment = ""
+
+	return u.String()
+}
+
+// dedupeByURL drops articles whose canonical URL has already been seen,
+// keeping the first occurrence.
+func dedupeByURL(articles []Fatwa) []Fatwa {
+	seen := make(map[string]bool, len(articles))
+	unique := articles[:0]
+
+	for _, article := range articles {
+		key := canonicalURL(article.URL)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, article)
+	}
+
+	return unique
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// webhookConfig holds the settings for receiving updates over a webhook
+// instead of long polling, for hosts that only run HTTP servers.
+type webhookConfig struct {
+	URL    string // public HTTPS address Telegram posts updates to
+	Listen string // address the HTTP server listens on
+	Secret string // token Telegram sends with every update
+}
+
+// webhookMux routes the webhook updates of each bot of the process to it,
+// by path, and webhookServe starts serving it once.
+var (
+	webhookMux   = http.NewServeMux()
+	webhookServe sync.Once
+)
+
+// loadWebhookConfig reads the webhook settings from the environment. The
+// bot polls for updates unless WEBHOOK_URL is set. Without WEBHOOK_SECRET,
+// the secret is derived from the bot token, so every instance of the bot
+// agrees on it. Bots other than the main one, named name, receive their
+// updates on WEBHOOK_URL followed by "/name".
+func loadWebhookConfig(botToken, name string) (webhookConfig, bool) {
+	cfg := webhookConfig{
+		URL:    os.Getenv("WEBHOOK_URL"),
+		Listen: os.Getenv("WEBHOOK_LISTEN"),
+		Secret: os.Getenv("WEBHOOK_SECRET"),
+	}
+
+	if cfg.URL == "" {
+		return cfg, false
+	}
+	if name != "" {
+		cfg.URL = strings.TrimSuffix(cfg.URL, "/") + "/" + name
+	}
+	if cfg.Listen == "" {
+		// Serverless platforms tell the app which port to use in PORT
+		if port := os.Getenv("PORT"); port != "" {
+			cfg.Listen = ":" + port
+		} else {
+			cfg.Listen = ":8080"
+		}
+	}
+	if cfg.Secret == "" {
+		sum := sha256.Sum256([]byte("webhook:" + botToken))
+		cfg.Secret = hex.EncodeToString(sum[:16])
+	}
+
+	return cfg, true
+}
+
+// updates returns the channel the bot's updates arrive on, from the webhook
+// if one is configured and by long polling otherwise.
+func (fb *FatwaBot) updates() tgbotapi.UpdatesChannel {
+	if cfg, ok := loadWebhookConfig(fb.bot.Token, fb.name); ok {
+		updates, err := fb.listenWebhook(cfg)
+		if err != nil {
+			log.Fatalf("Error starting webhook: %v", err)
+		}
+		return updates
+	}
+
+	// Telegram refuses to be polled while a webhook is set, such as one
+	// left behind by an earlier deployment
+	if _, err := fb.bot.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+		log.Printf("Error removing webhook: %v", err)
+	}
+
+	u := tgbotapi.NewUpdate(0)
+	u.Timeout = 60
+	return fb.bot.GetUpdatesChan(u)
+}
+
+// listenWebhook registers the webhook with Telegram and serves it. Requests
+// without the secret token are rejected, so only Telegram can send updates.
+func (fb *FatwaBot) listenWebhook(cfg webhookConfig) (tgbotapi.UpdatesChannel, error) {
+	webhookURL, err := url.Parse(cfg.URL)
+	if err != nil || webhookURL.Scheme != "https" {
+		return nil, fmt.Errorf("WEBHOOK_URL must be an https:// URL")
+	}
+
+	// The library's WebhookConfig predates secret tokens, so the webhook
+	// is set with a raw request
+	params := tgbotapi.Params{"url": cfg.URL, "secret_token": cfg.Secret}
+	if _, err := fb.bot.MakeRequest("setWebhook", params); err != nil {
+		return nil, fmt.Errorf("cannot set webhook: %v", err)
+	}
+
+	updates := make(chan tgbotapi.Update, fb.bot.Buffer)
+
+	path := webhookURL.Path
+	if path == "" {
+		path = "/"
+	}
+	webhookMux.HandleFunc(path, fb.webhookHandler(cfg.Secret, updates))
+	log.Printf("Listening for webhook updates on %s%s", cfg.Listen, path)
+
+	// Every bot's webhook is served by the same server
+	webhookServe.Do(func() {
+		go func() {
+			if err := http.ListenAndServe(cfg.Listen, webhookMux); err != nil {
+				log.Fatalf("Error serving webhook: %v", err)
+			}
+		}()
+	})
+
+	return updates, nil
+}
+
+// webhookHandler passes the updates Telegram posts on to the bot, after
+// checking they carry the secret token.
+func (fb *FatwaBot) webhookHandler(secret string, updates chan<- tgbotapi.Update) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get("X-Telegram-Bot-Api-Secret-Token")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		update, err := fb.bot.HandleUpdate(r)
+		if err != nil {
+			log.Printf("Error reading webhook update: %v", err)
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		updates <- *update
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// The steps of the /cari search wizard, kept as the chat's conversation
+// state in the user store: choosing what to search by, then a category,
+// then typing the keywords. Category searches end at the category.
+const (
+	wizardPickType     = "cari.type"
+	wizardPickCategory = "cari.category"
+	wizardKeywords     = "cari.keywords"
+)
+
+// wizardTimeout is how long the wizard waits for the next step before the
+// chat's messages are handled as usual again.
+const wizardTimeout = 10 * time.Minute
+
+// wizardTypes are the search types offered by the wizard, by message ID of
+// their button labels.
+var wizardTypes = []struct {
+	Type  string
+	Label string
+}{
+	{"keyword", "button.wizard_keyword"},
+	{"title", "button.wizard_title"},
+	{"category", "button.wizard_category"},
+}
+
+// startWizard starts a guided search, for users who find free-text
+// commands confusing, by asking what to search by. Groups don't send the
+// bot the keywords typed at the last step, so it is for private chats.
+func (fb *FatwaBot) startWizard(chatID int64) {
+	if isGroupChat(chatID) {
+		fb.reply(chatID, "wizard.private")
+		return
+	}
+
+	if err := fb.store.SetConversation(chatID, Conversation{State: wizardPickType}); err != nil {
+		log.Printf("Error starting search wizard: %v", err)
+		fb.reply(chatID, "wizard.error")
+		return
+	}
+
+	lang := fb.language(chatID)
+	var keyboard [][]tgbotapi.InlineKeyboardButton
+	for _, option := range wizardTypes {
+		keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(tr(lang, option.Label), "cari_type_"+option.Type),
+		))
+	}
+	keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(wizardCancelButton(lang)))
+
+	msg := tgbotapi.NewMessage(chatID, tr(lang, "wizard.type"))
+	msg.ParseMode = tgbotapi.ModeHTML
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(keyboard...)
+	fb.send(msg)
+}
+
+// handleWizardButton moves the wizard on from a button pressed on its
+// message, which is edited into the next step. value is "type_TYPE",
+// "catpage_OFFSET", "cat" with hash that of the category (empty for every
+// category) or "cancel"; older category buttons sent "cat_CATEGORY".
+// Buttons of a step the chat is no longer at are reported as expired.
+func (fb *FatwaBot) handleWizardButton(message *tgbotapi.Message, value, hash string) {
+	chatID := message.Chat.ID
+	lang := fb.language(chatID)
+
+	if value == "cancel" {
+		fb.endWizard(chatID)
+		fb.replaceStatus(chatID, message.MessageID, tr(lang, "wizard.cancelled"), nil)
+		return
+	}
+
+	conversation, ok := fb.wizardState(chatID)
+	action, argument, _ := strings.Cut(value, "_")
+	switch {
+	case ok && conversation.State == wizardPickType && action == "type":
+		if !isWizardType(argument) {
+			fb.reply(chatID, "wizard.expired")
+			return
+		}
+		conversation = Conversation{State: wizardPickCategory, SearchType: argument}
+		if err := fb.store.SetConversation(chatID, conversation); err != nil {
+			log.Printf("Error saving search wizard: %v", err)
+			fb.reply(chatID, "wizard.error")
+			return
+		}
+		text, keyboard := fb.wizardCategoryPage(conversation.SearchType, 0, lang)
+		fb.replaceStatus(chatID, message.MessageID, text, &keyboard)
+
+	case ok && conversation.State == wizardPickCategory && action == "catpage":
+		offset, err := strconv.Atoi(argument)
+		if err != nil || offset < 0 {
+			fb.reply(chatID, "error.page")
+			return
+		}
+		text, keyboard := fb.wizardCategoryPage(conversation.SearchType, offset, lang)
+		fb.replaceStatus(chatID, message.MessageID, text, &keyboard)
+
+	case ok && conversation.State == wizardPickCategory && action == "cat":
+		if hash != "" {
+			categories, _ := fb.datasetCategories()
+			if argument, ok = categoryByHash(categories, hash); !ok {
+				fb.reply(chatID, "wizard.expired")
+				return
+			}
+		}
+
+		// A category search needs nothing more than the category
+		if conversation.SearchType == "category" {
+			fb.endWizard(chatID)
+			fb.replaceStatus(chatID, message.MessageID, tr(lang, "details.category", argument), nil)
+			fb.searchFatwas(chatID, argument, "category")
+			return
+		}
+
+		conversation.State, conversation.Category = wizardKeywords, argument
+		if err := fb.store.SetConversation(chatID, conversation); err != nil {
+			log.Printf("Error saving search wizard: %v", err)
+			fb.reply(chatID, "wizard.error")
+			return
+		}
+		scope := tr(lang, "wizard.all_categories")
+		if argument != "" {
+			scope = argument
+		}
+		keyboard := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(wizardCancelButton(lang)))
+		fb.replaceStatus(chatID, message.MessageID, tr(lang, "wizard.keywords", scope), &keyboard)
+
+	default:
+		fb.reply(chatID, "wizard.expired")
+	}
+}
+
+// continueWizard runs the wizard's search with text as the keywords, if
+// the chat is at that step. It reports whether text was taken.
+func (fb *FatwaBot) continueWizard(chatID int64, text string) bool {
+	conversation, ok := fb.wizardState(chatID)
+	if !ok || conversation.State != wizardKeywords {
+		return false
+	}
+
+	fb.endWizard(chatID)
+	fb.searchFatwasIn(chatID, text, conversation.SearchType, conversation.Category)
+	return true
+}
+
+// wizardState returns the wizard step a chat is at, unless it is in none
+// or has left it unanswered for longer than wizardTimeout.
+func (fb *FatwaBot) wizardState(chatID int64) (Conversation, bool) {
+	conversation, ok, err := fb.store.Conversation(chatID)
+	if err != nil {
+		log.Printf("Error reading search wizard: %v", err)
+		return Conversation{}, false
+	}
+	if !ok || !strings.HasPrefix(conversation.State, "cari.") || time.Since(conversation.UpdatedAt) > wizardTimeout {
+		return Conversation{}, false
+	}
+	return conversation, true
+}
+
+func (fb *FatwaBot) endWizard(chatID int64) {
+	if err := fb.store.EndConversation(chatID); err != nil {
+		log.Printf("Error ending search wizard: %v", err)
+	}
+}
+
+func isWizardType(searchType string) bool {
+	for _, option := range wizardTypes {
+		if option.Type == searchType {
+			return true
+		}
+	}
+	return false
+}
+
+func wizardCancelButton(lang string) tgbotapi.InlineKeyboardButton {
+	return tgbotapi.NewInlineKeyboardButtonData(tr(lang, "broadcast.cancel"), "cari_cancel")
+}
+
+// wizardCategoryPage formats the wizard's page of category buttons
+// starting at offset, like categoriesPage. Keyword and title searches may
+// also search every category.
+func (fb *FatwaBot) wizardCategoryPage(searchType string, offset int, lang string) (string, tgbotapi.InlineKeyboardMarkup) {
+	categories, counts := fb.datasetCategories()
+	if offset >= len(categories) {
+		offset = max(len(categories)-1, 0) / categoriesPerPage * categoriesPerPage
+	}
+	end := min(offset+categoriesPerPage, len(categories))
+
+	message := tr(lang, "wizard.category")
+	if len(categories) > categoriesPerPage {
+		message += "\n\n" + tr(lang, "categories.range", offset+1, end, len(categories))
+	}
+
+	var keyboard [][]tgbotapi.InlineKeyboardButton
+	if searchType != "category" {
+		keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(tr(lang, "button.wizard_all"), callbackData{Action: "cari", Arg: "cat"}.String()),
+		))
+	}
+	keyboard = append(keyboard, categoryButtons(categories[offset:end], counts, "📂", callbackData{Action: "cari", Arg: "cat"})...)
+
+	var navigation []tgbotapi.InlineKeyboardButton
+	if offset > 0 {
+		navigation = append(navigation, tgbotapi.NewInlineKeyboardButtonData(
+			tr(lang, "button.previous"),
+			fmt.Sprintf("cari_catpage_%d", max(offset-categoriesPerPage, 0)),
+		))
+	}
+	if end < len(categories) {
+		navigation = append(navigation, tgbotapi.NewInlineKeyboardButtonData(
+			tr(lang, "button.next"),
+			fmt.Sprintf("cari_catpage_%d", end),
+		))
+	}
+	if len(navigation) > 0 {
+		keyboard = append(keyboard, navigation)
+	}
+	keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(wizardCancelButton(lang)))
+
+	return message, tgbotapi.NewInlineKeyboardMarkup(keyboard...)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"os"
+	"strconv"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// defaultUpdateWorkers is how many updates are handled at once, unless
+	// UPDATE_WORKERS says otherwise.
+	defaultUpdateWorkers = 8
+
+	// updateQueueLength is how many updates may wait for each worker before
+	// receiving more updates waits for it.
+	updateQueueLength = 100
+)
+
+// updateWorkers returns how many workers handle updates.
+func updateWorkers() int {
+	if workers, err := strconv.Atoi(os.Getenv("UPDATE_WORKERS")); err == nil && workers > 0 {
+		return workers
+	}
+	return defaultUpdateWorkers
+}
+
+// updatePool handles updates on a fixed number of workers, so a slow
+// search only holds up the chats sharing its worker rather than every
+// user. Each chat's updates always go to the same worker and are handled
+// in the order they arrived, so a search and the page button pressed
+// after it can't be handled the wrong way round.
+type updatePool struct {
+	queues []chan tgbotapi.Update
+}
+
+// newUpdatePool starts workers that pass their updates to handle.
+func newUpdatePool(workers int, handle func(tgbotapi.Update)) *updatePool {
+	pool := &updatePool{queues: make([]chan tgbotapi.Update, workers)}
+	for i := range pool.queues {
+		queue := make(chan tgbotapi.Update, updateQueueLength)
+		pool.queues[i] = queue
+		go func() {
+			for update := range queue {
+				handle(update)
+			}
+		}()
+	}
+	return pool
+}
+
+// dispatch queues an update on its chat's worker. Updates without a chat
+// go to the first worker.
+func (p *updatePool) dispatch(update tgbotapi.Update) {
+	worker := 0
+	if chatID, _, ok := updateChat(update); ok {
+		worker = int(uint64(chatID) % uint64(len(p.queues)))
+	}
+	p.queues[worker] <- update
+}
+
+// close stops the workers once they have handled their queued updates.
+func (p *updatePool) close() {
+	for _, queue := range p.queues {
+		close(queue)
+	}
+}
+
+// updateChat returns the chat an update comes from and its sender. Inline
+// queries have no chat and count as the sender's private chat.
+func updateChat(update tgbotapi.Update) (int64, *tgbotapi.User, bool) {
+	switch {
+	case update.Message != nil:
+		return update.Message.Chat.ID, update.Message.From, true
+	case update.CallbackQuery != nil && update.CallbackQuery.Message != nil:
+		return update.CallbackQuery.Message.Chat.ID, update.CallbackQuery.From, true
+	case update.InlineQuery != nil && update.InlineQuery.From != nil:
+		return update.InlineQuery.From.ID, update.InlineQuery.From, true
+	}
+	return 0, nil, false
+}